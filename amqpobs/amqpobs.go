@@ -0,0 +1,126 @@
+// Package amqpobs carries trace context through RabbitMQ (AMQP 0-9-1) message
+// headers for services built on the observability library. Publishers inject
+// the current trace context into the headers, and consumers start one span
+// per delivery that links back to the publishing trace.
+//
+// The helpers use OpenTelemetry span kinds and links, so the spans are only
+// exported when the service runs with the OTLP APM type.
+package amqpobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/app-obs/go/observability"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "amqpobs"
+
+// HeaderCarrier adapts an amqp.Table to a propagation.TextMapCarrier.
+type HeaderCarrier amqp.Table
+
+var _ propagation.TextMapCarrier = HeaderCarrier{}
+
+func (c HeaderCarrier) Get(key string) string {
+	switch v := c[key].(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	default:
+		return ""
+	}
+}
+
+func (c HeaderCarrier) Set(key, value string) {
+	c[key] = value
+}
+
+func (c HeaderCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectAMQPHeaders writes the trace context (and baggage) of ctx into
+// headers and returns them, allocating a new table if headers is nil.
+func InjectAMQPHeaders(ctx context.Context, headers amqp.Table) amqp.Table {
+	if headers == nil {
+		headers = amqp.Table{}
+	}
+	otel.GetTextMapPropagator().Inject(ctx, HeaderCarrier(headers))
+	return headers
+}
+
+// ExtractAMQPHeaders returns a copy of ctx carrying the trace context found in headers.
+func ExtractAMQPHeaders(ctx context.Context, headers amqp.Table) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, HeaderCarrier(headers))
+}
+
+// Publish publishes msg under a producer span, injecting the trace context
+// into its headers so consumers can link back to this trace.
+func Publish(ctx context.Context, ch *amqp.Channel, exchange, routingKey string, msg amqp.Publishing) error {
+	ctx, span := otel.Tracer(tracerName).Start(ctx, routingKey+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "rabbitmq"),
+			attribute.String("messaging.operation", "publish"),
+			attribute.String("messaging.destination.name", exchange),
+			attribute.String("messaging.rabbitmq.destination.routing_key", routingKey),
+		),
+	)
+	defer span.End()
+
+	msg.Headers = InjectAMQPHeaders(ctx, msg.Headers)
+	if err := ch.PublishWithContext(ctx, exchange, routingKey, false, false, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// Handler processes a single delivery. obs is bound to the delivery's
+// consumer span: use it (rather than ObsFromCtx) to log and to start child
+// spans.
+type Handler func(ctx context.Context, obs *observability.Observability, d amqp.Delivery) error
+
+// Middleware wraps h so that every delivery from queue is handled under its
+// own consumer span. The span starts a new trace linked to the publishing
+// trace, since the consumer may run long after the publisher finished. An
+// error returned by h is recorded on the span and passed back to the caller,
+// which decides whether to ack or nack the delivery.
+func Middleware(factory *observability.Factory, queue string, h Handler) func(amqp.Delivery) error {
+	return func(d amqp.Delivery) error {
+		publishCtx := ExtractAMQPHeaders(context.Background(), d.Headers)
+
+		ctx, span := otel.Tracer(tracerName).Start(context.Background(), queue+" process",
+			trace.WithNewRoot(),
+			trace.WithLinks(trace.LinkFromContext(publishCtx)),
+			trace.WithSpanKind(trace.SpanKindConsumer),
+			trace.WithAttributes(
+				attribute.String("messaging.system", "rabbitmq"),
+				attribute.String("messaging.operation", "process"),
+				attribute.String("messaging.source.name", queue),
+				attribute.String("messaging.message.id", d.MessageId),
+				attribute.Bool("messaging.rabbitmq.redelivered", d.Redelivered),
+			),
+		)
+		defer span.End()
+
+		obs := factory.NewBackgroundObservability(ctx)
+		if err := h(ctx, obs, d); err != nil {
+			obs.ErrorHandler.Record(err, "Failed to process delivery")
+			return err
+		}
+		return nil
+	}
+}
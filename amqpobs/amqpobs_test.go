@@ -0,0 +1,128 @@
+package amqpobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/app-obs/go/observability"
+	amqp "github.com/rabbitmq/amqp091-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	testFactory = observability.NewFactory(
+		observability.WithServiceName("amqpobs-test"),
+		observability.WithApmType("none"),
+		observability.WithMetricsType("none"),
+	)
+	recorder = tracetest.NewSpanRecorder()
+)
+
+func TestMain(m *testing.M) {
+	if _, err := testFactory.Setup(context.Background()); err != nil {
+		panic(err)
+	}
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	os.Exit(m.Run())
+}
+
+func TestAMQPHeadersRoundTrip(t *testing.T) {
+	ctx, span := otel.Tracer("test").Start(context.Background(), "publish")
+	defer span.End()
+	tests := []struct {
+		name    string
+		headers amqp.Table
+	}{
+		{"nil headers", nil},
+		{"existing headers", amqp.Table{"order-id": "1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := InjectAMQPHeaders(ctx, tt.headers)
+			if _, ok := headers["traceparent"]; !ok {
+				t.Fatalf("headers = %v, want a traceparent", headers)
+			}
+			if tt.headers != nil && headers["order-id"] != "1" {
+				t.Errorf("headers = %v, want order-id kept", headers)
+			}
+			got := trace.SpanContextFromContext(ExtractAMQPHeaders(context.Background(), headers))
+			want := span.SpanContext()
+			if got.TraceID() != want.TraceID() || got.SpanID() != want.SpanID() || !got.IsRemote() {
+				t.Errorf("extracted %v, want the remote publishing span %v", got, want)
+			}
+		})
+	}
+}
+
+func TestHeaderCarrierGet(t *testing.T) {
+	// Brokers and other clients may hand the headers back as byte slices.
+	c := HeaderCarrier{"str": "a", "bytes": []byte("b"), "int": int32(1)}
+	tests := []struct {
+		key, want string
+	}{
+		{"str", "a"},
+		{"bytes", "b"},
+		{"int", ""},
+		{"missing", ""},
+	}
+	for _, tt := range tests {
+		if got := c.Get(tt.key); got != tt.want {
+			t.Errorf("Get(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	errHandler := errors.New("handler failed")
+	tests := []struct {
+		name    string
+		err     error
+		wantErr error
+	}{
+		{"handled", nil, nil},
+		{"failed", errHandler, errHandler},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, publish := otel.Tracer("test").Start(context.Background(), "publish")
+			publish.End()
+			d := amqp.Delivery{MessageId: "m1", Headers: InjectAMQPHeaders(ctx, nil)}
+			var handled trace.SpanContext
+			h := Middleware(testFactory, "orders", func(ctx context.Context, obs *observability.Observability, d amqp.Delivery) error {
+				handled = trace.SpanContextFromContext(ctx)
+				return tt.err
+			})
+
+			if err := h(d); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Middleware = %v, want %v", err, tt.wantErr)
+			}
+			// The consumer span starts a new trace linked to the publisher's.
+			if handled.TraceID() == publish.SpanContext().TraceID() {
+				t.Errorf("consumer span in the publisher's trace %s, want a new trace", handled.TraceID())
+			}
+			var consumer sdktrace.ReadOnlySpan
+			for _, s := range recorder.Ended() {
+				if s.SpanContext().SpanID() == handled.SpanID() {
+					consumer = s
+				}
+			}
+			if consumer == nil {
+				t.Fatal("consumer span not ended")
+			}
+			if consumer.Name() != "orders process" {
+				t.Errorf("name = %q, want %q", consumer.Name(), "orders process")
+			}
+			links := consumer.Links()
+			if len(links) != 1 || links[0].SpanContext.SpanID() != publish.SpanContext().SpanID() {
+				t.Errorf("links = %v, want the publishing span", links)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+// Package apierror is the error response schema shared by the example
+// services. Every error response has the same JSON body:
+//
+//	{"code":"NOT_FOUND","message":"Product not found","trace_id":"4bf92f3577b34da6a3ce929d0e0e4736"}
+//
+// code is one of the Code constants, which clients branch on; message is the
+// human readable text; trace_id, when the request was traced with the OTLP
+// APM type, finds the failed request in the APM; details holds extra fields
+// some errors carry.
+//
+// Services mount Middleware once, which turns the plain text errors of
+// http.Error, and so of observability's ErrorHandler.HTTP, into that body.
+// Handlers keep reporting errors as before. Clients read it back with Parse.
+package apierror
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// TraceIDHeader is the response header in which the services send the trace
+// ID of the request, copied to trace_id.
+const TraceIDHeader = "X-Trace-Id"
+
+// Code identifies the kind of an error, independently of its message.
+type Code string
+
+const (
+	InvalidArgument  Code = "INVALID_ARGUMENT"
+	Unauthenticated  Code = "UNAUTHENTICATED"
+	PermissionDenied Code = "PERMISSION_DENIED"
+	NotFound         Code = "NOT_FOUND"
+	MethodNotAllowed Code = "METHOD_NOT_ALLOWED"
+	NotAcceptable    Code = "NOT_ACCEPTABLE"
+	Conflict         Code = "CONFLICT"
+	RateLimited      Code = "RATE_LIMITED"
+	Internal         Code = "INTERNAL"
+	// UpstreamError is a failure of a service the one answering depends on.
+	UpstreamError Code = "UPSTREAM_ERROR"
+	Unavailable   Code = "UNAVAILABLE"
+	// UpstreamTimeout is a service the one answering depends on not answering
+	// in time.
+	UpstreamTimeout Code = "UPSTREAM_TIMEOUT"
+)
+
+// CodeForStatus returns the code of an error answered with status.
+func CodeForStatus(status int) Code {
+	switch status {
+	case http.StatusBadRequest:
+		return InvalidArgument
+	case http.StatusUnauthorized:
+		return Unauthenticated
+	case http.StatusForbidden:
+		return PermissionDenied
+	case http.StatusNotFound:
+		return NotFound
+	case http.StatusMethodNotAllowed:
+		return MethodNotAllowed
+	case http.StatusNotAcceptable:
+		return NotAcceptable
+	case http.StatusConflict:
+		return Conflict
+	case http.StatusTooManyRequests:
+		return RateLimited
+	case http.StatusBadGateway:
+		return UpstreamError
+	case http.StatusServiceUnavailable:
+		return Unavailable
+	case http.StatusGatewayTimeout:
+		return UpstreamTimeout
+	}
+	if status < http.StatusInternalServerError {
+		return InvalidArgument
+	}
+	return Internal
+}
+
+// Response is the body of an error response.
+type Response struct {
+	Code    Code           `json:"code"`
+	Message string         `json:"message"`
+	TraceID string         `json:"trace_id,omitempty"`
+	Details map[string]any `json:"details,omitempty"`
+}
+
+// Write answers with status and resp as the body. An empty resp.Code is set
+// from status, and an empty resp.TraceID from the TraceIDHeader already set
+// on w. Handlers call it for errors with a code other than their status's,
+// or with details; http.Error does for the others.
+func Write(w http.ResponseWriter, status int, resp Response) {
+	if resp.Code == "" {
+		resp.Code = CodeForStatus(status)
+	}
+	if resp.TraceID == "" {
+		resp.TraceID = w.Header().Get(TraceIDHeader)
+	}
+	body, err := json.Marshal(resp)
+	if err != nil {
+		// Only Details can fail to marshal; answer without them.
+		resp.Details = nil
+		body, _ = json.Marshal(resp)
+	}
+	h := w.Header()
+	h.Set("Content-Type", "application/json")
+	h.Del("Content-Length")
+	w.WriteHeader(status)
+	w.Write(append(body, '\n'))
+}
+
+// Middleware answers the errors next writes with http.Error in the shared
+// schema. It recognizes them by their status, 400 or above, and the headers
+// http.Error sets; other responses, JSON errors included, pass through
+// unchanged. Mount it outside the middleware that sets TraceIDHeader, so
+// trace_id can be filled in.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ew := &envelopeWriter{ResponseWriter: w}
+		next.ServeHTTP(ew, r)
+		ew.finish()
+	})
+}
+
+// envelopeWriter holds back an error written with http.Error until the
+// handler returns, and then writes it in the shared schema.
+type envelopeWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	status      int // of the error held back; 0 when passing through
+	msg         bytes.Buffer
+}
+
+func (w *envelopeWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if status >= http.StatusBadRequest && isPlainTextError(w.Header()) {
+			w.status = status
+			return
+		}
+	}
+	if w.status == 0 {
+		w.ResponseWriter.WriteHeader(status)
+	}
+}
+
+func (w *envelopeWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.status != 0 {
+		return w.msg.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *envelopeWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *envelopeWriter) finish() {
+	if w.status == 0 {
+		return
+	}
+	Write(w.ResponseWriter, w.status, Response{Message: strings.TrimSpace(w.msg.String())})
+}
+
+// isPlainTextError reports whether h are the headers of a response written
+// by http.Error.
+func isPlainTextError(h http.Header) bool {
+	return h.Get("Content-Type") == "text/plain; charset=utf-8" && h.Get("X-Content-Type-Options") == "nosniff"
+}
+
+// maxErrorBody bounds how much of an error response Parse reads.
+const maxErrorBody = 64 << 10
+
+// Parse reads the error response resp in the shared schema. It reports false
+// for bodies that are not, such as the errors of a proxy in between, leaving
+// them to be handled by status.
+func Parse(resp *http.Response) (Response, bool) {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		return Response{}, false
+	}
+	var r Response
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxErrorBody)).Decode(&r); err != nil || r.Code == "" {
+		return Response{}, false
+	}
+	return r, true
+}
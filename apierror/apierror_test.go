@@ -0,0 +1,140 @@
+package apierror
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name     string
+		handler  http.HandlerFunc
+		wantCode int
+		// wantBody is the response body, or "" for an error in the shared
+		// schema with wantResp.
+		wantBody string
+		wantResp Response
+	}{
+		{
+			name:     "http.Error",
+			handler:  func(w http.ResponseWriter, r *http.Request) { http.Error(w, "Product not found", http.StatusNotFound) },
+			wantCode: http.StatusNotFound,
+			wantResp: Response{Code: NotFound, Message: "Product not found", TraceID: "abc"},
+		},
+		{
+			name: "http.Error in several writes",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Upstream", http.StatusBadGateway)
+				io.WriteString(w, "failed")
+			},
+			wantCode: http.StatusBadGateway,
+			wantResp: Response{Code: UpstreamError, Message: "Upstream\nfailed", TraceID: "abc"},
+		},
+		{
+			name: "success passes through",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				io.WriteString(w, "ok")
+			},
+			wantCode: http.StatusOK,
+			wantBody: "ok",
+		},
+		{
+			name: "JSON error passes through",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusConflict)
+				io.WriteString(w, `{"error":"taken"}`)
+			},
+			wantCode: http.StatusConflict,
+			wantBody: `{"error":"taken"}`,
+		},
+		{
+			name: "plain text without nosniff passes through",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+				w.WriteHeader(http.StatusBadRequest)
+				io.WriteString(w, "bad")
+			},
+			wantCode: http.StatusBadRequest,
+			wantBody: "bad",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rec.Header().Set(TraceIDHeader, "abc")
+			Middleware(tt.handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			if rec.Code != tt.wantCode {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" {
+				if got := rec.Body.String(); got != tt.wantBody {
+					t.Errorf("body = %q, want %q", got, tt.wantBody)
+				}
+				return
+			}
+			if got := rec.Header().Get("Content-Type"); got != "application/json" {
+				t.Errorf("Content-Type = %q, want application/json", got)
+			}
+			var got Response
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatalf("body %q: %v", rec.Body.String(), err)
+			}
+			if got.Code != tt.wantResp.Code || got.Message != tt.wantResp.Message || got.TraceID != tt.wantResp.TraceID {
+				t.Errorf("response = %+v, want %+v", got, tt.wantResp)
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        Response
+		wantOK      bool
+	}{
+		{"shared schema", "application/json", `{"code":"NOT_FOUND","message":"Product not found","trace_id":"abc"}`, Response{Code: NotFound, Message: "Product not found", TraceID: "abc"}, true},
+		{"media type parameters", "application/json; charset=utf-8", `{"code":"INTERNAL","message":"x"}`, Response{Code: Internal, Message: "x"}, true},
+		{"plain text", "text/plain; charset=utf-8", "Bad Gateway", Response{}, false},
+		{"JSON without code", "application/json", `{"error":"x"}`, Response{}, false},
+		{"invalid JSON", "application/json", `{"code":`, Response{}, false},
+		{"no content type", "", `{"code":"INTERNAL"}`, Response{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{
+				Header: http.Header{"Content-Type": {tt.contentType}},
+				Body:   io.NopCloser(strings.NewReader(tt.body)),
+			}
+			got, ok := Parse(resp)
+			if ok != tt.wantOK || got.Code != tt.want.Code || got.Message != tt.want.Message || got.TraceID != tt.want.TraceID {
+				t.Errorf("Parse = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestCodeForStatus(t *testing.T) {
+	tests := []struct {
+		status int
+		want   Code
+	}{
+		{http.StatusNotFound, NotFound},
+		{http.StatusTooManyRequests, RateLimited},
+		{http.StatusGatewayTimeout, UpstreamTimeout},
+		{http.StatusTeapot, InvalidArgument},
+		{http.StatusNotImplemented, Internal},
+	}
+	for _, tt := range tests {
+		if got := CodeForStatus(tt.status); got != tt.want {
+			t.Errorf("CodeForStatus(%d) = %s, want %s", tt.status, got, tt.want)
+		}
+	}
+}
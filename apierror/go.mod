@@ -0,0 +1,3 @@
+module apierror
+
+go 1.24.2
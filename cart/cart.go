@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"github.com/redis/go-redis/v9"
+)
+
+// cartKeyPrefix is the prefix of the Redis keys of carts, followed by the
+// user ID. Each cart is a hash of quantities by product ID.
+const cartKeyPrefix = "cart:"
+
+func cartKey(userID string) string {
+	return cartKeyPrefix + userID
+}
+
+// CartStore keeps carts in Redis. A cart expires ttl after it was last
+// changed.
+type CartStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+func NewCartStore(client *redis.Client, ttl time.Duration) *CartStore {
+	return &CartStore{client: client, ttl: ttl}
+}
+
+// Add adds quantity of productID to the cart of userID and returns the new
+// quantity of that product in the cart.
+func (s *CartStore) Add(ctx context.Context, userID, productID string, quantity int) (int64, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "CartStore.Add",
+		observability.String("product.id", productID),
+		observability.Int("cart.quantity", quantity),
+	)
+	defer span.End()
+
+	// Both commands go in one round trip, recorded as a single pipeline span.
+	var incr *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.HIncrBy(ctx, cartKey(userID), productID, int64(quantity))
+		pipe.Expire(ctx, cartKey(userID), s.ttl)
+		return nil
+	})
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error adding to cart")
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// Get returns the quantities in the cart of userID, by product ID. An
+// expired or never used cart is empty.
+func (s *CartStore) Get(ctx context.Context, userID string) (map[string]int64, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "CartStore.Get")
+	defer span.End()
+
+	fields, err := s.client.HGetAll(ctx, cartKey(userID)).Result()
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error reading cart")
+		return nil, err
+	}
+	items := make(map[string]int64, len(fields))
+	for productID, quantity := range fields {
+		n, err := strconv.ParseInt(quantity, 10, 64)
+		if err != nil {
+			err = fmt.Errorf("invalid quantity %q of product %s: %w", quantity, productID, err)
+			obs.ErrorHandler.Record(err, "Error reading cart")
+			return nil, err
+		}
+		items[productID] = n
+	}
+	span.SetAttributes(observability.Int("cart.items", len(items)))
+	return items, nil
+}
+
+// Remove removes productID from the cart of userID. It reports whether the
+// product was in the cart.
+func (s *CartStore) Remove(ctx context.Context, userID, productID string) (bool, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "CartStore.Remove",
+		observability.String("product.id", productID),
+	)
+	defer span.End()
+
+	removed, err := s.client.HDel(ctx, cartKey(userID), productID).Result()
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error removing from cart")
+		return false, err
+	}
+	return removed > 0, nil
+}
+
+// watchExpirations records an event for every cart that Redis evicts once
+// its TTL has passed, as a span, an Info log record and a count in
+// cart.expirations. It relies on keyspace notifications, which it enables
+// for expired keys; it logs a warning and returns when they are not
+// available, as on some managed Redis offerings that disallow CONFIG SET.
+// It stops when ctx is canceled.
+func watchExpirations(ctx context.Context, factory *observability.Factory, obs *observability.Observability, client *redis.Client) {
+	expirations, err := obs.Metrics.Counter("cart.expirations")
+	if err != nil {
+		obs.Log.Warn("Cart expirations not recorded", "error", err)
+		return
+	}
+	// "E" enables keyevent notifications, "x" those for expired keys.
+	if err := client.ConfigSet(ctx, "notify-keyspace-events", "Ex").Err(); err != nil {
+		obs.Log.Warn("Cart expirations not recorded, cannot enable keyspace notifications", "error", err)
+		return
+	}
+
+	sub := client.PSubscribe(ctx, "__keyevent@*__:expired")
+	defer sub.Close()
+	// Closing the subscription closes its channel.
+	stop := context.AfterFunc(ctx, func() { sub.Close() })
+	defer stop()
+	for msg := range sub.Channel() {
+		userID, ok := strings.CutPrefix(msg.Payload, cartKeyPrefix)
+		if !ok {
+			continue
+		}
+		// Each expiration gets a trace of its own, since no request caused it.
+		_, expObs, span := factory.NewBackgroundObservability(context.Background()).StartSpanWith("Cart.expired",
+			observability.String("user.id", userID),
+		)
+		expObs.Log.Info("Cart expired", "userID", userID)
+		expirations.Add(expObs.Context(), 1)
+		span.End()
+	}
+	if ctx.Err() != nil {
+		obs.Log.Info("Cart expiration watcher stopping")
+		return
+	}
+	obs.Log.Warn("Expiration subscription closed, no longer recording cart expirations")
+}
@@ -0,0 +1,9 @@
+package main
+
+import "servicekit"
+
+var DefaultPort = "8091"
+
+func main() {
+	servicekit.Run("cart", registerRoutes, servicekit.WithDefaultPort(DefaultPort))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"github.com/redis/go-redis/v9"
+
+	"health"
+	"obsmiddleware"
+	"redisobs"
+	"servicekit"
+)
+
+var (
+	EnvRedisURL     = "REDIS_URL"
+	DefaultRedisURL = "redis://redis:6379/0"
+	EnvCartTTL      = "CART_TTL"
+	DefaultCartTTL  = "30m"
+)
+
+// registerRoutes connects to Redis and returns the cart API.
+func registerRoutes(s *servicekit.Service) http.Handler {
+	redisOpts, err := redis.ParseURL(servicekit.Getenv(EnvRedisURL, DefaultRedisURL))
+	if err != nil {
+		s.Fatal("Invalid Redis URL", "error", err)
+	}
+	cartTTL, err := time.ParseDuration(servicekit.Getenv(EnvCartTTL, DefaultCartTTL))
+	if err != nil {
+		s.Fatal("Invalid cart TTL", "error", err)
+	}
+	redisHook, err := redisobs.NewHook(s.Obs)
+	if err != nil {
+		s.Fatal("Failed to create Redis hook", "error", err)
+	}
+	redisClient := redis.NewClient(redisOpts)
+	s.OnShutdown(func() { redisClient.Close() })
+	redisClient.AddHook(redisHook)
+
+	// Carts cannot be served without Redis.
+	s.Checks.Register("redis", health.CheckerFunc(func(ctx context.Context) error {
+		return redisClient.Ping(ctx).Err()
+	}))
+
+	s.Go(func(ctx context.Context) {
+		watchExpirations(ctx, s.Factory, s.Obs, redisClient)
+	})
+
+	store := NewCartStore(redisClient, cartTTL)
+	s.Obs.Log.Info("Carts expire after inactivity", "ttl", cartTTL.String())
+
+	mux := http.NewServeMux()
+	s.Handle(mux, "GET /cart/{userID}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleGetCart(r.Context(), w, r, observability.ObsFromCtx(r.Context()), store)
+	}))
+	s.Handle(mux, "POST /cart/{userID}/items", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleAddItem(r.Context(), w, r, observability.ObsFromCtx(r.Context()), store)
+	}))
+	s.Handle(mux, "DELETE /cart/{userID}/items/{productID}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleRemoveItem(r.Context(), w, r, observability.ObsFromCtx(r.Context()), store)
+	}))
+
+	return obsmiddleware.WithRoute(mux)
+}
+
+// cartResponse is the body of the answers of the cart endpoints.
+type cartResponse struct {
+	UserID string           `json:"userId"`
+	Items  map[string]int64 `json:"items"`
+}
+
+// addItemRequest is the body of POST /cart/{userID}/items.
+type addItemRequest struct {
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// handleGetCart answers with the cart of the user in the path.
+func handleGetCart(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	store *CartStore) {
+	userID := r.PathValue("userID")
+	ctx = obsmiddleware.ContextWith(ctx, obs, "userID", userID)
+
+	items, err := store.Get(ctx, userID)
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, "Failed to read cart", http.StatusInternalServerError)
+		return
+	}
+
+	obs.Log.Info("Cart fetched", "items", len(items))
+	writeJSON(w, http.StatusOK, cartResponse{UserID: userID, Items: items})
+}
+
+// handleAddItem adds the product in the request body to the cart of the
+// user in the path, and answers with the product's new quantity.
+func handleAddItem(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	store *CartStore) {
+	userID := r.PathValue("userID")
+	ctx = obsmiddleware.ContextWith(ctx, obs, "userID", userID)
+
+	var req addItemRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		obs.ErrorHandler.HTTP(w, "Invalid cart item", http.StatusBadRequest)
+		return
+	}
+	if req.ProductID == "" || req.Quantity <= 0 {
+		obs.ErrorHandler.HTTP(w, "Cart item needs a productId and a positive quantity", http.StatusBadRequest)
+		return
+	}
+
+	quantity, err := store.Add(ctx, userID, req.ProductID, req.Quantity)
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, "Failed to add to cart", http.StatusInternalServerError)
+		return
+	}
+
+	obs.Log.Info("Item added to cart", "productID", req.ProductID, "quantity", quantity)
+	writeJSON(w, http.StatusOK, cartResponse{UserID: userID, Items: map[string]int64{req.ProductID: quantity}})
+}
+
+// handleRemoveItem removes the product in the path from the cart of the
+// user in the path.
+func handleRemoveItem(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	store *CartStore) {
+	userID, productID := r.PathValue("userID"), r.PathValue("productID")
+	ctx = obsmiddleware.ContextWith(ctx, obs, "userID", userID)
+
+	removed, err := store.Remove(ctx, userID, productID)
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, "Failed to remove from cart", http.StatusInternalServerError)
+		return
+	}
+	if !removed {
+		obs.ErrorHandler.HTTP(w, "Product not in cart", http.StatusNotFound)
+		return
+	}
+
+	obs.Log.Info("Item removed from cart", "productID", productID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"clients"
+	"clients/productclient"
+	"clients/userclient"
+	"servicekit"
+)
+
+var (
+	productServiceURL = servicekit.Getenv("PRODUCT_SERVICE_URL", "http://product-service:8086")
+	userServiceURL    = servicekit.Getenv("USER_SERVICE_URL", "http://user-service:8087")
+	paymentServiceURL = servicekit.Getenv("PAYMENT_SERVICE_URL", "http://payment-service:8093")
+)
+
+// Step names, also the values of the saga.step attribute and of the fail
+// query parameter.
+const (
+	stepVerifyUser   = "verify-user"
+	stepReserveStock = "reserve-stock"
+	stepCharge       = "charge"
+	stepConfirm      = "confirm"
+)
+
+// initialStock is the stock every product starts with.
+const initialStock = 100
+
+// unitPrice is the price of every product, in cents.
+const unitPrice = 1999
+
+var (
+	// ErrOutOfStock is returned when a product has less stock than ordered.
+	ErrOutOfStock = errors.New("not enough stock")
+	// ErrInjectedFailure is returned by the step named in the fail query
+	// parameter, to demo compensation.
+	ErrInjectedFailure = errors.New("injected failure")
+)
+
+// paymentSender sends the calls to the payment service in a single attempt:
+// a charge whose answer was lost may have gone through, and retrying it
+// could charge the user twice.
+var paymentSender = clients.NewRetrier(&http.Client{Timeout: 5 * time.Second}, 1, 0)
+
+// checkoutRequest is the body of POST /checkout.
+type checkoutRequest struct {
+	UserID    string `json:"userId"`
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// Checkout runs a checkout saga for every order: verify the user with the
+// user service, reserve stock for a product the product service knows,
+// charge the payment with the payment service, and confirm the order.
+type Checkout struct {
+	factory  *observability.Factory
+	users    *userclient.Client
+	products *productclient.Client
+	stock    *stockLedger
+	payments *paymentClient
+	nextID   atomic.Uint64
+}
+
+func NewCheckout(factory *observability.Factory) *Checkout {
+	return &Checkout{
+		factory:  factory,
+		users:    userclient.New(userServiceURL),
+		products: productclient.New(productServiceURL),
+		stock:    newStockLedger(),
+		payments: newPaymentClient(),
+	}
+}
+
+// Run runs the checkout saga for req and returns the order ID. failStep, if
+// not empty, names a step that fails without doing its work.
+func (c *Checkout) Run(ctx context.Context, obs *observability.Observability, req checkoutRequest, failStep string) (string, error) {
+	orderID := "order-" + strconv.FormatUint(c.nextID.Add(1), 10)
+	s := &saga{
+		id:      orderID,
+		name:    "Checkout.saga",
+		factory: c.factory,
+		steps: []sagaStep{
+			{
+				name: stepVerifyUser,
+				do: func(ctx context.Context, obs *observability.Observability) error {
+					_, err := c.users.GetUser(ctx, obs, req.UserID)
+					return err
+				},
+			},
+			{
+				name: stepReserveStock,
+				do: func(ctx context.Context, obs *observability.Observability) error {
+					if _, err := c.products.GetProduct(ctx, obs, req.ProductID); err != nil {
+						return err
+					}
+					return c.stock.Reserve(orderID, req.ProductID, req.Quantity)
+				},
+				compensate: func(ctx context.Context, obs *observability.Observability) error {
+					c.stock.Release(orderID)
+					return nil
+				},
+			},
+			{
+				name: stepCharge,
+				do: func(ctx context.Context, obs *observability.Observability) error {
+					return c.payments.Charge(ctx, obs, orderID, req.UserID, int64(req.Quantity)*unitPrice)
+				},
+				compensate: func(ctx context.Context, obs *observability.Observability) error {
+					return c.payments.Refund(ctx, obs, orderID)
+				},
+			},
+			{
+				name: stepConfirm,
+				do: func(ctx context.Context, obs *observability.Observability) error {
+					// Nothing can be undone anymore.
+					c.stock.Commit(orderID)
+					c.payments.Settle(orderID)
+					obs.Log.Info("Order confirmed", "orderID", orderID)
+					return nil
+				},
+			},
+		},
+	}
+	if failStep != "" {
+		for i, step := range s.steps {
+			if step.name == failStep {
+				s.steps[i].do = func(context.Context, *observability.Observability) error {
+					return fmt.Errorf("%w in step %s", ErrInjectedFailure, step.name)
+				}
+			}
+		}
+	}
+	return orderID, s.Run(ctx, obs)
+}
+
+// stockLedger tracks the stock of products and the reservations held
+// against it, in memory.
+type stockLedger struct {
+	mu           sync.Mutex
+	available    map[string]int
+	reservations map[string]reservation // by order ID
+}
+
+type reservation struct {
+	productID string
+	quantity  int
+}
+
+func newStockLedger() *stockLedger {
+	return &stockLedger{available: map[string]int{}, reservations: map[string]reservation{}}
+}
+
+// Reserve holds quantity of productID for orderID.
+func (l *stockLedger) Reserve(orderID, productID string, quantity int) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	available, ok := l.available[productID]
+	if !ok {
+		available = initialStock
+	}
+	if quantity > available {
+		return fmt.Errorf("%w: %d of product %s available, %d ordered", ErrOutOfStock, available, productID, quantity)
+	}
+	l.available[productID] = available - quantity
+	l.reservations[orderID] = reservation{productID: productID, quantity: quantity}
+	return nil
+}
+
+// Release returns the stock held for orderID.
+func (l *stockLedger) Release(orderID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if r, ok := l.reservations[orderID]; ok {
+		l.available[r.productID] += r.quantity
+		delete(l.reservations, orderID)
+	}
+}
+
+// Commit drops the reservation of orderID, whose stock is no longer held but
+// sold.
+func (l *stockLedger) Commit(orderID string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.reservations, orderID)
+}
+
+// paymentClient charges and refunds orders with the payment service.
+type paymentClient struct {
+	mu sync.Mutex
+	// charges holds the charge ID by order ID, until the order is confirmed
+	// or refunded. A charge whose refund failed stays, for manual repair.
+	charges map[string]string
+}
+
+func newPaymentClient() *paymentClient {
+	return &paymentClient{charges: map[string]string{}}
+}
+
+// Charge charges amount, in cents, to userID for orderID.
+func (c *paymentClient) Charge(ctx context.Context, obs *observability.Observability, orderID, userID string, amount int64) error {
+	var resp struct {
+		ChargeID string `json:"chargeId"`
+	}
+	err := clients.PostJSON(ctx, obs, paymentSender, "payment", paymentServiceURL+"/charges", map[string]any{
+		"orderId": orderID,
+		"userId":  userID,
+		"amount":  amount,
+	}, &resp)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.charges[orderID] = resp.ChargeID
+	return nil
+}
+
+// Refund refunds the charge of orderID.
+func (c *paymentClient) Refund(ctx context.Context, obs *observability.Observability, orderID string) error {
+	c.mu.Lock()
+	chargeID, ok := c.charges[orderID]
+	c.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("no charge for order %s", orderID)
+	}
+	if err := clients.PostJSON(ctx, obs, paymentSender, "payment", paymentServiceURL+"/charges/"+url.PathEscape(chargeID)+"/refund", nil, nil); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.charges, orderID)
+	return nil
+}
+
+// Settle forgets the charge of orderID, which can no longer be refunded by
+// the saga.
+func (c *paymentClient) Settle(orderID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.charges, orderID)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStockLedgerDropsFinishedReservations(t *testing.T) {
+	tests := []struct {
+		name          string
+		finish        func(l *stockLedger, orderID string)
+		wantAvailable int
+	}{
+		{"released", (*stockLedger).Release, initialStock},
+		{"committed", (*stockLedger).Commit, initialStock - 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newStockLedger()
+			if err := l.Reserve("order-1", "p1", 3); err != nil {
+				t.Fatalf("Reserve = %v", err)
+			}
+			tt.finish(l, "order-1")
+			if len(l.reservations) != 0 {
+				t.Errorf("reservations = %v, want none", l.reservations)
+			}
+			if got := l.available["p1"]; got != tt.wantAvailable {
+				t.Errorf("available = %d, want %d", got, tt.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestStockLedgerReserveOutOfStock(t *testing.T) {
+	l := newStockLedger()
+	if err := l.Reserve("order-1", "p1", initialStock+1); !errors.Is(err, ErrOutOfStock) {
+		t.Errorf("Reserve = %v, want %v", err, ErrOutOfStock)
+	}
+	if len(l.reservations) != 0 {
+		t.Errorf("reservations = %v, want none", l.reservations)
+	}
+}
+
+func TestPaymentClientSettle(t *testing.T) {
+	c := newPaymentClient()
+	c.charges["order-1"] = "charge-1"
+	c.Settle("order-1")
+	if len(c.charges) != 0 {
+		t.Errorf("charges = %v, want none", c.charges)
+	}
+}
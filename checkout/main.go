@@ -0,0 +1,9 @@
+package main
+
+import "servicekit"
+
+var DefaultPort = "8090"
+
+func main() {
+	servicekit.Run("checkout", registerRoutes, servicekit.WithDefaultPort(DefaultPort))
+}
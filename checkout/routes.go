@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/app-obs/go/observability"
+
+	"clients"
+	"health"
+	"obsmiddleware"
+	"servicekit"
+)
+
+// registerRoutes sets the checkout saga up and returns the checkout API.
+func registerRoutes(s *servicekit.Service) http.Handler {
+	// Checkouts cannot run without the user, product and payment services.
+	s.Checks.Register("user", health.HTTPChecker(userServiceURL, s.DependencyCheckTTL, s.DependencyCheckTimeout))
+	s.Checks.Register("product", health.HTTPChecker(productServiceURL, s.DependencyCheckTTL, s.DependencyCheckTimeout))
+	s.Checks.Register("payment", health.HTTPChecker(paymentServiceURL, s.DependencyCheckTTL, s.DependencyCheckTimeout))
+
+	checkout := NewCheckout(s.Factory)
+
+	mux := http.NewServeMux()
+	s.Handle(mux, "POST /checkout", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCheckout(r.Context(), w, r, observability.ObsFromCtx(r.Context()), checkout)
+	}))
+
+	// The caller's bearer token is forwarded to the user service, which
+	// requires one when JWT_SECRET is set.
+	return clients.ForwardAuthorization(obsmiddleware.WithRoute(mux))
+}
+
+// checkoutResponse is the body of a successful POST /checkout.
+type checkoutResponse struct {
+	OrderID string `json:"orderId"`
+	State   string `json:"state"`
+}
+
+// handleCheckout runs the checkout saga for the order in the request body.
+// The fail query parameter names a step to fail, to demo compensation.
+func handleCheckout(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	checkout *Checkout) {
+	var req checkoutRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		obs.ErrorHandler.HTTP(w, "Invalid checkout", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" || req.ProductID == "" || req.Quantity <= 0 {
+		obs.ErrorHandler.HTTP(w, "Checkout needs a userId, a productId and a positive quantity", http.StatusBadRequest)
+		return
+	}
+	failStep := r.URL.Query().Get("fail")
+	if failStep != "" && !slices.Contains([]string{stepVerifyUser, stepReserveStock, stepCharge, stepConfirm}, failStep) {
+		obs.ErrorHandler.HTTP(w, "Unknown step to fail", http.StatusBadRequest)
+		return
+	}
+
+	orderID, err := checkout.Run(ctx, obs, req, failStep)
+	if err != nil {
+		var se *clients.StatusError
+		switch {
+		case errors.Is(err, ErrOutOfStock):
+			obs.ErrorHandler.HTTP(w, "Not enough stock", http.StatusConflict)
+		case errors.Is(err, clients.ErrNotFound) && errors.As(err, &se):
+			obs.ErrorHandler.HTTP(w, "Unknown "+se.Service, http.StatusUnprocessableEntity)
+		default:
+			obs.ErrorHandler.HTTP(w, "Checkout failed", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	obs.Log.Info("Checkout completed", "orderID", orderID)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(checkoutResponse{OrderID: orderID, State: sagaCompleted})
+}
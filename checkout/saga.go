@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// Saga states, recorded as saga.state on the saga span.
+const (
+	sagaCompleted = "completed"
+	// sagaCompensated means a step failed and every completed step was undone.
+	sagaCompensated = "compensated"
+	// sagaCompensationFailed means a step failed and at least one completed
+	// step could not be undone, which needs manual repair.
+	sagaCompensationFailed = "compensation_failed"
+)
+
+// compensationTimeout bounds the compensation of a failed saga, which runs
+// even after the request that started the saga is canceled.
+const compensationTimeout = 10 * time.Second
+
+// sagaStep is one step of a saga. compensate undoes a completed do; it is
+// nil for steps with nothing to undo.
+type sagaStep struct {
+	name       string
+	do         func(ctx context.Context, obs *observability.Observability) error
+	compensate func(ctx context.Context, obs *observability.Observability) error
+}
+
+// sagaError is returned by saga.Run when a step fails.
+type sagaError struct {
+	step  string
+	state string
+	err   error
+}
+
+func (e *sagaError) Error() string {
+	return fmt.Sprintf("saga step %s failed (%s): %v", e.step, e.state, e.err)
+}
+
+func (e *sagaError) Unwrap() error { return e.err }
+
+// saga runs steps in order. When a step fails, the steps completed before it
+// are compensated in reverse order. Every step and compensation runs in its
+// own child span of a span for the whole saga, so the trace shows how far the
+// saga got and what was undone.
+type saga struct {
+	id    string
+	name  string
+	steps []sagaStep
+	// factory creates the observability instance compensation runs with,
+	// detached from the cancellation of the request.
+	factory *observability.Factory
+}
+
+// Run runs the saga and returns a *sagaError if a step failed.
+func (s *saga) Run(ctx context.Context, obs *observability.Observability) error {
+	ctx, obs, span := obs.StartSpanWith(s.name,
+		observability.String("saga.id", s.id),
+		observability.Int("saga.step_count", len(s.steps)),
+	)
+	defer span.End()
+
+	for i, step := range s.steps {
+		if err := s.runStep(ctx, obs, i, step); err != nil {
+			span.SetAttributes(observability.String("saga.failed_step", step.name))
+			span.AddEvent("saga.compensating")
+			obs.Log.Warn("Saga step failed, compensating", "sagaID", s.id, "step", step.name, "error", err)
+
+			// A step often fails because the request was canceled or timed
+			// out, which must not stop the completed steps from being undone.
+			compCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), compensationTimeout)
+			state := s.compensate(compCtx, s.factory.NewBackgroundObservability(compCtx), s.steps[:i])
+			cancel()
+			span.SetAttributes(
+				observability.String("saga.state", state),
+				observability.Int("saga.completed_steps", i),
+			)
+			span.SetStatus(codes.Error, "saga "+state)
+			return &sagaError{step: step.name, state: state, err: err}
+		}
+	}
+
+	span.SetAttributes(
+		observability.String("saga.state", sagaCompleted),
+		observability.Int("saga.completed_steps", len(s.steps)),
+	)
+	obs.Log.Info("Saga completed", "sagaID", s.id)
+	return nil
+}
+
+// runStep runs step i under a child span.
+func (s *saga) runStep(ctx context.Context, obs *observability.Observability, i int, step sagaStep) error {
+	ctx, obs, span := obs.StartSpanWith("Saga."+step.name,
+		observability.String("saga.id", s.id),
+		observability.String("saga.step", step.name),
+		observability.Int("saga.step_index", i),
+	)
+	defer span.End()
+
+	if err := step.do(ctx, obs); err != nil {
+		obs.ErrorHandler.Record(err, "Saga step failed")
+		return err
+	}
+	return nil
+}
+
+// compensate undoes completed, in reverse order, and returns the final saga
+// state. A failed compensation does not stop the others.
+func (s *saga) compensate(ctx context.Context, obs *observability.Observability, completed []sagaStep) string {
+	state := sagaCompensated
+	for i := len(completed) - 1; i >= 0; i-- {
+		step := completed[i]
+		if step.compensate == nil {
+			continue
+		}
+		if err := s.compensateStep(ctx, obs, i, step); err != nil {
+			state = sagaCompensationFailed
+		}
+	}
+	return state
+}
+
+// compensateStep undoes step i under a child span.
+func (s *saga) compensateStep(ctx context.Context, obs *observability.Observability, i int, step sagaStep) error {
+	ctx, obs, span := obs.StartSpanWith("Saga.compensate."+step.name,
+		observability.String("saga.id", s.id),
+		observability.String("saga.step", step.name),
+		observability.Int("saga.step_index", i),
+		observability.Bool("saga.compensation", true),
+	)
+	defer span.End()
+
+	if err := step.compensate(ctx, obs); err != nil {
+		obs.ErrorHandler.Record(err, "Saga compensation failed")
+		return err
+	}
+	obs.Log.Info("Saga step compensated", "sagaID", s.id, "step", step.name)
+	return nil
+}
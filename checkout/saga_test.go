@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/app-obs/go/observability"
+)
+
+var testFactory = observability.NewFactory(
+	observability.WithServiceName("checkout-test"),
+	observability.WithApmType("none"),
+	observability.WithMetricsType("none"),
+)
+
+func TestMain(m *testing.M) {
+	if _, err := testFactory.Setup(context.Background()); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestSagaRun(t *testing.T) {
+	errStep := errors.New("step failed")
+	errUndo := errors.New("undo failed")
+	tests := []struct {
+		name string
+		// failAt is the index of the step that fails, or -1.
+		failAt int
+		// undoFails names the steps whose compensation fails.
+		undoFails []string
+		wantState string
+		wantUndo  []string
+	}{
+		{"completed", -1, nil, "", nil},
+		{"first step fails", 0, nil, sagaCompensated, nil},
+		{"compensated in reverse", 3, nil, sagaCompensated, []string{"c", "a"}},
+		{"compensation failure", 3, []string{"c"}, sagaCompensationFailed, []string{"c", "a"}},
+		{"failed compensation does not stop the others", 3, []string{"a", "c"}, sagaCompensationFailed, []string{"c", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var undone []string
+			step := func(i int, name string, compensable bool) sagaStep {
+				st := sagaStep{
+					name: name,
+					do: func(context.Context, *observability.Observability) error {
+						if i == tt.failAt {
+							return errStep
+						}
+						return nil
+					},
+				}
+				if compensable {
+					st.compensate = func(context.Context, *observability.Observability) error {
+						undone = append(undone, name)
+						if slices.Contains(tt.undoFails, name) {
+							return errUndo
+						}
+						return nil
+					}
+				}
+				return st
+			}
+			s := &saga{
+				id:      "order-1",
+				name:    "Test.saga",
+				factory: testFactory,
+				// b has nothing to undo.
+				steps: []sagaStep{step(0, "a", true), step(1, "b", false), step(2, "c", true), step(3, "d", true)},
+			}
+
+			err := s.Run(context.Background(), testFactory.NewBackgroundObservability(context.Background()))
+
+			if tt.wantState == "" {
+				if err != nil {
+					t.Fatalf("Run = %v, want nil", err)
+				}
+			} else {
+				var se *sagaError
+				if !errors.As(err, &se) {
+					t.Fatalf("Run = %v, want a *sagaError", err)
+				}
+				if se.state != tt.wantState || se.step != s.steps[tt.failAt].name {
+					t.Errorf("Run failed in step %s (%s), want %s (%s)", se.step, se.state, s.steps[tt.failAt].name, tt.wantState)
+				}
+				if !errors.Is(err, errStep) {
+					t.Errorf("Run = %v, want it to wrap %v", err, errStep)
+				}
+			}
+			if !slices.Equal(undone, tt.wantUndo) {
+				t.Errorf("compensated %v, want %v", undone, tt.wantUndo)
+			}
+		})
+	}
+}
+
+func TestSagaCompensatesAfterCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var compensateErr error
+	var hasDeadline bool
+	s := &saga{
+		id:      "order-1",
+		name:    "Test.saga",
+		factory: testFactory,
+		steps: []sagaStep{
+			{
+				name: "reserve",
+				do:   func(context.Context, *observability.Observability) error { return nil },
+				compensate: func(ctx context.Context, obs *observability.Observability) error {
+					compensateErr = ctx.Err()
+					_, hasDeadline = ctx.Deadline()
+					if obs.Context().Err() != nil {
+						t.Error("compensation observability context is canceled")
+					}
+					return nil
+				},
+			},
+			{
+				name: "charge",
+				do: func(ctx context.Context, _ *observability.Observability) error {
+					cancel()
+					return ctx.Err()
+				},
+			},
+		},
+	}
+
+	err := s.Run(ctx, testFactory.NewBackgroundObservability(ctx))
+
+	var se *sagaError
+	if !errors.As(err, &se) || se.state != sagaCompensated {
+		t.Fatalf("Run = %v, want a compensated saga", err)
+	}
+	if compensateErr != nil {
+		t.Errorf("compensation context error = %v, want nil", compensateErr)
+	}
+	if !hasDeadline {
+		t.Error("compensation context has no deadline")
+	}
+}
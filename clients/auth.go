@@ -0,0 +1,48 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+)
+
+// authorizationKey is a private type to prevent collisions with other packages.
+type authorizationKey struct{}
+
+// WithAuthorization returns ctx carrying authorization, the value of the
+// Authorization header of the caller's request, which the clients forward
+// with every request sent with ctx. A service that requires a bearer token,
+// such as the user service with JWT_SECRET set, then sees the token of the
+// end user on whose behalf it is called.
+func WithAuthorization(ctx context.Context, authorization string) context.Context {
+	if authorization == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, authorizationKey{}, authorization)
+}
+
+// ForwardAuthorization makes the Authorization header of each request
+// available to the clients, as WithAuthorization does.
+func ForwardAuthorization(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if authorization := r.Header.Get("Authorization"); authorization != "" {
+			r = r.WithContext(WithAuthorization(r.Context(), authorization))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// SetAuthorization sets the Authorization header of req to the one ctx
+// carries, if any. The clients call it for every request; services that
+// build their requests themselves call it too.
+func SetAuthorization(ctx context.Context, req *http.Request) {
+	if authorization, ok := Authorization(ctx); ok {
+		req.Header.Set("Authorization", authorization)
+	}
+}
+
+// Authorization returns the Authorization header value ctx carries, if any,
+// for callers that forward it over another protocol, such as gRPC metadata.
+func Authorization(ctx context.Context) (string, bool) {
+	authorization, ok := ctx.Value(authorizationKey{}).(string)
+	return authorization, ok
+}
@@ -0,0 +1,46 @@
+package clients
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardAuthorization(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming string
+		want     string
+	}{
+		{"forwarded", "Bearer abc", "Bearer abc"},
+		{"none", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := ForwardAuthorization(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				out := httptest.NewRequest("GET", "http://user-service/user?id=1", nil)
+				SetAuthorization(r.Context(), out)
+				got = out.Header.Get("Authorization")
+			}))
+			in := httptest.NewRequest("GET", "/product-detail", nil)
+			if tt.incoming != "" {
+				in.Header.Set("Authorization", tt.incoming)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), in)
+			if got != tt.want {
+				t.Errorf("forwarded Authorization = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetAuthorizationKeepsHeaderWithoutCaller(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://user-service/user?id=1", nil)
+	req.Header.Set("Authorization", "Bearer service")
+	SetAuthorization(WithAuthorization(context.Background(), ""), req)
+	if got := req.Header.Get("Authorization"); got != "Bearer service" {
+		t.Errorf("Authorization = %q, want it unchanged", got)
+	}
+}
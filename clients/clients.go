@@ -0,0 +1,246 @@
+// Package clients holds what the typed clients of the example services, such
+// as productclient and userclient, have in common: sending a request with the
+// caller's trace context, retrying it when the service is briefly unavailable,
+// and turning error responses into errors callers can match with errors.Is.
+//
+// The clients forward the caller's Authorization header, carried by the
+// context through WithAuthorization or ForwardAuthorization, so the called
+// service can check the end user's bearer token.
+//
+// The clients start no spans of their own. Callers pass the Observability of
+// their current span, whose trace context is injected into every request, so
+// the called service continues the caller's trace.
+package clients
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"apierror"
+)
+
+// Errors matched by the StatusError of a call with errors.Is, by the code of
+// the error response.
+var (
+	// ErrNotFound is matched by NOT_FOUND.
+	ErrNotFound = errors.New("not found")
+	// ErrInvalidRequest is matched by INVALID_ARGUMENT.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrUnavailable is matched by UNAVAILABLE and UPSTREAM_ERROR: the
+	// service, or one it depends on, failed.
+	ErrUnavailable = errors.New("service unavailable")
+	// ErrUpstreamTimeout is matched by UPSTREAM_TIMEOUT: a service the called
+	// one depends on did not answer in time.
+	ErrUpstreamTimeout = errors.New("upstream timeout")
+)
+
+// StatusError is returned when a service answers with a status other than
+// 200 OK. It holds the error response the service sent, in the schema of
+// apierror; for responses that are not, Code is derived from StatusCode and
+// Message is empty.
+type StatusError struct {
+	Service    string
+	StatusCode int
+	apierror.Response
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s service returned status %d", e.Service, e.StatusCode)
+	}
+	return fmt.Sprintf("%s service returned status %d: %s: %s", e.Service, e.StatusCode, e.Code, e.Message)
+}
+
+// Is reports whether target is the error of e's code, such as ErrNotFound for
+// NOT_FOUND.
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == apierror.NotFound
+	case ErrInvalidRequest:
+		return e.Code == apierror.InvalidArgument
+	case ErrUnavailable:
+		return e.Code == apierror.Unavailable || e.Code == apierror.UpstreamError
+	case ErrUpstreamTimeout:
+		return e.Code == apierror.UpstreamTimeout
+	}
+	return false
+}
+
+// newStatusError reads the error response resp of service.
+func newStatusError(service string, resp *http.Response) *StatusError {
+	e := &StatusError{Service: service, StatusCode: resp.StatusCode}
+	if r, ok := apierror.Parse(resp); ok {
+		e.Response = r
+	} else {
+		e.Code = apierror.CodeForStatus(resp.StatusCode)
+	}
+	return e
+}
+
+// retryable reports whether a call answered with status is worth another
+// attempt.
+func retryable(status int) bool {
+	switch status {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// RequestFunc builds the request of one attempt at a call. The trace context
+// is injected from obs, so the request is sent on behalf of the span obs
+// belongs to.
+type RequestFunc func(ctx context.Context, obs *observability.Observability) (*http.Request, error)
+
+// Sender sends the request of a call, built by newRequest once per attempt.
+// It returns the response of the last attempt, whatever its status.
+//
+// Services with their own retry policy, such as one that also hedges slow
+// calls, pass it to the clients as a Sender.
+type Sender interface {
+	Send(ctx context.Context, obs *observability.Observability, newRequest RequestFunc) (*http.Response, error)
+}
+
+// Retrier is the Sender the clients use by default. It sends a new request
+// while an attempt fails to connect or is answered with 502, 503 or 504, up
+// to maxAttempts in all. The delay before attempt n+1 is drawn at random
+// between half and all of backoff*2^(n-1), so that clients retrying at once
+// do not hit a recovering service in lockstep.
+type Retrier struct {
+	client      *http.Client
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewRetrier returns a Retrier sending requests with client. maxAttempts
+// counts the first attempt, so 1 disables retries.
+func NewRetrier(client *http.Client, maxAttempts int, backoff time.Duration) *Retrier {
+	return &Retrier{client: client, maxAttempts: max(maxAttempts, 1), backoff: backoff}
+}
+
+// DefaultSender is used by the clients unless they are given another Sender.
+// It makes up to 3 attempts, each within 5 seconds.
+var DefaultSender Sender = NewRetrier(&http.Client{Timeout: 5 * time.Second}, 3, 100*time.Millisecond)
+
+// Send sends the request built by newRequest, and new ones while attempts
+// fail in a retryable way and remain.
+func (r *Retrier) Send(ctx context.Context, obs *observability.Observability, newRequest RequestFunc) (*http.Response, error) {
+	delay := r.backoff
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest(ctx, obs)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := r.client.Do(req)
+		var again bool
+		if err != nil {
+			// A canceled or expired request is not worth another attempt.
+			again = ctx.Err() == nil
+		} else {
+			again = retryable(resp.StatusCode)
+		}
+		if !again || attempt >= r.maxAttempts {
+			return resp, err
+		}
+		if resp != nil {
+			// Drain the body so the connection can be reused.
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		wait := time.Duration(0)
+		if delay > 0 {
+			wait = delay/2 + rand.N(delay/2+1)
+		}
+		delay *= 2
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, context.Cause(ctx)
+		}
+	}
+}
+
+// GetJSON gets url from service with sender, asking for JSON, and decodes
+// the answer into v. The trace context of obs is injected into each attempt,
+// along with the caller's Authorization header that ctx carries, if any.
+// A status other than 200 OK is returned as a *StatusError holding the
+// error response.
+func GetJSON(ctx context.Context, obs *observability.Observability, sender Sender, service, url string, v any) error {
+	resp, err := sender.Send(ctx, obs, func(ctx context.Context, obs *observability.Observability) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		SetAuthorization(ctx, req)
+		obs.Trace.InjectHTTP(req)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return newStatusError(service, resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("decode %s service response: %w", service, err)
+	}
+	return nil
+}
+
+// PostJSON posts body as JSON to url on service with sender, and decodes the
+// answer into out, unless out is nil. Like GetJSON, it injects the trace
+// context of obs and the caller's Authorization header into each attempt. A
+// status other than 2xx is returned as a *StatusError holding the error
+// response.
+//
+// A POST that is not idempotent, such as a payment, must not be sent again
+// after an attempt that may have reached the service: pass a sender that
+// makes a single attempt, such as NewRetrier(client, 1, 0).
+func PostJSON(ctx context.Context, obs *observability.Observability, sender Sender, service, url string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	resp, err := sender.Send(ctx, obs, func(ctx context.Context, obs *observability.Observability) (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept", "application/json")
+		SetAuthorization(ctx, req)
+		obs.Trace.InjectHTTP(req)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		return newStatusError(service, resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s service response: %w", service, err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+// Package productclient is a typed client of the product service's HTTP API.
+//
+//	products := productclient.New("http://product-service:8086")
+//	p, err := products.GetProduct(ctx, obs, "123")
+//	if errors.Is(err, clients.ErrNotFound) {
+//		// No product with that ID.
+//	}
+package productclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+
+	"clients"
+)
+
+// Product is a product as the product service returns it.
+type Product struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Price int64  `json:"price"` // in cents
+	Stock int    `json:"stock"`
+}
+
+// String returns the plain text form of p, the same the product service
+// serves to clients that do not ask for JSON.
+func (p Product) String() string {
+	return fmt.Sprintf("%s with ID %s", p.Name, p.ID)
+}
+
+// Review is a review of a product.
+type Review struct {
+	UserID  string `json:"userId"`
+	Rating  int    `json:"rating"` // 1 to 5
+	Comment string `json:"comment"`
+}
+
+// Reviews is the reviews of a product as the product service returns them.
+type Reviews struct {
+	ProductID     string   `json:"productId"`
+	Count         int      `json:"count"`
+	AverageRating float64  `json:"averageRating"`
+	Reviews       []Review `json:"reviews"`
+}
+
+// String returns the plain text form of r, the same the product service
+// serves to clients that do not ask for JSON: the average rating, then one
+// review per line.
+func (r Reviews) String() string {
+	if r.Count == 0 {
+		return "No reviews yet\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%.1f/5 from %d reviews\n", r.AverageRating, r.Count)
+	for _, rv := range r.Reviews {
+		fmt.Fprintf(&b, "%d/5 by user %s: %s\n", rv.Rating, rv.UserID, rv.Comment)
+	}
+	return b.String()
+}
+
+// Client calls the product service at a base URL.
+type Client struct {
+	baseURL string
+	sender  clients.Sender
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithSender sends the client's requests with sender instead of
+// clients.DefaultSender.
+func WithSender(sender clients.Sender) Option {
+	return func(c *Client) {
+		c.sender = sender
+	}
+}
+
+// New returns a client of the product service at baseURL, such as
+// http://product-service:8086.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/"), sender: clients.DefaultSender}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GetProduct returns the product with id, on behalf of the span of obs. A
+// product that does not exist is a *clients.StatusError matching
+// clients.ErrNotFound.
+func (c *Client) GetProduct(ctx context.Context, obs *observability.Observability, id string) (Product, error) {
+	var p Product
+	err := clients.GetJSON(ctx, obs, c.sender, "product", c.baseURL+"/product?"+url.Values{"id": {id}}.Encode(), &p)
+	return p, err
+}
+
+// GetProducts returns the products with ids, at most 100, in one call, on
+// behalf of the span of obs. The products come in the order of ids; the IDs
+// of products that do not exist are left out.
+func (c *Client) GetProducts(ctx context.Context, obs *observability.Observability, ids []string) ([]Product, error) {
+	var products []Product
+	err := clients.GetJSON(ctx, obs, c.sender, "product", c.baseURL+"/products?"+url.Values{"ids": {strings.Join(ids, ",")}}.Encode(), &products)
+	return products, err
+}
+
+// GetReviews returns the reviews of the product with id, on behalf of the
+// span of obs. A product without reviews has a zero Count.
+func (c *Client) GetReviews(ctx context.Context, obs *observability.Observability, id string) (Reviews, error) {
+	var r Reviews
+	err := clients.GetJSON(ctx, obs, c.sender, "product", c.baseURL+"/products/"+url.PathEscape(id)+"/reviews", &r)
+	return r, err
+}
@@ -0,0 +1,64 @@
+// Package userclient is a typed client of the user service's HTTP API.
+//
+//	users := userclient.New("http://user-service:8087")
+//	u, err := users.GetUser(ctx, obs, "user123")
+package userclient
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+
+	"clients"
+)
+
+// User is a user as the user service returns it.
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// String returns the plain text form of u, the same the user service serves
+// to clients that do not ask for JSON. It leaves the email out.
+func (u User) String() string {
+	return fmt.Sprintf("%s with ID %s", u.Name, u.ID)
+}
+
+// Client calls the user service at a base URL.
+type Client struct {
+	baseURL string
+	sender  clients.Sender
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithSender sends the client's requests with sender instead of
+// clients.DefaultSender.
+func WithSender(sender clients.Sender) Option {
+	return func(c *Client) {
+		c.sender = sender
+	}
+}
+
+// New returns a client of the user service at baseURL, such as
+// http://user-service:8087.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/"), sender: clients.DefaultSender}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// GetUser returns the user with id, on behalf of the span of obs. A user that
+// does not exist is a *clients.StatusError matching clients.ErrNotFound.
+func (c *Client) GetUser(ctx context.Context, obs *observability.Observability, id string) (User, error) {
+	var u User
+	err := clients.GetJSON(ctx, obs, c.sender, "user", c.baseURL+"/user?"+url.Values{"id": {id}}.Encode(), &u)
+	return u, err
+}
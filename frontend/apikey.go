@@ -0,0 +1,57 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"ratelimit"
+)
+
+var EnvAPIKeys = "FRONTEND_API_KEYS"
+
+// apiKeys is the set of API keys the frontend knows. Only the SHA-256 of each
+// key is kept, so the keys cannot leak from the process's memory into a dump
+// or a profile. Quotas and rate limits are only kept per known key: a client
+// that makes keys up must not get a fresh limit with each one. A nil
+// *apiKeys knows no key.
+type apiKeys struct {
+	keys map[[sha256.Size]byte]bool
+}
+
+// newAPIKeys returns the comma-separated keys of FRONTEND_API_KEYS, or nil if
+// there are none.
+func newAPIKeys(value string) *apiKeys {
+	keys := make(map[[sha256.Size]byte]bool)
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys[sha256.Sum256([]byte(key))] = true
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return &apiKeys{keys: keys}
+}
+
+// lookup returns the ID of the key r carries in APIKeyHeader, a short hash
+// of it that can be recorded, and whether the key is known. The ID is empty
+// if r carries no key.
+func (k *apiKeys) lookup(r *http.Request) (id string, known bool) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return "", false
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6]), k != nil && k.keys[sum]
+}
+
+// rateLimitKey identifies clients by their API key if it is known, and by IP
+// address otherwise.
+func (k *apiKeys) rateLimitKey(r *http.Request) (key, kind string) {
+	if id, known := k.lookup(r); known {
+		return id, "api_key"
+	}
+	return ratelimit.ByIP(r)
+}
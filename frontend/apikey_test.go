@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAPIKeysRateLimitKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		keys     string
+		header   string
+		wantKey  string
+		wantKind string
+	}{
+		{"known key", "loadgen, partner", "partner", "7f3fa48ca885", "api_key"},
+		{"unknown key", "loadgen", "made-up", "10.0.0.1", "ip"},
+		{"no key", "loadgen", "", "10.0.0.1", "ip"},
+		{"no keys configured", "", "loadgen", "10.0.0.1", "ip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/product-detail", nil)
+			r.RemoteAddr = "10.0.0.1:1234"
+			if tt.header != "" {
+				r.Header.Set(APIKeyHeader, tt.header)
+			}
+			key, kind := newAPIKeys(tt.keys).rateLimitKey(r)
+			if key != tt.wantKey || kind != tt.wantKind {
+				t.Errorf("rateLimitKey = %q, %q, want %q, %q", key, kind, tt.wantKey, tt.wantKind)
+			}
+		})
+	}
+}
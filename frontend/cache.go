@@ -0,0 +1,226 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvRedisURL             = "REDIS_URL"
+	EnvProductCacheTTL      = "PRODUCT_CACHE_TTL"
+	DefaultCacheTTL         = "30s"
+	EnvProductCacheSize     = "PRODUCT_CACHE_SIZE"
+	DefaultProductCacheSize = "0"
+)
+
+// cachedProductService serves product info from Redis when possible and
+// falls back to the wrapped service on a miss.
+type cachedProductService struct {
+	next      ProductService
+	client    *redis.Client
+	ttl       time.Duration
+	telemetry *cacheTelemetry
+}
+
+func (s *cachedProductService) GetProductInfo(ctx context.Context, productID string) (string, error) {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ProductCache.GetProductInfo", observability.String("product.id", productID))
+	defer span.End()
+
+	key := "product:" + productID
+	productInfo, err := s.client.Get(ctx, key).Result()
+	switch {
+	case err == nil:
+		s.telemetry.Record(ctx, span, "redis", true)
+		return productInfo, nil
+	case !errors.Is(err, redis.Nil):
+		// A broken cache must not break the request; go to the source instead.
+		obs.Log.Warn("Product cache unavailable", "error", err)
+	}
+	s.telemetry.Record(ctx, span, "redis", false)
+
+	productInfo, err = s.next.GetProductInfo(ctx, productID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.client.Set(ctx, key, productInfo, s.ttl).Err(); err != nil {
+		obs.Log.Warn("Failed to cache product info", "error", err)
+	}
+	return productInfo, nil
+}
+
+// NewCachedProductService wraps next with a Redis cache whose entries expire
+// after ttl, reporting its lookups to telemetry.
+func NewCachedProductService(next ProductService, client *redis.Client, ttl time.Duration, telemetry *cacheTelemetry) ProductService {
+	return &cachedProductService{next: next, client: client, ttl: ttl, telemetry: telemetry}
+}
+
+// productCacheSize returns PRODUCT_CACHE_SIZE, the number of products the
+// in-memory cache holds; 0 disables it.
+func productCacheSize() int {
+	n, err := strconv.Atoi(servicekit.Getenv(EnvProductCacheSize, DefaultProductCacheSize))
+	if err != nil || n < 0 {
+		n, _ = strconv.Atoi(DefaultProductCacheSize)
+	}
+	return n
+}
+
+// memoryProductCache serves product info from memory when possible, in front
+// of the Redis cache if there is one, and falls back to the wrapped service
+// on a miss. It holds up to size products; when full, it drops the expired
+// ones, or else any one, to make room.
+type memoryProductCache struct {
+	next      ProductService
+	ttl       time.Duration
+	size      int
+	telemetry *cacheTelemetry
+
+	mu      sync.Mutex
+	entries map[string]memoryCacheEntry
+}
+
+type memoryCacheEntry struct {
+	info    string
+	expires time.Time
+}
+
+// NewMemoryProductCache wraps next with an in-memory cache of size products
+// whose entries expire after ttl, reporting its lookups to telemetry.
+func NewMemoryProductCache(next ProductService, size int, ttl time.Duration, telemetry *cacheTelemetry) ProductService {
+	return &memoryProductCache{
+		next:      next,
+		ttl:       ttl,
+		size:      size,
+		telemetry: telemetry,
+		entries:   make(map[string]memoryCacheEntry, size),
+	}
+}
+
+func (c *memoryProductCache) GetProductInfo(ctx context.Context, productID string) (string, error) {
+	ctx, _, span := obsmiddleware.StartSpan(ctx, "MemoryCache.GetProductInfo", observability.String("product.id", productID))
+	defer span.End()
+
+	c.mu.Lock()
+	entry, ok := c.entries[productID]
+	c.mu.Unlock()
+	if ok && time.Now().Before(entry.expires) {
+		c.telemetry.Record(ctx, span, "memory", true)
+		return entry.info, nil
+	}
+	c.telemetry.Record(ctx, span, "memory", false)
+
+	productInfo, err := c.next.GetProductInfo(ctx, productID)
+	if err != nil {
+		return "", err
+	}
+	c.store(productID, productInfo)
+	return productInfo, nil
+}
+
+func (c *memoryProductCache) store(productID, productInfo string) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[productID]; !ok && len(c.entries) >= c.size {
+		for id, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, id)
+			}
+		}
+		for id := range c.entries {
+			if len(c.entries) < c.size {
+				break
+			}
+			delete(c.entries, id)
+		}
+	}
+	c.entries[productID] = memoryCacheEntry{info: productInfo, expires: now.Add(c.ttl)}
+}
+
+// cacheTelemetry reports the lookups of the product caches: each one adds a
+// cache.hit or cache.miss event to the cache's span and counts towards the
+// cache.lookups counter and the cache.hit_ratio gauge, both split by
+// cache.tier. The request span gets cache.hit and, on a hit, cache.tier; with
+// both caches, the outcome of the innermost cache consulted wins, which is
+// the request's overall outcome.
+type cacheTelemetry struct {
+	lookups metric.Int64Counter
+
+	mu    sync.Mutex
+	stats map[string]*cacheStats // by tier
+}
+
+type cacheStats struct {
+	hits, lookups int64
+}
+
+func newCacheTelemetry(meter metric.Meter) (*cacheTelemetry, error) {
+	lookups, err := meter.Int64Counter("cache.lookups",
+		metric.WithDescription("Product cache lookups, split by cache tier and whether they hit"),
+		metric.WithUnit("{lookup}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	t := &cacheTelemetry{lookups: lookups, stats: make(map[string]*cacheStats)}
+	_, err = meter.Float64ObservableGauge("cache.hit_ratio",
+		metric.WithDescription("Share of product cache lookups that hit since the service started, per cache tier"),
+		metric.WithUnit("1"),
+		metric.WithFloat64Callback(func(_ context.Context, o metric.Float64Observer) error {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			for tier, st := range t.stats {
+				o.Observe(float64(st.hits)/float64(st.lookups), metric.WithAttributes(attribute.String("cache.tier", tier)))
+			}
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Record reports a lookup in the cache tier whose span is span.
+func (t *cacheTelemetry) Record(ctx context.Context, span observability.Span, tier string, hit bool) {
+	event := "cache.miss"
+	if hit {
+		event = "cache.hit"
+	}
+	span.AddEvent(event, trace.WithAttributes(attribute.String("cache.tier", tier)))
+	span.SetAttributes(observability.Bool("cache.hit", hit))
+	if request, ok := obsmiddleware.SpanFromContext(ctx); ok {
+		request.SetAttributes(observability.Bool("cache.hit", hit))
+		if hit {
+			request.SetAttributes(observability.String("cache.tier", tier))
+		}
+	}
+	t.lookups.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("cache.tier", tier),
+		attribute.Bool("cache.hit", hit),
+	))
+
+	t.mu.Lock()
+	st := t.stats[tier]
+	if st == nil {
+		st = &cacheStats{}
+		t.stats[tier] = st
+	}
+	st.lookups++
+	if hit {
+		st.hits++
+	}
+	t.mu.Unlock()
+}
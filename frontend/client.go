@@ -0,0 +1,60 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"obsmiddleware"
+)
+
+// downstreamClient is shared by all calls to the product and user services,
+// so connections are kept alive and reused across requests rather than set up
+// for each call. The timeouts keep a hung dependency from holding a request
+// until the server's own write timeout.
+var downstreamClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   20,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   2 * time.Second,
+		ResponseHeaderTimeout: 3 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	},
+}
+
+// sendDownstream sends req with downstreamClient. If the request's context has
+// a deadline, the time left is sent in obsmiddleware.TimeoutHeader. With the
+// OTLP APM type, the span in the request's context gets
+// http.client.connection.reused and http.client.connection.wait_ms, which show
+// whether calls pay for new connections or wait for a free one.
+func sendDownstream(req *http.Request) (*http.Response, error) {
+	if deadline, ok := req.Context().Deadline(); ok {
+		req.Header.Set(obsmiddleware.TimeoutHeader, strconv.FormatInt(max(time.Until(deadline).Milliseconds(), 1), 10))
+	}
+	if span := trace.SpanFromContext(req.Context()); span.IsRecording() {
+		var start time.Time
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+			GetConn: func(string) { start = time.Now() },
+			GotConn: func(info httptrace.GotConnInfo) {
+				span.SetAttributes(
+					attribute.Bool("http.client.connection.reused", info.Reused),
+					attribute.Float64("http.client.connection.wait_ms", milliseconds(time.Since(start))),
+				)
+			},
+		}))
+	}
+	return downstreamClient.Do(req)
+}
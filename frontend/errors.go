@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// dependencyError is the failure of one downstream call made as part of a
+// fan-out, along with the attributes describing it.
+type dependencyError struct {
+	dependency string
+	err        error
+	attrs      []attribute.KeyValue
+}
+
+func (e *dependencyError) Error() string {
+	return e.dependency + ": " + e.err.Error()
+}
+
+func (e *dependencyError) Unwrap() error {
+	return e.err
+}
+
+// Errors aggregates the failures of several downstream calls. errors.Is and
+// errors.As match any of the aggregated errors. It is safe for concurrent use.
+type Errors struct {
+	mu   sync.Mutex
+	errs []*dependencyError
+}
+
+// Add records that the call to dependency failed with err. A nil err is ignored.
+func (e *Errors) Add(dependency string, err error, attrs ...attribute.KeyValue) {
+	if err == nil {
+		return
+	}
+	attrs = append([]attribute.KeyValue{attribute.String("dependency", dependency)}, attrs...)
+	var se *statusError
+	if errors.As(err, &se) {
+		attrs = append(attrs, attribute.Int("http.status_code", se.statusCode))
+	}
+
+	e.mu.Lock()
+	e.errs = append(e.errs, &dependencyError{dependency: dependency, err: err, attrs: attrs})
+	e.mu.Unlock()
+}
+
+// Err returns e if any error was added, or nil.
+func (e *Errors) Err() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *Errors) Error() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	msgs := make([]string, len(e.errs))
+	for i, de := range e.errs {
+		msgs[i] = de.Error()
+	}
+	return fmt.Sprintf("%d dependencies failed: %s", len(e.errs), strings.Join(msgs, "; "))
+}
+
+func (e *Errors) Unwrap() []error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	errs := make([]error, len(e.errs))
+	for i, de := range e.errs {
+		errs[i] = de
+	}
+	return errs
+}
+
+// recordErrors records err on span and logs msg as a warning. An Errors
+// aggregate is recorded as one exception event per failed dependency, each
+// with its own attributes, so every failure can be inspected on its own in
+// the trace. The span status is left alone: callers use this for failures
+// they can serve the request without.
+func recordErrors(obs *observability.Observability, span observability.Span, err error, msg string) {
+	var agg *Errors
+	if !errors.As(err, &agg) {
+		span.RecordError(err)
+		obs.Log.Warn(msg, "error", err)
+		return
+	}
+
+	agg.mu.Lock()
+	failed := make([]string, len(agg.errs))
+	for i, de := range agg.errs {
+		span.RecordError(de.err, trace.WithAttributes(de.attrs...))
+		failed[i] = de.dependency
+	}
+	agg.mu.Unlock()
+
+	obs.Log.Warn(msg, "error", err, "failedDependencies", failed)
+}
+
+// fetchOptional runs calls concurrently, keyed by the dependency they reach,
+// and returns an *Errors holding the failed ones, or nil if all succeeded.
+// Each call stores its own result.
+func fetchOptional(ctx context.Context, calls map[string]func(context.Context) error) error {
+	var (
+		errs Errors
+		wg   sync.WaitGroup
+	)
+	for dependency, call := range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs.Add(dependency, call(ctx))
+		}()
+	}
+	wg.Wait()
+	return errs.Err()
+}
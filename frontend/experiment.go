@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var EnvExperiments = "EXPERIMENTS"
+
+// experiment is an A/B test splitting users evenly between its variants.
+type experiment struct {
+	name     string
+	variants []string
+}
+
+// variant returns the variant userID is assigned to. The same user always
+// gets the same variant, and the experiment name is part of the hash so that
+// being in the first variant of one experiment says nothing about the others.
+func (e experiment) variant(userID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(e.name))
+	h.Write([]byte{0})
+	h.Write([]byte(userID))
+	return e.variants[h.Sum32()%uint32(len(e.variants))]
+}
+
+// experiments assigns requests to the variants of the running experiments.
+// Assignments are recorded on the request span, so latency and errors can be
+// split by variant, and in the baggage, so downstream services see them too.
+type experiments struct {
+	list      []experiment
+	exposures metric.Int64Counter
+}
+
+// newExperiments creates the experiments described by spec, as read from
+// EXPERIMENTS: semicolon-separated experiments, each a name and its
+// comma-separated variants, e.g. "checkout-button:control,green;ranking:v1,v2".
+func newExperiments(meter metric.Meter, spec string) (*experiments, error) {
+	list, err := parseExperiments(spec)
+	if err != nil {
+		return nil, err
+	}
+	exposures, err := meter.Int64Counter("experiment.exposures",
+		metric.WithDescription("Requests served under an experiment, by experiment and variant"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &experiments{list: list, exposures: exposures}, nil
+}
+
+// Assign assigns userID to a variant of every experiment, counts the
+// exposures and records them on span. The returned context carries the
+// assignments as baggage members named experiment.<name>.
+func (e *experiments) Assign(ctx context.Context, span observability.Span, userID string) context.Context {
+	if len(e.list) == 0 {
+		return ctx
+	}
+	bag := baggage.FromContext(ctx)
+	for _, exp := range e.list {
+		variant := exp.variant(userID)
+		key := "experiment." + exp.name
+
+		span.SetAttributes(observability.String(key, variant))
+		e.exposures.Add(ctx, 1, metric.WithAttributes(
+			attribute.String("experiment.name", exp.name),
+			attribute.String("experiment.variant", variant),
+		))
+		// Names and variants were validated as baggage in parseExperiments.
+		if member, err := baggage.NewMember(key, variant); err == nil {
+			if b, err := bag.SetMember(member); err == nil {
+				bag = b
+			}
+		}
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+func parseExperiments(spec string) ([]experiment, error) {
+	var list []experiment
+	for _, def := range strings.Split(spec, ";") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		name, variantList, ok := strings.Cut(def, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("experiment %q: expected name:variant,variant", def)
+		}
+		var variants []string
+		for _, v := range strings.Split(variantList, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				variants = append(variants, v)
+			}
+		}
+		if len(variants) < 2 {
+			return nil, fmt.Errorf("experiment %q: needs at least two variants", name)
+		}
+		for _, v := range variants {
+			if _, err := baggage.NewMember("experiment."+name, v); err != nil {
+				return nil, fmt.Errorf("experiment %q: %w", name, err)
+			}
+		}
+		list = append(list, experiment{name: name, variants: variants})
+	}
+	return list, nil
+}
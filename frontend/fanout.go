@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvProductFanOutLimit     = "PRODUCT_FANOUT_CONCURRENCY"
+	DefaultProductFanOutLimit = "4"
+)
+
+// maxFanOutProducts bounds the IDs of one GET /products request.
+const maxFanOutProducts = 50
+
+// productFanOutLimit returns PRODUCT_FANOUT_CONCURRENCY, the number of product
+// lookups one GET /products request runs at a time.
+func productFanOutLimit() int {
+	n, err := strconv.Atoi(servicekit.Getenv(EnvProductFanOutLimit, DefaultProductFanOutLimit))
+	if err != nil || n < 1 {
+		n, _ = strconv.Atoi(DefaultProductFanOutLimit)
+	}
+	return n
+}
+
+// productResult is one entry of the answer of GET /products.
+type productResult struct {
+	ProductID string `json:"productId"`
+	Info      string `json:"info,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// newProductsHandler serves GET /products?ids=a,b,c: the info of each
+// product, looked up concurrently, at most limit at a time. Each lookup gets
+// its own ProductService.GetProductInfo span; as every goroutine starts it
+// from the request's context, they are siblings under the request span. A
+// failed lookup only fails its own entry.
+func newProductsHandler(productService ProductService, limit int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		obs := observability.ObsFromCtx(ctx)
+		var ids []string
+		for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+			if id = strings.TrimSpace(id); id != "" && !slices.Contains(ids, id) {
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 || len(ids) > maxFanOutProducts {
+			obs.ErrorHandler.HTTP(w, fmt.Sprintf("ids must list between 1 and %d product IDs", maxFanOutProducts), http.StatusBadRequest)
+			return
+		}
+
+		results := fanOutProducts(ctx, productService, ids, limit)
+		failed := 0
+		for _, res := range results {
+			if res.Error != "" {
+				failed++
+			}
+		}
+		obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{
+			"fanout.size":        len(ids),
+			"fanout.concurrency": min(limit, len(ids)),
+			"fanout.failed":      failed,
+		})
+		obs.Log.Info("Products fetched", "requested", len(ids), "failed", failed)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Products []productResult `json:"products"`
+		}{results})
+	})
+}
+
+// fanOutProducts looks up the info of each product in ids, running at most
+// limit lookups at once, and returns the results in the order of ids. Once
+// ctx is done, the lookups not started yet fail with its error.
+func fanOutProducts(ctx context.Context, productService ProductService, ids []string, limit int) []productResult {
+	results := make([]productResult, len(ids))
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		results[i].ProductID = id
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Error = context.Cause(ctx).Error()
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			info, err := productService.GetProductInfo(ctx, id)
+			if err != nil {
+				results[i].Error = productErrorMessage(err)
+				return
+			}
+			results[i].Info = info
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// productErrorMessage describes the failure of a product lookup for clients.
+// Only a NOT_FOUND answer means the product does not exist; other client
+// errors, such as an invalid ID or a rejected token, are failures.
+func productErrorMessage(err error) string {
+	switch {
+	case isNotFound(err):
+		return "Product not found"
+	case isTimeout(err):
+		return "Product service timed out"
+	}
+	return "Failed to fetch product info"
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"apierror"
+)
+
+func TestProductErrorMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"not found", &statusError{service: "product", statusCode: http.StatusNotFound}, "Product not found"},
+		{"not found by code", &statusError{service: "product", statusCode: http.StatusNotFound, code: apierror.NotFound}, "Product not found"},
+		{"invalid ID", &statusError{service: "product", statusCode: http.StatusBadRequest}, "Failed to fetch product info"},
+		{"unauthorized", &statusError{service: "product", statusCode: http.StatusUnauthorized}, "Failed to fetch product info"},
+		{"upstream timeout", &statusError{service: "product", statusCode: http.StatusGatewayTimeout}, "Product service timed out"},
+		{"deadline", fmt.Errorf("call: %w", context.DeadlineExceeded), "Product service timed out"},
+		{"connection refused", errors.New("connection refused"), "Failed to fetch product info"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := productErrorMessage(tt.err); got != tt.want {
+				t.Errorf("productErrorMessage(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
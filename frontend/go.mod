@@ -2,7 +2,25 @@ module frontend
 
 go 1.24.2
 
-require github.com/app-obs/go v0.250805.5
+require (
+	apierror v0.0.0
+	clients v0.0.0
+	github.com/app-obs/go v0.250805.5
+	github.com/graph-gophers/graphql-go v1.6.0
+	github.com/redis/go-redis/v9 v9.7.3
+	go.opentelemetry.io/otel v1.37.0
+	go.opentelemetry.io/otel/metric v1.37.0
+	go.opentelemetry.io/otel/trace v1.37.0
+	golang.org/x/sync v0.15.0
+	google.golang.org/grpc v1.73.0
+	grpcobs v0.0.0
+	health v0.0.0
+	obsmiddleware v0.0.0
+	proto v0.0.0
+	ratelimit v0.0.0
+	redisobs v0.0.0
+	servicekit v0.0.0
+)
 
 require (
 	github.com/DataDog/appsec-internal-go v1.13.0 // indirect
@@ -16,6 +34,7 @@ require (
 	github.com/Microsoft/go-winio v0.6.2 // indirect
 	github.com/cenkalti/backoff/v5 v5.0.2 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/ebitengine/purego v0.8.3 // indirect
 	github.com/go-logr/logr v1.4.3 // indirect
@@ -39,14 +58,11 @@ require (
 	github.com/tklauser/numcpus v0.8.0 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
 	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
-	go.opentelemetry.io/otel v1.37.0 // indirect
 	go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp v1.37.0 // indirect
 	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.37.0 // indirect
 	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.37.0 // indirect
-	go.opentelemetry.io/otel/metric v1.37.0 // indirect
 	go.opentelemetry.io/otel/sdk v1.37.0 // indirect
 	go.opentelemetry.io/otel/sdk/metric v1.37.0 // indirect
-	go.opentelemetry.io/otel/trace v1.37.0 // indirect
 	go.opentelemetry.io/proto/otlp v1.7.0 // indirect
 	go.uber.org/atomic v1.11.0 // indirect
 	golang.org/x/net v0.41.0 // indirect
@@ -56,7 +72,24 @@ require (
 	golang.org/x/xerrors v0.0.0-20231012003039-104605ab7028 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250603155806-513f23925822 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250603155806-513f23925822 // indirect
-	google.golang.org/grpc v1.73.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/DataDog/dd-trace-go.v1 v1.62.0 // indirect
 )
+
+replace health => ../health
+
+replace redisobs => ../redisobs
+
+replace grpcobs => ../grpcobs
+
+replace proto => ../proto
+
+replace ratelimit => ../ratelimit
+
+replace servicekit => ../servicekit
+
+replace clients => ../clients
+
+replace apierror => ../apierror
+
+replace obsmiddleware => ../obsmiddleware
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/app-obs/go/observability"
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+	"github.com/graph-gophers/graphql-go/introspection"
+	"github.com/graph-gophers/graphql-go/trace/tracer"
+	"go.opentelemetry.io/otel/codes"
+
+	"obsmiddleware"
+)
+
+// graphqlSchema aggregates the product and user services. productDetail
+// serves the same data as GET /product-detail, with each part resolved by its
+// own resolver.
+const graphqlSchema = `
+schema {
+	query: Query
+}
+
+type Query {
+	product(id: ID!): Product
+	user(id: ID!): User
+	productDetail(productId: ID!, userId: ID!): ProductDetail!
+}
+
+type Product {
+	id: ID!
+	info: String!
+}
+
+type User {
+	id: ID!
+	info: String!
+}
+
+type ProductDetail {
+	product: Product
+	user: User
+}
+`
+
+// newGraphQLHandler serves POST /graphql. Every query gets a span, and every
+// resolver a child span of it; see graphqlTracer.
+func newGraphQLHandler(productService ProductService, userService UserService) http.Handler {
+	schema := graphql.MustParseSchema(graphqlSchema,
+		&graphqlResolver{products: productService, users: userService},
+		graphql.Tracer(graphqlTracer{}),
+		graphql.Logger(graphqlLogger{}),
+	)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		obs := observability.ObsFromCtx(r.Context())
+		var params struct {
+			Query         string         `json:"query"`
+			OperationName string         `json:"operationName"`
+			Variables     map[string]any `json:"variables"`
+		}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&params); err != nil {
+			obs.ErrorHandler.HTTP(w, "Invalid GraphQL request", http.StatusBadRequest)
+			return
+		}
+
+		resp := schema.Exec(r.Context(), params.Query, params.OperationName, params.Variables)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	})
+}
+
+type graphqlResolver struct {
+	products ProductService
+	users    UserService
+}
+
+func (r *graphqlResolver) Product(ctx context.Context, args struct{ ID graphql.ID }) (*productResolver, error) {
+	return fetchProduct(ctx, r.products, string(args.ID))
+}
+
+func (r *graphqlResolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	return fetchUser(ctx, r.users, string(args.ID))
+}
+
+func (r *graphqlResolver) ProductDetail(args struct{ ProductID, UserID graphql.ID }) *productDetailResolver {
+	return &productDetailResolver{root: r, productID: string(args.ProductID), userID: string(args.UserID)}
+}
+
+type productResolver struct {
+	id, info string
+}
+
+func (p *productResolver) ID() graphql.ID { return graphql.ID(p.id) }
+func (p *productResolver) Info() string   { return p.info }
+
+type userResolver struct {
+	id, info string
+}
+
+func (u *userResolver) ID() graphql.ID { return graphql.ID(u.id) }
+func (u *userResolver) Info() string   { return u.info }
+
+// productDetailResolver resolves the product and the user of a product
+// detail separately, so a query can ask for either and a failing user lookup
+// only nulls the user.
+type productDetailResolver struct {
+	root              *graphqlResolver
+	productID, userID string
+}
+
+func (d *productDetailResolver) Product(ctx context.Context) (*productResolver, error) {
+	return fetchProduct(ctx, d.root.products, d.productID)
+}
+
+func (d *productDetailResolver) User(ctx context.Context) (*userResolver, error) {
+	return fetchUser(ctx, d.root.users, d.userID)
+}
+
+// fetchProduct returns the product with id, or nil if there is none.
+func fetchProduct(ctx context.Context, products ProductService, id string) (*productResolver, error) {
+	info, err := products.GetProductInfo(ctx, id)
+	if err != nil {
+		if isClientError(err) {
+			return nil, nil
+		}
+		return nil, errors.New("failed to fetch product info")
+	}
+	return &productResolver{id: id, info: info}, nil
+}
+
+// fetchUser returns the user with id, or nil if there is none.
+func fetchUser(ctx context.Context, users UserService, id string) (*userResolver, error) {
+	info, err := users.GetUserInfo(ctx, id)
+	if err != nil {
+		if isClientError(err) {
+			return nil, nil
+		}
+		return nil, errors.New("failed to fetch user info")
+	}
+	return &userResolver{id: id, info: info}, nil
+}
+
+// graphqlTracer traces GraphQL execution: a "GraphQL <operation>" span per
+// query, with graphql.operation.name and graphql.document, and a
+// "GraphQL.resolve <Type>.<field>" child span per resolver, with
+// graphql.field.name and graphql.field.parent_type. Spans of the services a
+// resolver calls become children of its span. Fields read straight from a
+// resolved object, such as Product.id, get no span.
+type graphqlTracer struct{}
+
+var (
+	_ tracer.Tracer           = graphqlTracer{}
+	_ tracer.ValidationTracer = graphqlTracer{}
+)
+
+func (graphqlTracer) TraceQuery(ctx context.Context, queryString, operationName string, _ map[string]any, _ map[string]*introspection.Type) (context.Context, tracer.QueryFinishFunc) {
+	name := "GraphQL query"
+	if operationName != "" {
+		name = "GraphQL " + operationName
+	}
+	ctx, _, span := obsmiddleware.StartSpan(ctx, name,
+		observability.String("graphql.operation.name", operationName),
+		observability.String("graphql.document", queryString),
+	)
+	return ctx, func(errs []*gqlerrors.QueryError) {
+		if len(errs) > 0 {
+			span.SetAttributes(observability.Int("graphql.errors.count", len(errs)))
+			span.SetStatus(codes.Error, errs[0].Message)
+		}
+		span.End()
+	}
+}
+
+func (graphqlTracer) TraceField(ctx context.Context, _, typeName, fieldName string, trivial bool, _ map[string]any) (context.Context, tracer.FieldFinishFunc) {
+	if trivial {
+		return ctx, func(*gqlerrors.QueryError) {}
+	}
+	ctx, _, span := obsmiddleware.StartSpan(ctx, "GraphQL.resolve "+typeName+"."+fieldName,
+		observability.String("graphql.field.name", fieldName),
+		observability.String("graphql.field.parent_type", typeName),
+	)
+	return ctx, func(err *gqlerrors.QueryError) {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Message)
+		}
+		span.End()
+	}
+}
+
+// TraceValidation records nothing: validation errors fail the query span.
+func (graphqlTracer) TraceValidation(context.Context) tracer.ValidationFinishFunc {
+	return func([]*gqlerrors.QueryError) {}
+}
+
+// graphqlLogger logs the panics the GraphQL executor recovers from in a
+// resolver through the request's Observability, like obsmiddleware.Recoverer
+// does for handlers, instead of the standard logger.
+type graphqlLogger struct{}
+
+func (graphqlLogger) LogPanic(ctx context.Context, value any) {
+	observability.ObsFromCtx(ctx).Log.Error("Recovered from panic",
+		"error", fmt.Errorf("panic: %v", value),
+		"exception.stacktrace", string(debug.Stack()),
+	)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"clients"
+	"grpcobs"
+	"proto/productpb"
+	"proto/userpb"
+
+	"servicekit"
+)
+
+var EnvDownstreamProtocol = "DOWNSTREAM_PROTOCOL"
+
+var (
+	productServiceGRPCAddr = servicekit.Getenv("PRODUCT_SERVICE_GRPC_ADDR", "product-service:9086")
+	userServiceGRPCAddr    = servicekit.Getenv("USER_SERVICE_GRPC_ADDR", "user-service:9087")
+)
+
+// downstreamClients returns the gRPC clients of the product and user services
+// when DOWNSTREAM_PROTOCOL is "grpc", and nil clients when it is "http" (the
+// default), in which case the services are called over HTTP.
+func downstreamClients() (productpb.ProductServiceClient, userpb.UserServiceClient, error) {
+	switch protocol := servicekit.Getenv(EnvDownstreamProtocol, "http"); protocol {
+	case "http":
+		return nil, nil, nil
+	case "grpc":
+		productConn, err := dialDownstream(productServiceGRPCAddr)
+		if err != nil {
+			return nil, nil, err
+		}
+		userConn, err := dialDownstream(userServiceGRPCAddr)
+		if err != nil {
+			return nil, nil, err
+		}
+		return productpb.NewProductServiceClient(productConn), userpb.NewUserServiceClient(userConn), nil
+	default:
+		return nil, nil, fmt.Errorf("unknown %s %q, want http or grpc", EnvDownstreamProtocol, protocol)
+	}
+}
+
+// dialDownstream creates a client connection to addr whose calls get a
+// client span from grpcobs, which also propagates the trace context, and
+// forward the caller's bearer token. The connection is set up lazily, on the
+// first call.
+func dialDownstream(addr string) (*grpc.ClientConn, error) {
+	return grpc.NewClient(addr,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithChainUnaryInterceptor(grpcobs.UnaryClientInterceptor(), forwardAuthorization),
+	)
+}
+
+// forwardAuthorization sends the Authorization header of the caller's
+// request, which clients.ForwardAuthorization put in ctx, in the authorization
+// metadata of the call, as the HTTP clients do in the header.
+func forwardAuthorization(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if authorization, ok := clients.Authorization(ctx); ok {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", authorization)
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+func callProductServiceGRPC(ctx context.Context, client productpb.ProductServiceClient, productID string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, downstreamClient.Timeout)
+	defer cancel()
+
+	resp, err := client.GetProduct(ctx, &productpb.GetProductRequest{Id: productID})
+	if err != nil {
+		return "", grpcStatusError("product", err)
+	}
+	return resp.GetInfo(), nil
+}
+
+func callUserServiceGRPC(ctx context.Context, client userpb.UserServiceClient, userID string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, downstreamClient.Timeout)
+	defer cancel()
+
+	resp, err := client.GetUser(ctx, &userpb.GetUserRequest{Id: userID})
+	if err != nil {
+		return "", grpcStatusError("user", err)
+	}
+	return resp.GetInfo(), nil
+}
+
+// grpcStatusError turns the status of a failed call into the statusError an
+// HTTP call would have returned, so callers handle both protocols alike.
+// Calls that failed to reach the service keep their error, as with HTTP.
+func grpcStatusError(service string, err error) error {
+	switch status.Code(err) {
+	case codes.NotFound:
+		return &statusError{service: service, statusCode: http.StatusNotFound}
+	case codes.InvalidArgument:
+		return &statusError{service: service, statusCode: http.StatusBadRequest}
+	case codes.Unauthenticated:
+		return &statusError{service: service, statusCode: http.StatusUnauthorized}
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Canceled:
+		return err
+	default:
+		return &statusError{service: service, statusCode: http.StatusInternalServerError}
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"clients"
+)
+
+func TestForwardAuthorization(t *testing.T) {
+	tests := []struct {
+		name          string
+		authorization string
+		want          []string
+	}{
+		{"forwarded", "Bearer abc", []string{"Bearer abc"}},
+		{"none", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := clients.WithAuthorization(context.Background(), tt.authorization)
+			var got []string
+			invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				got = md.Get("authorization")
+				return nil
+			}
+			if err := forwardAuthorization(ctx, "/user.v1.UserService/GetUser", nil, nil, nil, invoker); err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("authorization metadata = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+)
+
+var EnvHedgeDelay = "DOWNSTREAM_HEDGE_DELAY"
+
+// errHedgeLost cancels the attempts of a hedged request that lost the race.
+var errHedgeLost = errors.New("another attempt answered first")
+
+// requestFunc builds the request of one attempt at a downstream call. The
+// trace context is injected from obs, so the request is sent on behalf of the
+// span obs belongs to.
+type requestFunc func(ctx context.Context, obs *observability.Observability) (*http.Request, error)
+
+// hedgePolicy hedges slow downstream calls: when the first attempt has not
+// answered after delay, a second one is sent, and whichever answers first is
+// used while the other is canceled. This trades a little extra load for a
+// shorter tail latency, as long as slowness hits attempts independently. A
+// nil *hedgePolicy sends a single attempt.
+type hedgePolicy struct {
+	delay time.Duration
+}
+
+// newHedgePolicy parses DOWNSTREAM_HEDGE_DELAY. Empty or zero disables
+// hedging and returns nil.
+func newHedgePolicy(delay string) (*hedgePolicy, error) {
+	if delay == "" {
+		return nil, nil
+	}
+	d, err := time.ParseDuration(delay)
+	if err != nil || d < 0 {
+		return nil, fmt.Errorf("invalid hedge delay %q", delay)
+	}
+	if d == 0 {
+		return nil, nil
+	}
+	return &hedgePolicy{delay: d}, nil
+}
+
+// hedgeAttempt is the outcome of one attempt of a hedged request.
+type hedgeAttempt struct {
+	n    int
+	resp *http.Response
+	err  error
+	span observability.Span
+}
+
+// Send sends the request built by newRequest and, if it has not been answered
+// after the hedge delay, a second one. It returns the first response, of any
+// status; an attempt that fails without one only ends the call if no other
+// attempt is left. Each attempt runs under a HedgedRequest.Attempt span with
+// hedge.attempt and hedge.won, and the current span of ctx records
+// hedge.attempts and hedge.winner.
+func (h *hedgePolicy) Send(ctx context.Context, obs *observability.Observability, newRequest requestFunc) (*http.Response, error) {
+	if h == nil {
+		req, err := newRequest(ctx, obs)
+		if err != nil {
+			return nil, err
+		}
+		return sendDownstream(req)
+	}
+
+	results := make(chan hedgeAttempt, 2)
+	cancels := make(map[int]context.CancelCauseFunc, 2)
+	launch := func(n int) {
+		attemptCtx, attemptObs, span := obsmiddleware.StartSpan(ctx, "HedgedRequest.Attempt", observability.Int("hedge.attempt", n))
+		// Cancel the request only, so a lost attempt's span is not marked
+		// as failed by its context.
+		sendCtx, cancel := context.WithCancelCause(attemptCtx)
+		cancels[n] = cancel
+		go func() {
+			req, err := newRequest(sendCtx, attemptObs)
+			var resp *http.Response
+			if err == nil {
+				resp, err = sendDownstream(req)
+			}
+			results <- hedgeAttempt{n: n, resp: resp, err: err, span: span}
+		}()
+	}
+
+	launch(1)
+	launched, pending := 1, 1
+	timer := time.NewTimer(h.delay)
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C:
+			obsmiddleware.LogDebug(obs, "Hedging slow downstream call", "delay", h.delay.String())
+			launched++
+			pending++
+			launch(launched)
+		case a := <-results:
+			pending--
+			if a.err != nil {
+				a.span.SetAttributes(observability.Bool("hedge.won", false))
+				a.span.RecordError(a.err)
+				a.span.End()
+				cancels[a.n](a.err)
+				// Wait for the other attempt, if any; a first attempt that
+				// fails before the hedge is sent is not hedged.
+				if pending > 0 {
+					continue
+				}
+				obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{"hedge.attempts": launched})
+				return nil, a.err
+			}
+
+			a.span.SetAttributes(observability.Bool("hedge.won", true))
+			a.span.End()
+			obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{"hedge.attempts": launched, "hedge.winner": a.n})
+			for n, cancel := range cancels {
+				if n != a.n {
+					cancel(errHedgeLost)
+				}
+			}
+			if pending > 0 {
+				go discardHedgeAttempts(results, pending)
+			}
+			// The winner's request stays alive until its body is closed.
+			a.resp.Body = &cancelOnClose{ReadCloser: a.resp.Body, cancel: cancels[a.n]}
+			return a.resp, nil
+		}
+	}
+}
+
+// discardHedgeAttempts waits for the n attempts that were canceled because
+// another one won, and ends their spans.
+func discardHedgeAttempts(results <-chan hedgeAttempt, n int) {
+	for range n {
+		a := <-results
+		if a.resp != nil {
+			a.resp.Body.Close()
+		}
+		a.span.SetAttributes(
+			observability.Bool("hedge.won", false),
+			observability.Bool("hedge.canceled", true),
+		)
+		a.span.End()
+	}
+}
+
+// cancelOnClose cancels the context of a response's request once its body
+// is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelCauseFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel(nil)
+	return err
+}
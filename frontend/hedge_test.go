@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/app-obs/go/observability"
+)
+
+func TestNewHedgePolicy(t *testing.T) {
+	tests := []struct {
+		delay   string
+		want    *hedgePolicy
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"0s", nil, false},
+		{"50ms", &hedgePolicy{delay: 50 * time.Millisecond}, false},
+		{"-1s", nil, true},
+		{"soon", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := newHedgePolicy(tt.delay)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("newHedgePolicy(%q) error = %v, want error %v", tt.delay, err, tt.wantErr)
+		}
+		if (got == nil) != (tt.want == nil) || got != nil && *got != *tt.want {
+			t.Errorf("newHedgePolicy(%q) = %v, want %v", tt.delay, got, tt.want)
+		}
+	}
+}
+
+func TestHedgePolicySend(t *testing.T) {
+	tests := []struct {
+		name string
+		// slow lists the attempts, counted from 1, that only answer once
+		// canceled.
+		slow         map[int32]bool
+		wantAttempts int32
+		wantBody     string
+	}{
+		{"fast first attempt", nil, 1, "1"},
+		{"slow first attempt hedged", map[int32]bool{1: true}, 2, "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits, canceled atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				n := hits.Add(1)
+				if tt.slow[n] {
+					<-r.Context().Done()
+					canceled.Add(1)
+					return
+				}
+				fmt.Fprint(w, n)
+			}))
+			defer srv.Close()
+			h := &hedgePolicy{delay: 20 * time.Millisecond}
+			ctx := context.Background()
+
+			resp, err := h.Send(ctx, testFactory.NewBackgroundObservability(ctx), getRequest(srv.URL))
+			if err != nil {
+				t.Fatalf("Send = %v", err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if string(body) != tt.wantBody {
+				t.Errorf("body = %q, want the answer of attempt %s", body, tt.wantBody)
+			}
+			if got := hits.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+			// The server sees the loser's cancellation asynchronously.
+			deadline := time.Now().Add(time.Second)
+			for canceled.Load() != int32(len(tt.slow)) && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+			if got := canceled.Load(); got != int32(len(tt.slow)) {
+				t.Errorf("canceled attempts = %d, want %d", got, len(tt.slow))
+			}
+		})
+	}
+}
+
+func TestHedgePolicySendFailedBeforeHedge(t *testing.T) {
+	errBuild := errors.New("build failed")
+	var calls atomic.Int32
+	h := &hedgePolicy{delay: time.Second}
+	ctx := context.Background()
+
+	_, err := h.Send(ctx, testFactory.NewBackgroundObservability(ctx), func(context.Context, *observability.Observability) (*http.Request, error) {
+		calls.Add(1)
+		return nil, errBuild
+	})
+	if !errors.Is(err, errBuild) {
+		t.Errorf("Send = %v, want %v", err, errBuild)
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
+
+func TestHedgePolicySendNil(t *testing.T) {
+	var hits atomic.Int32
+	srv := statusServer(t, &hits, http.StatusOK)
+	var h *hedgePolicy
+	ctx := context.Background()
+
+	resp, err := h.Send(ctx, testFactory.NewBackgroundObservability(ctx), getRequest(srv.URL))
+	if err != nil {
+		t.Fatalf("Send = %v", err)
+	}
+	resp.Body.Close()
+	if got := hits.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"obsmiddleware"
+)
+
+// callTimelineKey is a private type to prevent collisions with other packages.
+type callTimelineKey struct{}
+
+// callTimeline records when the downstream calls made for one request start
+// and end.
+type callTimeline struct {
+	mu        sync.Mutex
+	calls     int
+	firstCall time.Time
+	lastEnd   time.Time
+}
+
+// recordCall adds a downstream call that ran from start to end to the
+// timeline of the request behind ctx, if any.
+func recordCall(ctx context.Context, start, end time.Time) {
+	t, ok := ctx.Value(callTimelineKey{}).(*callTimeline)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls++
+	if t.firstCall.IsZero() || start.Before(t.firstCall) {
+		t.firstCall = start
+	}
+	if end.After(t.lastEnd) {
+		t.lastEnd = end
+	}
+}
+
+// trackOrchestration measures the time a request spends in the frontend
+// itself before its first downstream call and after its last one: parsing,
+// validation and rendering, which no dependency span covers. They are recorded
+// on the request span as orchestration.first_call_delay_ms and
+// orchestration.tail_ms, next to the number of calls made. It must run inside
+// obsmiddleware.WithObservability.
+func trackOrchestration(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		span, ok := obsmiddleware.SpanFromContext(r.Context())
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		timeline := &callTimeline{}
+		defer func() {
+			end := time.Now()
+			timeline.mu.Lock()
+			defer timeline.mu.Unlock()
+
+			attrs := []attribute.KeyValue{attribute.Int("orchestration.calls", timeline.calls)}
+			if timeline.calls > 0 {
+				attrs = append(attrs,
+					attribute.Float64("orchestration.first_call_delay_ms", milliseconds(timeline.firstCall.Sub(start))),
+					attribute.Float64("orchestration.tail_ms", milliseconds(end.Sub(timeline.lastEnd))),
+				)
+			}
+			span.SetAttributes(attrs...)
+		}()
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), callTimelineKey{}, timeline)))
+	})
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// indexPage is a demo page whose fetch calls send a traceparent header made
+// up in the browser, so the backend spans join the browser's trace.
+//
+//go:embed index.html
+var indexPage []byte
+
+// handleIndex serves GET /, the demo page.
+func handleIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(indexPage)
+}
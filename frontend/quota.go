@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvAPIQuotaPerDay     = "API_QUOTA_PER_DAY"
+	DefaultAPIQuotaPerDay = "1000"
+)
+
+// APIKeyHeader carries the caller's API key. Requests without one share the
+// anonymous quota.
+const (
+	APIKeyHeader = "X-API-Key"
+	anonymousKey = "anonymous"
+)
+
+// quotaTracker enforces a daily request quota per known API key. Usage is
+// kept in memory and resets at midnight UTC, which is enough for a single
+// instance. Requests with an unknown key are rejected before they are
+// counted, so usage holds at most one entry per known key and the anonymous
+// one.
+type quotaTracker struct {
+	limit int64
+	keys  *apiKeys
+
+	mu    sync.Mutex
+	day   string
+	usage map[string]int64
+
+	requests metric.Int64Counter
+}
+
+// newQuotaTracker creates a tracker allowing limit requests per day to each
+// of keys. With no keys, the key of a request is ignored and every request
+// counts against the anonymous quota.
+func newQuotaTracker(meter metric.Meter, limit int64, keys *apiKeys) (*quotaTracker, error) {
+	requests, err := meter.Int64Counter("api.quota.requests",
+		metric.WithDescription("Requests counted against API key quotas, split by whether the quota was exceeded"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &quotaTracker{
+		limit:    limit,
+		keys:     keys,
+		usage:    make(map[string]int64),
+		requests: requests,
+	}, nil
+}
+
+// take counts one request for key and reports how many requests are left
+// today, and whether this one is within the quota.
+func (t *quotaTracker) take(key string, now time.Time) (remaining int64, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rollover(now)
+	used := t.usage[key]
+	if used >= t.limit {
+		return 0, false
+	}
+	used++
+	t.usage[key] = used
+	return t.limit - used, true
+}
+
+// used returns the requests key has made today.
+func (t *quotaTracker) used(key string, now time.Time) int64 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.rollover(now)
+	return t.usage[key]
+}
+
+// rollover forgets the usage of previous days. t.mu must be held.
+func (t *quotaTracker) rollover(now time.Time) {
+	if day := now.UTC().Format(time.DateOnly); day != t.day {
+		t.day = day
+		clear(t.usage)
+	}
+}
+
+// Middleware counts the request against the caller's quota, sets the
+// X-RateLimit-* headers and rejects the request with 429 once the quota is
+// used up, or with 401 if its API key is unknown. It must run inside
+// obsmiddleware.WithObservability.
+func (t *quotaTracker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyID, known := t.keyID(r)
+		if !known {
+			t.rejectUnknownKey(w, r, keyID)
+			return
+		}
+		now := time.Now()
+		remaining, ok := t.take(keyID, now)
+
+		t.requests.Add(r.Context(), 1, metric.WithAttributes(
+			attribute.String("api.key_id", keyID),
+			attribute.Bool("api.quota.exceeded", !ok),
+		))
+		if span, found := obsmiddleware.SpanFromContext(r.Context()); found {
+			span.SetAttributes(
+				observability.String("api.key_id", keyID),
+				observability.Int("api.quota.remaining", int(remaining)),
+				observability.Bool("api.quota.exceeded", !ok),
+			)
+		}
+
+		reset := secondsUntilReset(now)
+		w.Header().Set("X-RateLimit-Limit", strconv.FormatInt(t.limit, 10))
+		w.Header().Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset, 10))
+
+		if !ok {
+			// An exhausted quota is expected client behavior, so it is
+			// reported as a security event rather than through the error handler.
+			security(observability.ObsFromCtx(r.Context())).RateLimited(r, keyID, t.limit)
+			w.Header().Set("Retry-After", strconv.FormatInt(reset, 10))
+			http.Error(w, "API quota exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// quotaUsage is the body served by HandleUsage.
+type quotaUsage struct {
+	KeyID          string `json:"key_id"`
+	Limit          int64  `json:"limit"`
+	Used           int64  `json:"used"`
+	Remaining      int64  `json:"remaining"`
+	ResetInSeconds int64  `json:"reset_in_seconds"`
+}
+
+// HandleUsage reports the caller's quota usage for today. It does not count
+// against the quota.
+func (t *quotaTracker) HandleUsage(w http.ResponseWriter, r *http.Request) {
+	keyID, known := t.keyID(r)
+	if !known {
+		t.rejectUnknownKey(w, r, keyID)
+		return
+	}
+	now := time.Now()
+	used := t.used(keyID, now)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quotaUsage{
+		KeyID:          keyID,
+		Limit:          t.limit,
+		Used:           used,
+		Remaining:      max(t.limit-used, 0),
+		ResetInSeconds: secondsUntilReset(now),
+	})
+}
+
+// keyID identifies the caller's API key without exposing it: the key is
+// replaced by a short hash before it reaches spans, metrics or logs. Callers
+// without a key, or of a tracker without keys, are anonymous. known is false
+// for a key the tracker does not know.
+func (t *quotaTracker) keyID(r *http.Request) (id string, known bool) {
+	if t.keys == nil {
+		return anonymousKey, true
+	}
+	id, known = t.keys.lookup(r)
+	if id == "" {
+		return anonymousKey, true
+	}
+	return id, known
+}
+
+// rejectUnknownKey answers a request whose API key, keyID, is unknown with
+// 401, and records it as a failed authentication.
+func (t *quotaTracker) rejectUnknownKey(w http.ResponseWriter, r *http.Request, keyID string) {
+	if span, found := obsmiddleware.SpanFromContext(r.Context()); found {
+		span.SetAttributes(observability.String("api.key_id", keyID))
+	}
+	security(observability.ObsFromCtx(r.Context())).AuthFailure(r, keyID, "unknown API key")
+	http.Error(w, "Invalid API key", http.StatusUnauthorized)
+}
+
+// secondsUntilReset returns the seconds left until quotas reset at midnight UTC.
+func secondsUntilReset(now time.Time) int64 {
+	midnight := now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+	return int64(midnight.Sub(now).Seconds()) + 1
+}
+
+// apiQuotaPerDay returns the number of requests each API key may make per day.
+func apiQuotaPerDay() int64 {
+	n, err := strconv.ParseInt(servicekit.Getenv(EnvAPIQuotaPerDay, DefaultAPIQuotaPerDay), 10, 64)
+	if err != nil || n < 0 {
+		n, _ = strconv.ParseInt(DefaultAPIQuotaPerDay, 10, 64)
+	}
+	return n
+}
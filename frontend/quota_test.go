@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestQuotaTrackerKeyID(t *testing.T) {
+	tests := []struct {
+		name      string
+		keys      string
+		header    string
+		wantID    string
+		wantKnown bool
+	}{
+		{"no key", "loadgen", "", anonymousKey, true},
+		{"known key", "loadgen", "loadgen", "bd5d5d0eeff9", true},
+		{"unknown key", "loadgen", "made-up", "da53422a4618", false},
+		{"no keys configured", "", "made-up", anonymousKey, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &quotaTracker{keys: newAPIKeys(tt.keys)}
+			r := httptest.NewRequest("GET", "/product-detail", nil)
+			if tt.header != "" {
+				r.Header.Set(APIKeyHeader, tt.header)
+			}
+			id, known := tracker.keyID(r)
+			if id != tt.wantID || known != tt.wantKnown {
+				t.Errorf("keyID = %q, %v, want %q, %v", id, known, tt.wantID, tt.wantKnown)
+			}
+		})
+	}
+}
+
+func TestQuotaTrackerTake(t *testing.T) {
+	day := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		limit         int64
+		takes         []time.Time
+		wantRemaining int64
+		wantOK        bool
+	}{
+		{"first", 2, []time.Time{day}, 1, true},
+		{"last", 2, []time.Time{day, day}, 0, true},
+		{"over", 2, []time.Time{day, day, day}, 0, false},
+		{"reset at midnight", 2, []time.Time{day, day, day.Add(12 * time.Hour)}, 1, true},
+		{"zero quota", 0, []time.Time{day}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := &quotaTracker{limit: tt.limit, usage: make(map[string]int64)}
+			var remaining int64
+			var ok bool
+			for _, now := range tt.takes {
+				remaining, ok = tracker.take("key", now)
+			}
+			if remaining != tt.wantRemaining || ok != tt.wantOK {
+				t.Errorf("take = %d, %v, want %d, %v", remaining, ok, tt.wantRemaining, tt.wantOK)
+			}
+		})
+	}
+}
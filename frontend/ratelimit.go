@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/app-obs/go/observability"
+
+	"ratelimit"
+	"servicekit"
+)
+
+var (
+	EnvRateLimit          = "RATE_LIMIT"
+	EnvRateLimitBurst     = "RATE_LIMIT_BURST"
+	DefaultRateLimitBurst = "20"
+	EnvRateLimitKey       = "RATE_LIMIT_KEY"
+	DefaultRateLimitKey   = "ip"
+)
+
+// newRateLimiter creates the limiter of the requests of each client, from
+// RATE_LIMIT (requests per second, empty or 0 for no limit), RATE_LIMIT_BURST
+// and RATE_LIMIT_KEY ("ip", or "api_key" for the X-API-Key header if it is
+// one of keys, and the IP address otherwise). It returns nil if there is no
+// limit.
+func newRateLimiter(factory *observability.Factory, keys *apiKeys) (*ratelimit.Limiter, error) {
+	value := servicekit.Getenv(EnvRateLimit, "")
+	if value == "" {
+		return nil, nil
+	}
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 {
+		return nil, fmt.Errorf("invalid rate limit %q", value)
+	}
+	if rate == 0 {
+		return nil, nil
+	}
+	burst, err := strconv.Atoi(servicekit.Getenv(EnvRateLimitBurst, DefaultRateLimitBurst))
+	if err != nil || burst < 1 {
+		return nil, fmt.Errorf("invalid rate limit burst %q", servicekit.Getenv(EnvRateLimitBurst, DefaultRateLimitBurst))
+	}
+	cfg := ratelimit.Config{Rate: rate, Burst: burst}
+	switch key := servicekit.Getenv(EnvRateLimitKey, DefaultRateLimitKey); key {
+	case "ip":
+		cfg.Key = ratelimit.ByIP
+	case "api_key":
+		cfg.Key = keys.rateLimitKey
+	default:
+		return nil, fmt.Errorf("invalid rate limit key %q: must be ip or api_key", key)
+	}
+	return ratelimit.New(factory, cfg)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"io"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"obsmiddleware"
+)
+
+// trackedReader wraps an io.Reader and measures the bytes read and the time
+// spent waiting in Read.
+type trackedReader struct {
+	r        io.Reader
+	bytes    int64
+	readTime time.Duration
+}
+
+// wrapReader instruments r.
+func wrapReader(r io.Reader) *trackedReader {
+	return &trackedReader{r: r}
+}
+
+func (t *trackedReader) Read(p []byte) (int, error) {
+	start := time.Now()
+	n, err := t.r.Read(p)
+	t.readTime += time.Since(start)
+	t.bytes += int64(n)
+	return n, err
+}
+
+// Throughput returns the observed read throughput in bytes per second.
+func (t *trackedReader) Throughput() float64 {
+	if t.readTime <= 0 {
+		return 0
+	}
+	return float64(t.bytes) / t.readTime.Seconds()
+}
+
+// trackedCloser wraps an io.Closer and measures how long it stayed open.
+// When leak detection is on, a finalizer warns about closers that were
+// garbage collected without being closed.
+type trackedCloser struct {
+	c       io.Closer
+	name    string
+	opened  time.Time
+	closed  atomic.Bool
+	onClose func(openFor time.Duration)
+}
+
+// wrapCloser instruments c. onClose, if set, is called once with the time
+// between wrapping and closing.
+func wrapCloser(c io.Closer, name string, onClose func(openFor time.Duration)) *trackedCloser {
+	return &trackedCloser{c: c, name: name, opened: time.Now(), onClose: onClose}
+}
+
+func (t *trackedCloser) Close() error {
+	if t.closed.Swap(true) {
+		return t.c.Close()
+	}
+	runtime.SetFinalizer(t, nil)
+	err := t.c.Close()
+	if t.onClose != nil {
+		t.onClose(time.Since(t.opened))
+	}
+	return err
+}
+
+// detectLeak installs a finalizer that reports t if it is garbage collected
+// while still open. Finalizers are not guaranteed to run and cost GC time, so
+// this is meant for development only.
+func (t *trackedCloser) detectLeak(onLeak func(name string, openFor time.Duration)) {
+	runtime.SetFinalizer(t, func(t *trackedCloser) {
+		if !t.closed.Load() {
+			onLeak(t.name, time.Since(t.opened))
+		}
+	})
+}
+
+// resourceTracker records read throughput, time-to-close and leaks of
+// wrapped resources such as downstream response bodies.
+type resourceTracker struct {
+	detectLeaks bool
+	bytesRead   metric.Int64Histogram
+	throughput  metric.Float64Histogram
+	openTime    metric.Float64Histogram
+	leaked      metric.Int64Counter
+}
+
+// newResourceTracker creates the resource instruments. Leak detection is
+// only enabled when detectLeaks is true.
+func newResourceTracker(meter metric.Meter, detectLeaks bool) (*resourceTracker, error) {
+	bytesRead, err := meter.Int64Histogram("resource.read.bytes",
+		metric.WithDescription("Bytes read from a wrapped resource before it was closed"),
+		metric.WithUnit("By"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	throughput, err := meter.Float64Histogram("resource.read.throughput",
+		metric.WithDescription("Read throughput of a wrapped resource"),
+		metric.WithUnit("By/s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	openTime, err := meter.Float64Histogram("resource.open.duration",
+		metric.WithDescription("Time between opening and closing a wrapped resource"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	leaked, err := meter.Int64Counter("resource.leaked",
+		metric.WithDescription("Wrapped resources garbage collected without being closed"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &resourceTracker{
+		detectLeaks: detectLeaks,
+		bytesRead:   bytesRead,
+		throughput:  throughput,
+		openTime:    openTime,
+		leaked:      leaked,
+	}, nil
+}
+
+// WrapBody instruments a response body (or any io.ReadCloser) named name.
+// The measurements are recorded when the body is closed.
+func (rt *resourceTracker) WrapBody(obs *observability.Observability, name string, rc io.ReadCloser) io.ReadCloser {
+	reader := wrapReader(rc)
+	attrs := metric.WithAttributes(attribute.String("resource.name", name))
+
+	closer := wrapCloser(rc, name, func(openFor time.Duration) {
+		ctx := obs.Context()
+		rt.bytesRead.Record(ctx, reader.bytes, attrs)
+		rt.throughput.Record(ctx, reader.Throughput(), attrs)
+		rt.openTime.Record(ctx, openFor.Seconds(), attrs)
+		obsmiddleware.LogDebug(obs, "Resource closed",
+			"resource", name,
+			"bytesRead", reader.bytes,
+			"throughputBps", reader.Throughput(),
+			"openFor", openFor.String(),
+		)
+	})
+	if rt.detectLeaks {
+		closer.detectLeak(func(name string, openFor time.Duration) {
+			rt.leaked.Add(context.Background(), 1, attrs)
+			obs.Log.Warn("Resource was never closed", "resource", name, "openFor", openFor.String())
+		})
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, closer}
+}
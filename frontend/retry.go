@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+)
+
+var (
+	EnvRetryMaxAttempts     = "DOWNSTREAM_RETRY_MAX_ATTEMPTS"
+	DefaultRetryMaxAttempts = "3"
+	EnvRetryBackoff         = "DOWNSTREAM_RETRY_BACKOFF"
+	DefaultRetryBackoff     = "100ms"
+	EnvRetryMaxBackoff      = "DOWNSTREAM_RETRY_MAX_BACKOFF"
+	DefaultRetryMaxBackoff  = "1s"
+	EnvRetryStatuses        = "DOWNSTREAM_RETRY_STATUSES"
+	DefaultRetryStatuses    = "502,503,504"
+)
+
+// retryPolicy retries the HTTP calls to the product and user services, which
+// are all idempotent GETs, when they fail to connect or get one of the
+// retryable statuses. The delay before attempt n+1 is drawn at random between
+// half and all of backoff*2^(n-1), capped at maxBackoff, so that clients
+// retrying at once do not hit a recovering service in lockstep.
+type retryPolicy struct {
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+	statuses    map[int]bool
+	hedge       *hedgePolicy // hedges each attempt; nil not to
+}
+
+// newRetryPolicy parses the policy's settings. maxAttempts counts the first
+// attempt, so "1" disables retries; statuses is a comma-separated list of
+// status codes, "none" for none.
+func newRetryPolicy(maxAttempts, backoff, maxBackoff, statuses string) (*retryPolicy, error) {
+	p := &retryPolicy{statuses: make(map[int]bool)}
+	var err error
+	if p.maxAttempts, err = strconv.Atoi(maxAttempts); err != nil || p.maxAttempts < 1 {
+		return nil, fmt.Errorf("invalid max attempts %q: must be a positive integer", maxAttempts)
+	}
+	if p.backoff, err = time.ParseDuration(backoff); err != nil || p.backoff < 0 {
+		return nil, fmt.Errorf("invalid backoff %q", backoff)
+	}
+	if p.maxBackoff, err = time.ParseDuration(maxBackoff); err != nil || p.maxBackoff < p.backoff {
+		return nil, fmt.Errorf("invalid max backoff %q: must be at least the backoff", maxBackoff)
+	}
+	for _, s := range strings.Split(statuses, ",") {
+		if s = strings.TrimSpace(s); s == "" || s == "none" {
+			continue
+		}
+		code, err := strconv.Atoi(s)
+		if err != nil || code < 100 || code > 599 {
+			return nil, fmt.Errorf("invalid retry status %q", s)
+		}
+		p.statuses[code] = true
+	}
+	return p, nil
+}
+
+// Do sends the request built by newRequest, hedged as set by p.hedge, and
+// sends a new one while the attempt fails in a retryable way and attempts remain. It
+// returns the outcome of the last attempt. Every attempt adds a
+// downstream.attempt event to the current span of ctx, with its number and
+// the backoff it waited for; after the first failure, the Debug logs of obs
+// are written for the rest of the span, whatever the log level.
+func (p *retryPolicy) Do(ctx context.Context, obs *observability.Observability, newRequest requestFunc) (*http.Response, error) {
+	var delay time.Duration
+	for attempt := 1; ; attempt++ {
+		obsmiddleware.AddEvent(ctx, "downstream.attempt", observability.SpanAttributes{
+			"retry.attempt":    attempt,
+			"retry.backoff_ms": delay.Milliseconds(),
+		})
+		resp, err := p.hedge.Send(ctx, obs, newRequest)
+		var retryable bool
+		if err != nil {
+			// A canceled or expired request is not worth another attempt.
+			retryable = ctx.Err() == nil
+		} else {
+			retryable = p.statuses[resp.StatusCode]
+		}
+		if !retryable || attempt >= p.maxAttempts {
+			return resp, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			// Drain the body so the connection can be reused.
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if attempt == 1 {
+			obsmiddleware.ElevateFor(obs, slog.LevelDebug)
+		}
+		delay = p.delay(attempt)
+		obsmiddleware.LogDebug(obs, "Retrying downstream call", "attempt", attempt, "reason", reason, "backoff", delay.String())
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, context.Cause(ctx)
+		}
+	}
+}
+
+// delay returns the jittered backoff after the given failed attempt.
+func (p *retryPolicy) delay(attempt int) time.Duration {
+	d := p.backoff
+	for i := 1; i < attempt && d < p.maxBackoff; i++ {
+		d *= 2
+	}
+	d = min(d, p.maxBackoff)
+	if d <= 0 {
+		return 0
+	}
+	return d/2 + rand.N(d/2+1)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/app-obs/go/observability"
+)
+
+var testFactory = observability.NewFactory(
+	observability.WithServiceName("frontend-test"),
+	observability.WithApmType("none"),
+	observability.WithMetricsType("none"),
+)
+
+func TestMain(m *testing.M) {
+	if _, err := testFactory.Setup(context.Background()); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// statusServer answers the nth request with statuses[n], or with the last
+// one once they run out, and counts the requests in hits.
+func statusServer(t *testing.T, hits *atomic.Int32, statuses ...int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(hits.Add(1)) - 1
+		w.WriteHeader(statuses[min(n, len(statuses)-1)])
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func getRequest(url string) requestFunc {
+	return func(ctx context.Context, _ *observability.Observability) (*http.Request, error) {
+		return http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	}
+}
+
+func TestNewRetryPolicy(t *testing.T) {
+	tests := []struct {
+		name                                     string
+		maxAttempts, backoff, maxBackoff, status string
+		wantErr                                  bool
+		wantStatuses                             int
+	}{
+		{"defaults", "3", "100ms", "1s", "502,503,504", false, 3},
+		{"no statuses", "3", "100ms", "1s", "none", false, 0},
+		{"spaces", "1", "0s", "0s", " 503 , ", false, 1},
+		{"zero attempts", "0", "100ms", "1s", "503", true, 0},
+		{"negative backoff", "3", "-1s", "1s", "503", true, 0},
+		{"max below backoff", "3", "1s", "100ms", "503", true, 0},
+		{"invalid status", "3", "100ms", "1s", "600", true, 0},
+		{"non-numeric status", "3", "100ms", "1s", "bad", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := newRetryPolicy(tt.maxAttempts, tt.backoff, tt.maxBackoff, tt.status)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newRetryPolicy error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && len(p.statuses) != tt.wantStatuses {
+				t.Errorf("statuses = %v, want %d of them", p.statuses, tt.wantStatuses)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := &retryPolicy{backoff: 100 * time.Millisecond, maxBackoff: time.Second}
+	tests := []struct {
+		attempt  int
+		min, max time.Duration
+	}{
+		{1, 50 * time.Millisecond, 100 * time.Millisecond},
+		{3, 200 * time.Millisecond, 400 * time.Millisecond},
+		{10, 500 * time.Millisecond, time.Second},
+	}
+	for _, tt := range tests {
+		for range 100 {
+			if d := p.delay(tt.attempt); d < tt.min || d > tt.max {
+				t.Fatalf("delay(%d) = %v, want between %v and %v", tt.attempt, d, tt.min, tt.max)
+			}
+		}
+	}
+	if d := (&retryPolicy{}).delay(1); d != 0 {
+		t.Errorf("delay without backoff = %v, want 0", d)
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	tests := []struct {
+		name         string
+		maxAttempts  string
+		statuses     []int
+		wantAttempts int32
+		wantStatus   int
+	}{
+		{"success", "3", []int{200}, 1, 200},
+		{"retried until success", "3", []int{503, 502, 200}, 3, 200},
+		{"attempts exhausted", "3", []int{503}, 3, 503},
+		{"status not retryable", "3", []int{500}, 1, 500},
+		{"retries disabled", "1", []int{503}, 1, 503},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var hits atomic.Int32
+			srv := statusServer(t, &hits, tt.statuses...)
+			p, err := newRetryPolicy(tt.maxAttempts, "0s", "0s", DefaultRetryStatuses)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+
+			resp, err := p.Do(ctx, testFactory.NewBackgroundObservability(ctx), getRequest(srv.URL))
+			if err != nil {
+				t.Fatalf("Do = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := hits.Load(); got != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", got, tt.wantAttempts)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyDoCanceled(t *testing.T) {
+	var hits atomic.Int32
+	srv := statusServer(t, &hits, http.StatusServiceUnavailable)
+	p, err := newRetryPolicy("3", "1s", "1s", DefaultRetryStatuses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := p.Do(ctx, testFactory.NewBackgroundObservability(ctx), getRequest(srv.URL)); err == nil {
+		t.Error("Do = nil, want the context's error")
+	}
+	if got := hits.Load(); got != 1 {
+		t.Errorf("attempts = %d, want 1", got)
+	}
+}
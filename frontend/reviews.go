@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"clients/productclient"
+	"obsmiddleware"
+)
+
+var (
+	EnvReviewsTimeout     = "REVIEWS_TIMEOUT"
+	DefaultReviewsTimeout = "1s"
+)
+
+// ReviewService fetches the reviews of products from the product service.
+type ReviewService interface {
+	GetReviews(ctx context.Context, productID string) (string, error)
+}
+
+type reviewServiceImpl struct {
+	sla     *slaTracker
+	timeout time.Duration // 0 for none
+	http    *productclient.Client
+}
+
+// NewReviewService calls the reviews endpoint of the product service over
+// HTTP with productclient, retrying as set by retry, whatever
+// DOWNSTREAM_PROTOCOL says, as it has no gRPC counterpart.
+// Reviews are optional, so their timeout is usually shorter than the product
+// service's: a slow review store should cost a page its reviews, not its
+// latency.
+func NewReviewService(sla *slaTracker, resources *resourceTracker, retry *retryPolicy, timeout time.Duration) ReviewService {
+	sender := &downstreamSender{retry: retry, resources: resources, body: "reviews.response.body"}
+	return &reviewServiceImpl{
+		sla:     sla,
+		timeout: timeout,
+		http:    productclient.New(productServiceURL, productclient.WithSender(sender)),
+	}
+}
+
+func (s *reviewServiceImpl) GetReviews(ctx context.Context, productID string) (string, error) {
+	ctx, cancel := withDependencyTimeout(ctx, "reviews", s.timeout)
+	defer cancel()
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ReviewService.GetReviews", observability.String("product.id", productID))
+	defer span.End()
+
+	start := time.Now()
+	reviews, err := callReviewService(ctx, obs, s.http, productID)
+	err = deadlineCause(ctx, err)
+	recordCall(ctx, start, time.Now())
+	s.sla.Observe(obs, span, "reviews", time.Since(start), err)
+	return reviews, err
+}
+
+func callReviewService(ctx context.Context, obs *observability.Observability, client *productclient.Client, productID string) (string, error) {
+	reviews, err := client.GetReviews(ctx, obs, productID)
+	if err != nil {
+		return "", clientStatusError(err)
+	}
+	return reviews.String(), nil
+}
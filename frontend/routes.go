@@ -0,0 +1,253 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/errgroup"
+
+	"clients"
+	"health"
+	"obsmiddleware"
+	"redisobs"
+	"servicekit"
+)
+
+// registerRoutes sets the downstream clients, caches and trackers up, and returns the frontend API.
+func registerRoutes(s *servicekit.Service) http.Handler {
+	// Selected tenants can have their traces sent to a second APM backend.
+	spans, secondaryShutdowner, err := setupTenantRouting(s.Factory, s.Obs)
+	if err != nil {
+		s.Fatal("Failed to setup tenant APM routing", "error", err)
+	}
+	s.Spans = spans
+	if secondaryShutdowner != nil {
+		s.AddTelemetry("secondaryAPM", secondaryShutdowner)
+	}
+
+	// Downstream SLAs are read from DEPENDENCY_SLA_FILE, or the embedded sla.json.
+	slaConfig, err := loadSLAConfig()
+	if err != nil {
+		s.Fatal("Failed to load dependency SLA config", "error", err)
+	}
+	sla, err := newSLATracker(s.Meter, slaConfig)
+	if err != nil {
+		s.Fatal("Failed to create dependency SLA tracker", "error", err)
+	}
+
+	// Leaked response bodies are only reported in development, where the
+	// finalizer overhead does not matter.
+	detectLeaks := servicekit.Getenv("OBS_ENVIRONMENT", "development") == "development"
+	resources, err := newResourceTracker(s.Meter, detectLeaks)
+	if err != nil {
+		s.Fatal("Failed to create resource tracker", "error", err)
+	}
+
+	// The services rely on the following environment variables to connect to backends:
+	// - PRODUCT_SERVICE_URL: The URL for the product service.
+	// - USER_SERVICE_URL: The URL for the user service.
+	// - DOWNSTREAM_PROTOCOL: "http" (default) or "grpc", to call them over
+	//   gRPC at PRODUCT_SERVICE_GRPC_ADDR and USER_SERVICE_GRPC_ADDR instead.
+	productClient, userClient, err := downstreamClients()
+	if err != nil {
+		s.Fatal("Failed to setup gRPC clients", "error", err)
+	}
+	// HTTP calls to them are retried as set by the DOWNSTREAM_RETRY_* variables.
+	retry, err := newRetryPolicy(
+		servicekit.Getenv(EnvRetryMaxAttempts, DefaultRetryMaxAttempts),
+		servicekit.Getenv(EnvRetryBackoff, DefaultRetryBackoff),
+		servicekit.Getenv(EnvRetryMaxBackoff, DefaultRetryMaxBackoff),
+		servicekit.Getenv(EnvRetryStatuses, DefaultRetryStatuses),
+	)
+	if err != nil {
+		s.Fatal("Invalid downstream retry policy", "error", err)
+	}
+	// Slow attempts are hedged after DOWNSTREAM_HEDGE_DELAY, if set.
+	if retry.hedge, err = newHedgePolicy(servicekit.Getenv(EnvHedgeDelay, "")); err != nil {
+		s.Fatal("Invalid downstream hedge delay", "error", err)
+	}
+	// Each dependency has its own timeout, passed on to it as a deadline.
+	productTimeout, err := parseDependencyTimeout(servicekit.Getenv(EnvProductServiceTimeout, DefaultDependencyTimeout))
+	if err != nil {
+		s.Fatal("Invalid product service timeout", "error", err)
+	}
+	userTimeout, err := parseDependencyTimeout(servicekit.Getenv(EnvUserServiceTimeout, DefaultDependencyTimeout))
+	if err != nil {
+		s.Fatal("Invalid user service timeout", "error", err)
+	}
+	productService := NewProductService(sla, resources, retry, productTimeout, productClient)
+	userService := NewUserService(sla, resources, retry, userTimeout, userClient)
+	reviewsTimeout, err := parseDependencyTimeout(servicekit.Getenv(EnvReviewsTimeout, DefaultReviewsTimeout))
+	if err != nil {
+		s.Fatal("Invalid reviews timeout", "error", err)
+	}
+	reviewService := NewReviewService(sla, resources, retry, reviewsTimeout)
+	// The frontend is not ready until the product service can be reached. User
+	// details are optional on product pages, so the user check is non-critical.
+	s.Checks.Register("product", health.HTTPChecker(productServiceURL, s.DependencyCheckTTL, s.DependencyCheckTimeout))
+	s.Checks.RegisterNonCritical("user", health.HTTPChecker(userServiceURL, s.DependencyCheckTTL, s.DependencyCheckTimeout))
+
+	// Product lookups are cached in Redis when REDIS_URL is set, and in
+	// memory, in front of Redis, when PRODUCT_CACHE_SIZE is above 0.
+	cacheTTL, err := time.ParseDuration(servicekit.Getenv(EnvProductCacheTTL, DefaultCacheTTL))
+	if err != nil {
+		s.Fatal("Invalid product cache TTL", "error", err)
+	}
+	cacheTelemetry, err := newCacheTelemetry(s.Meter)
+	if err != nil {
+		s.Fatal("Failed to create cache telemetry", "error", err)
+	}
+	if redisURL := os.Getenv(EnvRedisURL); redisURL != "" {
+		redisOpts, err := redis.ParseURL(redisURL)
+		if err != nil {
+			s.Fatal("Invalid Redis URL", "error", err)
+		}
+		redisHook, err := redisobs.NewHook(s.Obs)
+		if err != nil {
+			s.Fatal("Failed to create Redis hook", "error", err)
+		}
+
+		redisClient := redis.NewClient(redisOpts)
+		s.OnShutdown(func() { redisClient.Close() })
+		redisClient.AddHook(redisHook)
+		s.Checks.RegisterNonCritical("redis", health.CheckerFunc(func(ctx context.Context) error {
+			return redisClient.Ping(ctx).Err()
+		}))
+		productService = NewCachedProductService(productService, redisClient, cacheTTL, cacheTelemetry)
+		s.Obs.Log.Info("Product cache enabled", "ttl", cacheTTL.String())
+	}
+	if size := productCacheSize(); size > 0 {
+		productService = NewMemoryProductCache(productService, size, cacheTTL, cacheTelemetry)
+		s.Obs.Log.Info("In-memory product cache enabled", "size", size, "ttl", cacheTTL.String())
+	}
+
+	// Only the keys of FRONTEND_API_KEYS get a quota and a rate limit of
+	// their own.
+	keys := newAPIKeys(servicekit.Getenv(EnvAPIKeys, ""))
+	quota, err := newQuotaTracker(s.Meter, apiQuotaPerDay(), keys)
+	if err != nil {
+		s.Fatal("Failed to create API quota tracker", "error", err)
+	}
+	exps, err := newExperiments(s.Meter, servicekit.Getenv(EnvExperiments, ""))
+	if err != nil {
+		s.Fatal("Invalid experiments", "error", err)
+	}
+	if len(exps.list) > 0 {
+		s.Obs.Log.Info("Experiments running", "experiments", len(exps.list))
+	}
+
+	streamInterval, err := time.ParseDuration(servicekit.Getenv(EnvProductStreamInterval, DefaultProductStreamInterval))
+	if err != nil || streamInterval <= 0 {
+		s.Fatal("Invalid product stream interval", "error", err)
+	}
+
+	// Clients are limited to RATE_LIMIT requests per second, if set.
+	limiter, err := newRateLimiter(s.Factory, keys)
+	if err != nil {
+		s.Fatal("Invalid rate limit", "error", err)
+	}
+
+	mux := http.NewServeMux()
+	s.Handle(mux, "GET /product-detail", quota.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleProductDetail(r.Context(), w, r, observability.ObsFromCtx(r.Context()), productService, userService, reviewService, exps)
+	})))
+	s.Handle(mux, "POST /graphql", quota.Middleware(newGraphQLHandler(productService, userService)))
+	s.Handle(mux, "GET /products", quota.Middleware(newProductsHandler(productService, productFanOutLimit())))
+	s.Handle(mux, "GET /product-stream", quota.Middleware(newProductStreamHandler(productService, streamInterval)))
+	mux.HandleFunc("GET /usage", quota.HandleUsage)
+	mux.HandleFunc("GET /{$}", handleIndex)
+
+	// With SESSION_SERVICE_URL set, session cookies are checked and their
+	// session ID carried in baggage.
+	sessions := newSessionValidator()
+	// The caller's bearer token is forwarded to the user service, which
+	// requires one when JWT_SECRET is set.
+	api := sessions.Middleware(trackOrchestration(clients.ForwardAuthorization(obsmiddleware.WithRoute(mux))))
+	if limiter != nil {
+		api = limiter.Middleware(api)
+	}
+	return api
+}
+
+// handleProductDetail now centralizes all error handling logic.
+func handleProductDetail(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	productService ProductService, userService UserService, reviewService ReviewService,
+	exps *experiments) {
+	productID := r.URL.Query().Get("id")
+
+	if productID == "" {
+		obs.ErrorHandler.HTTP(w, "Missing product ID", http.StatusBadRequest)
+		return
+	}
+
+	obsmiddleware.LogDebug(obs, "Searching for product info", "productID", productID)
+
+	userID := "user123" // Example user ID
+	if span, ok := obsmiddleware.SpanFromContext(ctx); ok {
+		ctx = exps.Assign(ctx, span, userID)
+	}
+
+	// The product, the user and the reviews are fetched at once, so the page
+	// waits for the slowest of them rather than for each in turn. Their spans
+	// start from ctx in their own goroutines and end up side by side under
+	// the request span. The product is required: if it fails, the group's
+	// context cancels the other fetches. The user and the reviews are
+	// optional, so their errors are kept out of the group.
+	var productInfo, userInfo, reviews string
+	var optionalErr error
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		productInfo, err = productService.GetProductInfo(gctx, productID)
+		return err
+	})
+	g.Go(func() error {
+		optionalErr = fetchOptional(gctx, map[string]func(context.Context) error{
+			"user": func(ctx context.Context) (err error) {
+				userInfo, err = userService.GetUserInfo(ctx, userID)
+				return err
+			},
+			"reviews": func(ctx context.Context) (err error) {
+				reviews, err = reviewService.GetReviews(ctx, productID)
+				return err
+			},
+		})
+		return nil
+	})
+	if err := g.Wait(); err != nil {
+		if isNotFound(err) {
+			obs.ErrorHandler.HTTP(w, "Product not found", http.StatusNotFound)
+			return
+		}
+		if isTimeout(err) {
+			obs.ErrorHandler.HTTP(w, "Product service timed out", http.StatusGatewayTimeout)
+			return
+		}
+		obs.ErrorHandler.HTTP(w, "Failed to fetch product info", http.StatusInternalServerError)
+		return
+	}
+
+	// The remaining details are optional: the page is served without them.
+	if optionalErr != nil {
+		if span, ok := obsmiddleware.SpanFromContext(ctx); ok {
+			recordErrors(obs, span, optionalErr, "Optional product details unavailable")
+		}
+	}
+	if userInfo == "" {
+		userInfo = "User info not available"
+	}
+	if reviews == "" {
+		reviews = "Reviews not available\n"
+	}
+
+	obs.Log.Info("Product and user info fetched successfully", "productInfo", productInfo, "userInfo", userInfo)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "Detail Produk ID %s:\n%s\nInfo Pengguna:\n%s\nUlasan:\n%s", productID, productInfo, userInfo, reviews)
+}
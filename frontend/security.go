@@ -0,0 +1,119 @@
+package main
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"obsmiddleware"
+)
+
+// ocsfClass is an event class of the Open Cybersecurity Schema Framework.
+type ocsfClass struct {
+	uid         int
+	name        string
+	categoryUID int
+}
+
+var (
+	classAuthentication = ocsfClass{uid: 3002, name: "Authentication", categoryUID: 3}
+	classAPIActivity    = ocsfClass{uid: 6003, name: "API Activity", categoryUID: 6}
+)
+
+// OCSF severities used by the security events.
+const (
+	severityLow    = 2
+	severityMedium = 3
+)
+
+var severityNames = map[int]string{
+	severityLow:    "Low",
+	severityMedium: "Medium",
+}
+
+// securityEvents emits security events with the same fields whichever part
+// of the service raises them. The fields follow OCSF closely enough for a
+// SIEM to pick them out of the logs with one rule. Each event is logged as a
+// warning and added as an event to the request span. Events describe
+// requests that were refused, so the span status is left alone.
+type securityEvents struct {
+	obs *observability.Observability
+}
+
+// security returns the security events of the request obs belongs to.
+func security(obs *observability.Observability) securityEvents {
+	return securityEvents{obs: obs}
+}
+
+// AuthFailure records that r failed to authenticate as user for reason.
+// user must identify the principal without being a credential: an ID or a
+// key hash, never a token or password.
+func (s securityEvents) AuthFailure(r *http.Request, user, reason string) {
+	args := s.event(r, "security.auth_failure", classAuthentication, "Logon", severityMedium, reason,
+		attribute.String("user.uid", user),
+	)
+	s.obs.Log.Logc(slog.LevelWarn, 3, "Authentication failed", args...)
+}
+
+// AccessDenied records that user, once authenticated, was refused access to
+// resource for reason.
+func (s securityEvents) AccessDenied(r *http.Request, user, resource, reason string) {
+	args := s.event(r, "security.access_denied", classAPIActivity, "Access Denied", severityMedium, reason,
+		attribute.String("user.uid", user),
+		attribute.String("resource.name", resource),
+	)
+	s.obs.Log.Logc(slog.LevelWarn, 3, "Access denied", args...)
+}
+
+// RateLimited records that the caller with API key keyID was refused for
+// going over limit.
+func (s securityEvents) RateLimited(r *http.Request, keyID string, limit int64) {
+	args := s.event(r, "security.rate_limited", classAPIActivity, "Rate Limit", severityLow, "quota exceeded",
+		attribute.String("api.key_id", keyID),
+		attribute.Int64("rate_limit.limit", limit),
+	)
+	s.obs.Log.Logc(slog.LevelWarn, 3, "Rate limit exceeded", args...)
+}
+
+// event adds the span event for a security event and returns its fields as
+// log arguments. The callers log them themselves, so that the log source
+// points at the code that raised the event.
+func (s securityEvents) event(r *http.Request, name string, class ocsfClass, activity string, severity int, detail string, extra ...attribute.KeyValue) []any {
+	attrs := append([]attribute.KeyValue{
+		attribute.String("event.kind", "security"),
+		attribute.Int("class_uid", class.uid),
+		attribute.String("class_name", class.name),
+		attribute.Int("category_uid", class.categoryUID),
+		attribute.String("activity_name", activity),
+		attribute.Int("severity_id", severity),
+		attribute.String("severity", severityNames[severity]),
+		attribute.String("status", "Failure"),
+		attribute.String("status_detail", detail),
+		attribute.String("src_endpoint.ip", clientIP(r)),
+		attribute.String("http_request.http_method", r.Method),
+		attribute.String("http_request.url.path", r.URL.Path),
+	}, extra...)
+
+	if span, ok := obsmiddleware.SpanFromContext(r.Context()); ok {
+		span.AddEvent(name, trace.WithAttributes(attrs...))
+	}
+	args := make([]any, 0, 2*len(attrs))
+	for _, a := range attrs {
+		args = append(args, string(a.Key), a.Value.AsInterface())
+	}
+	return args
+}
+
+// clientIP returns the address r came from. Forwarding headers are ignored:
+// they are set by the caller, so they cannot be trusted in a security event.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
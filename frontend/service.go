@@ -2,16 +2,27 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"time"
 
 	"github.com/app-obs/go/observability"
+
+	"apierror"
+	"clients"
+	"clients/productclient"
+	"clients/userclient"
+	"obsmiddleware"
+	"proto/productpb"
+	"proto/userpb"
+
+	"servicekit"
 )
 
 var (
-	productServiceURL = getEnvOrDefault("PRODUCT_SERVICE_URL", "http://product-service:8086")
-	userServiceURL    = getEnvOrDefault("USER_SERVICE_URL", "http://user-service:8087")
+	productServiceURL = servicekit.Getenv("PRODUCT_SERVICE_URL", "http://product-service:8086")
+	userServiceURL    = servicekit.Getenv("USER_SERVICE_URL", "http://user-service:8087")
 )
 
 type ProductService interface {
@@ -22,78 +33,161 @@ type UserService interface {
 	GetUserInfo(ctx context.Context, userID string) (string, error)
 }
 
+// statusError is returned when a downstream service answers with a non-200 status.
+type statusError struct {
+	service    string
+	statusCode int
+	code       apierror.Code // of the error response; "" to derive it from statusCode
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("%s service returned status %d", e.service, e.statusCode)
+}
+
+// errorCode returns the code of the downstream error response.
+func (e *statusError) errorCode() apierror.Code {
+	if e.code != "" {
+		return e.code
+	}
+	return apierror.CodeForStatus(e.statusCode)
+}
+
+// isClientError reports whether err is a 4xx answer from a downstream service.
+func isClientError(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.statusCode >= 400 && se.statusCode < 500
+}
+
+// isNotFound reports whether err is a downstream service's NOT_FOUND error.
+func isNotFound(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.errorCode() == apierror.NotFound
+}
+
 // Implementation for calling external services
 
-type productServiceImpl struct{}
+type productServiceImpl struct {
+	sla     *slaTracker
+	timeout time.Duration                  // 0 for none
+	http    *productclient.Client          // used when grpc is nil
+	grpc    productpb.ProductServiceClient // nil to call over HTTP
+}
 
 func (s *productServiceImpl) GetProductInfo(ctx context.Context, productID string) (string, error) {
-	ctx, obs, span := observability.StartSpanFromCtx(ctx, "ProductService.GetProductInfo", observability.SpanAttributes{"product.id": productID})
+	ctx, cancel := withDependencyTimeout(ctx, "product", s.timeout)
+	defer cancel()
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ProductService.GetProductInfo", observability.String("product.id", productID))
 	defer span.End()
-	return callProductService(ctx, obs, productID)
+
+	start := time.Now()
+	var productInfo string
+	var err error
+	if s.grpc != nil {
+		productInfo, err = callProductServiceGRPC(ctx, s.grpc, productID)
+	} else {
+		productInfo, err = callProductService(ctx, obs, s.http, productID)
+	}
+	err = deadlineCause(ctx, err)
+	recordCall(ctx, start, time.Now())
+	s.sla.Observe(obs, span, "product", time.Since(start), err)
+	return productInfo, err
 }
 
-type userServiceImpl struct{}
+type userServiceImpl struct {
+	sla     *slaTracker
+	timeout time.Duration            // 0 for none
+	http    *userclient.Client       // used when grpc is nil
+	grpc    userpb.UserServiceClient // nil to call over HTTP
+}
 
 func (s *userServiceImpl) GetUserInfo(ctx context.Context, userID string) (string, error) {
-	ctx, obs, span := observability.StartSpanFromCtx(ctx, "UserService.GetUserInfo", observability.SpanAttributes{"user.id": userID})
+	ctx, cancel := withDependencyTimeout(ctx, "user", s.timeout)
+	defer cancel()
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "UserService.GetUserInfo", observability.String("user.id", userID))
 	defer span.End()
-	return callUserService(ctx, obs, userID)
-}
 
-func NewProductService() ProductService {
-	return &productServiceImpl{}
+	start := time.Now()
+	var userInfo string
+	var err error
+	if s.grpc != nil {
+		userInfo, err = callUserServiceGRPC(ctx, s.grpc, userID)
+	} else {
+		userInfo, err = callUserService(ctx, obs, s.http, userID)
+	}
+	err = deadlineCause(ctx, err)
+	recordCall(ctx, start, time.Now())
+	s.sla.Observe(obs, span, "user", time.Since(start), err)
+	return userInfo, err
 }
 
-func NewUserService() UserService {
-	return &userServiceImpl{}
+// NewProductService calls the product service over gRPC through client, or
+// over HTTP with productclient, retrying as set by retry, if client is nil.
+// Each call, retries included, must be answered within timeout, unless it is
+// zero.
+func NewProductService(sla *slaTracker, resources *resourceTracker, retry *retryPolicy, timeout time.Duration, client productpb.ProductServiceClient) ProductService {
+	sender := &downstreamSender{retry: retry, resources: resources, body: "product.response.body"}
+	return &productServiceImpl{
+		sla:     sla,
+		timeout: timeout,
+		http:    productclient.New(productServiceURL, productclient.WithSender(sender)),
+		grpc:    client,
+	}
 }
 
-func callProductService(ctx context.Context, obs *observability.Observability, productID string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/product?id=%s", productServiceURL, productID), nil)
-	if err != nil {
-		return "", err
+// NewUserService calls the user service over gRPC through client, or over
+// HTTP with userclient, retrying as set by retry, if client is nil, within
+// timeout as for NewProductService.
+func NewUserService(sla *slaTracker, resources *resourceTracker, retry *retryPolicy, timeout time.Duration, client userpb.UserServiceClient) UserService {
+	sender := &downstreamSender{retry: retry, resources: resources, body: "user.response.body"}
+	return &userServiceImpl{
+		sla:     sla,
+		timeout: timeout,
+		http:    userclient.New(userServiceURL, userclient.WithSender(sender)),
+		grpc:    client,
 	}
-	obs.Trace.InjectHTTP(req)
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func callProductService(ctx context.Context, obs *observability.Observability, client *productclient.Client, productID string) (string, error) {
+	product, err := client.GetProduct(ctx, obs, productID)
 	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("product service returned status %d", resp.StatusCode)
+		return "", clientStatusError(err)
 	}
+	return product.String(), nil
+}
 
-	body, err := io.ReadAll(resp.Body)
+func callUserService(ctx context.Context, obs *observability.Observability, client *userclient.Client, userID string) (string, error) {
+	user, err := client.GetUser(ctx, obs, userID)
 	if err != nil {
-		return "", err
+		return "", clientStatusError(err)
 	}
-	return string(body), nil
+	return user.String(), nil
 }
 
-func callUserService(ctx context.Context, obs *observability.Observability, userID string) (string, error) {
-	req, err := http.NewRequestWithContext(ctx, "GET", fmt.Sprintf("%s/user?id=%s", userServiceURL, userID), nil)
-	if err != nil {
-		return "", err
-	}
-	obs.Trace.InjectHTTP(req)
+// downstreamSender sends the requests of the product and user clients with
+// the frontend's retry policy, hedging included, instead of the clients' own,
+// and tracks their response bodies as body.
+type downstreamSender struct {
+	retry     *retryPolicy
+	resources *resourceTracker
+	body      string
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+func (s *downstreamSender) Send(ctx context.Context, obs *observability.Observability, newRequest clients.RequestFunc) (*http.Response, error) {
+	resp, err := s.retry.Do(ctx, obs, requestFunc(newRequest))
 	if err != nil {
-		return "", err
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("user service returned status %d", resp.StatusCode)
+		return nil, err
 	}
+	resp.Body = s.resources.WrapBody(obs, s.body, resp.Body)
+	return resp, nil
+}
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return "", err
+// clientStatusError turns the StatusError of a client call into the
+// statusError the rest of the frontend handles, like grpcStatusError does for
+// gRPC calls, keeping the code of the error response.
+func clientStatusError(err error) error {
+	var se *clients.StatusError
+	if errors.As(err, &se) {
+		return &statusError{service: se.Service, statusCode: se.StatusCode, code: se.Code}
 	}
-	return string(body), nil
+	return err
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/baggage"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var EnvSessionServiceURL = "SESSION_SERVICE_URL"
+
+// sessionCookie is the cookie in which the session service sends the
+// session ID.
+const sessionCookie = "session_id"
+
+// sessionValidator checks the session cookies of requests with the session
+// service. A nil *sessionValidator ignores them.
+type sessionValidator struct {
+	url string
+}
+
+// newSessionValidator returns the validator for the session service at
+// SESSION_SERVICE_URL, or nil if it is not set.
+func newSessionValidator() *sessionValidator {
+	url := servicekit.Getenv(EnvSessionServiceURL, "")
+	if url == "" {
+		return nil
+	}
+	return &sessionValidator{url: url}
+}
+
+// Middleware makes the session of a request with a valid session cookie the
+// session.id baggage member, so every span and log record of the journey, in
+// this service and the ones it calls, carries it. It wins over a session ID
+// sent by a RUM SDK. The request span and the request's log records get
+// session.id as well. A request whose cookie is invalid, or that the session
+// service cannot check, goes on without a session: sessions only label
+// telemetry here, they do not guard anything.
+func (v *sessionValidator) Middleware(next http.Handler) http.Handler {
+	if v == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(sessionCookie)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		id, err := v.validate(ctx, cookie)
+		if err != nil {
+			observability.ObsFromCtx(ctx).Log.Info("Session cookie not validated", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		member, err := baggage.NewMemberRaw("session.id", id)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		bag, err := baggage.FromContext(ctx).SetMember(member)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if span, ok := obsmiddleware.SpanFromContext(ctx); ok {
+			span.SetAttributes(observability.String("session.id", id))
+		}
+		obs := observability.ObsFromCtx(ctx)
+		obs.Log = obs.Log.With("session.id", id)
+		next.ServeHTTP(w, r.WithContext(baggage.ContextWithBaggage(ctx, bag)))
+	})
+}
+
+// validate asks the session service for the session of cookie, in a
+// SessionService.Validate span, and returns its ID.
+func (v *sessionValidator) validate(ctx context.Context, cookie *http.Cookie) (string, error) {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "SessionService.Validate")
+	defer span.End()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", v.url+"/sessions/current", nil)
+	if err != nil {
+		return "", err
+	}
+	req.AddCookie(cookie)
+	obs.Trace.InjectHTTP(req)
+	resp, err := sendDownstream(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &statusError{service: "session", statusCode: resp.StatusCode}
+	}
+
+	var session struct {
+		ID string `json:"sessionId"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&session); err != nil {
+		return "", err
+	}
+	span.SetAttributes(observability.String("session.id", session.ID))
+	return session.ID, nil
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+var EnvDependencySLAFile = "DEPENDENCY_SLA_FILE"
+
+// defaultSLAConfig is used when DEPENDENCY_SLA_FILE is not set.
+//
+//go:embed sla.json
+var defaultSLAConfig []byte
+
+// dependencySLA declares the service level a downstream dependency promises.
+type dependencySLA struct {
+	// MaxLatencyMs is the latency every call is expected to stay under.
+	MaxLatencyMs int64 `json:"max_latency_ms"`
+	// MinLatencyCompliance is the minimum ratio of calls that must stay under
+	// MaxLatencyMs, from 0 to 1 (e.g. 0.95 for a p95 target).
+	MinLatencyCompliance float64 `json:"min_latency_compliance"`
+	// MinAvailability is the minimum ratio of successful calls, from 0 to 1.
+	MinAvailability float64 `json:"min_availability"`
+}
+
+// slaConfig is the on-disk format of the dependency SLA file.
+type slaConfig struct {
+	// WindowSize is the number of most recent calls compliance is computed over.
+	WindowSize   int                      `json:"window_size"`
+	Dependencies map[string]dependencySLA `json:"dependencies"`
+}
+
+// loadSLAConfig reads the SLA file named by DEPENDENCY_SLA_FILE, falling back
+// to the embedded defaults.
+func loadSLAConfig() (slaConfig, error) {
+	data := defaultSLAConfig
+	if path := os.Getenv(EnvDependencySLAFile); path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return slaConfig{}, fmt.Errorf("failed to read SLA file: %w", err)
+		}
+	}
+
+	var cfg slaConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return slaConfig{}, fmt.Errorf("failed to parse SLA file: %w", err)
+	}
+	if cfg.WindowSize <= 0 {
+		return slaConfig{}, errors.New("SLA window_size must be positive")
+	}
+	return cfg, nil
+}
+
+// callOutcome is a single observed call to a dependency.
+type callOutcome struct {
+	failed bool
+	slow   bool
+}
+
+// dependencyWindow keeps the outcomes of the most recent calls to one dependency.
+type dependencyWindow struct {
+	sla      dependencySLA
+	outcomes []callOutcome
+	next     int
+	full     bool
+}
+
+// compliance returns the availability and latency compliance ratios over the window.
+func (w *dependencyWindow) compliance() (availability, latency float64, ok bool) {
+	n := w.next
+	if w.full {
+		n = len(w.outcomes)
+	}
+	if n == 0 {
+		return 0, 0, false
+	}
+	var failed, slow int
+	for _, o := range w.outcomes[:n] {
+		if o.failed {
+			failed++
+		}
+		if o.slow {
+			slow++
+		}
+	}
+	return 1 - float64(failed)/float64(n), 1 - float64(slow)/float64(n), true
+}
+
+// slaTracker tracks per-dependency availability and latency against the
+// declared SLAs, exports compliance gauges, and marks spans of calls that
+// violate their dependency's SLA.
+type slaTracker struct {
+	mu      sync.Mutex
+	windows map[string]*dependencyWindow
+}
+
+// newSLATracker creates a tracker for the configured dependencies and
+// registers its compliance gauges.
+func newSLATracker(meter metric.Meter, cfg slaConfig) (*slaTracker, error) {
+	t := &slaTracker{windows: make(map[string]*dependencyWindow, len(cfg.Dependencies))}
+	for name, sla := range cfg.Dependencies {
+		t.windows[name] = &dependencyWindow{sla: sla, outcomes: make([]callOutcome, cfg.WindowSize)}
+	}
+
+	availability, err := meter.Float64ObservableGauge("dependency.sla.availability",
+		metric.WithDescription("Ratio of successful calls to the dependency over the SLA window"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	latency, err := meter.Float64ObservableGauge("dependency.sla.latency_compliance",
+		metric.WithDescription("Ratio of calls to the dependency within its latency SLA over the SLA window"),
+		metric.WithUnit("1"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	compliant, err := meter.Int64ObservableGauge("dependency.sla.compliant",
+		metric.WithDescription("1 if the dependency currently meets its SLA, 0 otherwise"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		for name, w := range t.windows {
+			avail, lat, ok := w.compliance()
+			if !ok {
+				continue
+			}
+			attrs := metric.WithAttributes(attribute.String("dependency", name))
+			o.ObserveFloat64(availability, avail, attrs)
+			o.ObserveFloat64(latency, lat, attrs)
+			meets := int64(0)
+			if avail >= w.sla.MinAvailability && lat >= w.sla.MinLatencyCompliance {
+				meets = 1
+			}
+			o.ObserveInt64(compliant, meets, attrs)
+		}
+		return nil
+	}, availability, latency, compliant)
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Observe records the outcome of a call to dependency and marks span if the
+// call violated the dependency's SLA. Client errors (4xx) do not count
+// against availability. Unknown dependencies are ignored.
+func (t *slaTracker) Observe(obs *observability.Observability, span observability.Span, dependency string, elapsed time.Duration, err error) {
+	t.mu.Lock()
+	w, ok := t.windows[dependency]
+	if !ok {
+		t.mu.Unlock()
+		return
+	}
+	outcome := callOutcome{
+		failed: err != nil && !isClientError(err),
+		slow:   elapsed.Milliseconds() > w.sla.MaxLatencyMs,
+	}
+	w.outcomes[w.next] = outcome
+	w.next++
+	if w.next == len(w.outcomes) {
+		w.next, w.full = 0, true
+	}
+	avail, _, _ := w.compliance()
+	sla := w.sla
+	t.mu.Unlock()
+
+	var violation string
+	switch {
+	case outcome.failed && avail < sla.MinAvailability:
+		violation = "availability"
+	case outcome.slow:
+		violation = "latency"
+	default:
+		return
+	}
+
+	span.SetAttributes(
+		observability.Bool("sla.violated", true),
+		observability.String("sla.violation", violation),
+	)
+	obs.Log.Warn("Dependency SLA violated",
+		"dependency", dependency,
+		"violation", violation,
+		"elapsedMs", elapsed.Milliseconds(),
+		"maxLatencyMs", sla.MaxLatencyMs,
+		"availability", avail,
+		"minAvailability", sla.MinAvailability,
+	)
+}
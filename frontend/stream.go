@@ -0,0 +1,183 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"obsmiddleware"
+)
+
+var (
+	EnvProductStreamInterval     = "PRODUCT_STREAM_INTERVAL"
+	DefaultProductStreamInterval = "1s"
+)
+
+// Bounds of the count query parameter of GET /product-stream.
+const (
+	defaultStreamEvents = 10
+	maxStreamEvents     = 100
+)
+
+// productStreamEvent is the data of a "product" event of the product stream.
+type productStreamEvent struct {
+	ProductID string `json:"productId"`
+	Info      string `json:"info"`
+}
+
+// newProductStreamHandler serves GET /product-stream?id=...&count=...: a
+// Server-Sent Events stream that sends the product's info count times, every
+// interval. The request span gets an sse.chunk.flushed event per event sent.
+// A client that disconnects early cancels the request context, which ends
+// the stream and is recorded on the span as sse.client.disconnected; it is
+// not an error. A stream cut short by the caller's deadline, set with
+// obsmiddleware.TimeoutHeader, is recorded as sse.deadline.exceeded instead.
+func newProductStreamHandler(productService ProductService, interval time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		obs := observability.ObsFromCtx(ctx)
+		productID := r.URL.Query().Get("id")
+		if productID == "" {
+			obs.ErrorHandler.HTTP(w, "Missing product ID", http.StatusBadRequest)
+			return
+		}
+		count := defaultStreamEvents
+		if v := r.URL.Query().Get("count"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 1 || n > maxStreamEvents {
+				obs.ErrorHandler.HTTP(w, fmt.Sprintf("count must be between 1 and %d", maxStreamEvents), http.StatusBadRequest)
+				return
+			}
+			count = n
+		}
+
+		// Fetch the first event before answering, so an unknown product gets
+		// a 404 rather than an empty stream.
+		info, err := productService.GetProductInfo(ctx, productID)
+		if err != nil {
+			if isNotFound(err) {
+				obs.ErrorHandler.HTTP(w, "Product not found", http.StatusNotFound)
+			} else if isTimeout(err) {
+				obs.ErrorHandler.HTTP(w, "Product service timed out", http.StatusGatewayTimeout)
+			} else {
+				obs.ErrorHandler.HTTP(w, "Failed to fetch product info", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		// The stream outlives the server's WriteTimeout, so lift it.
+		rc := http.NewResponseController(w)
+		if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+			obs.ErrorHandler.Record(err, "Failed to lift the write deadline of the product stream")
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		span, _ := obsmiddleware.SpanFromContext(ctx)
+		stream := &sseStream{w: w, rc: rc, span: span}
+		defer func() {
+			if span != nil {
+				span.SetAttributes(attribute.Int("sse.events.sent", stream.sent))
+			}
+		}()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			if err := stream.Send("product", productStreamEvent{ProductID: productID, Info: info}); err != nil {
+				obs.ErrorHandler.Record(err, "Failed to write to the product stream")
+				return
+			}
+			if stream.sent == count {
+				obs.Log.Info("Product stream completed", "productID", productID, "events", stream.sent)
+				return
+			}
+
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				stream.Closed(ctx, obs, productID)
+				return
+			}
+
+			info, err = productService.GetProductInfo(ctx, productID)
+			if err != nil {
+				if ctx.Err() != nil {
+					stream.Closed(ctx, obs, productID)
+					return
+				}
+				// The status is sent already; tell the client in the stream.
+				obs.ErrorHandler.Record(err, "Failed to fetch product info for the product stream")
+				stream.Send("error", map[string]string{"error": "Failed to fetch product info"})
+				return
+			}
+		}
+	})
+}
+
+// sseStream writes Server-Sent Events and records each one it flushes as an
+// event on the request span.
+type sseStream struct {
+	w    http.ResponseWriter
+	rc   *http.ResponseController
+	span observability.Span // nil outside of obsmiddleware.WithObservability
+	sent int
+}
+
+// Send writes one event named event with data as JSON, and flushes it.
+func (s *sseStream) Send(event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	n, err := fmt.Fprintf(s.w, "id: %d\nevent: %s\ndata: %s\n\n", s.sent+1, event, payload)
+	if err != nil {
+		return err
+	}
+	if err := s.rc.Flush(); err != nil {
+		return err
+	}
+	s.sent++
+	if s.span != nil {
+		s.span.AddEvent("sse.chunk.flushed", trace.WithAttributes(
+			attribute.Int("sse.event.id", s.sent),
+			attribute.String("sse.event.type", event),
+			attribute.Int("sse.chunk.size", n),
+		))
+	}
+	return nil
+}
+
+// Closed records why the done request context ctx ended the stream of
+// productID early: the caller's deadline expired, or the client went away.
+func (s *sseStream) Closed(ctx context.Context, obs *observability.Observability, productID string) {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		s.event("sse.deadline.exceeded", context.Cause(ctx))
+		obs.Log.Warn("Product stream timed out", "productID", productID, "events", s.sent, "error", context.Cause(ctx))
+		return
+	}
+	s.event("sse.client.disconnected", ctx.Err())
+	obs.Log.Info("Product stream closed by the client", "productID", productID, "events", s.sent)
+}
+
+// event records on the request span that the stream ended early as name,
+// with err, and sets name to true.
+func (s *sseStream) event(name string, err error) {
+	if s.span == nil {
+		return
+	}
+	s.span.AddEvent(name, trace.WithAttributes(
+		attribute.String("error.message", err.Error()),
+		attribute.Int("sse.events.sent", s.sent),
+	))
+	s.span.SetAttributes(attribute.Bool(name, true))
+}
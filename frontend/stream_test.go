@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// productServiceFunc is a ProductService answering with a function.
+type productServiceFunc func(ctx context.Context, productID string) (string, error)
+
+func (f productServiceFunc) GetProductInfo(ctx context.Context, productID string) (string, error) {
+	return f(ctx, productID)
+}
+
+func TestProductStreamFirstLookup(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"found", nil, http.StatusOK},
+		{"not found", &statusError{service: "product", statusCode: http.StatusNotFound}, http.StatusNotFound},
+		{"invalid ID", &statusError{service: "product", statusCode: http.StatusBadRequest}, http.StatusInternalServerError},
+		{"unauthorized", &statusError{service: "product", statusCode: http.StatusUnauthorized}, http.StatusInternalServerError},
+		{"timeout", context.DeadlineExceeded, http.StatusGatewayTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products := productServiceFunc(func(context.Context, string) (string, error) {
+				return "Laptop with ID 1", tt.err
+			})
+			rec := httptest.NewRecorder()
+			newProductStreamHandler(products, time.Millisecond).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/product-stream?id=1&count=1", nil))
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestProductStreamDeadline(t *testing.T) {
+	products := productServiceFunc(func(context.Context, string) (string, error) {
+		return "Laptop with ID 1", nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/product-stream?id=1&count=3", nil).WithContext(ctx)
+	rec := httptest.NewRecorder()
+
+	newProductStreamHandler(products, time.Second).ServeHTTP(rec, req)
+	if got := strings.Count(rec.Body.String(), "event: product\n"); got != 1 {
+		t.Errorf("product events = %d, want 1 before the deadline", got)
+	}
+	if strings.Contains(rec.Body.String(), "event: error\n") {
+		t.Errorf("body = %q, want no error event for the deadline", rec.Body.String())
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvSecondaryAPMType    = "SECONDARY_APM_TYPE"
+	EnvSecondaryAPMURL     = "SECONDARY_APM_URL"
+	EnvSecondaryAPMTenants = "SECONDARY_APM_TENANTS"
+)
+
+// TenantHeader identifies the tenant a request is made for.
+const TenantHeader = "X-Tenant-ID"
+
+// tenantRouter sends the traces of selected tenants to a second APM backend,
+// for migrations where some tenants' telemetry must already land in the new
+// system while the rest stays in the old one. Both backends come from
+// factories of the same library, so handlers see the same Span and
+// Observability types whichever backend a request is routed to.
+//
+// Only the default build (no APM build tag) contains both tracers. Logs are
+// correlated with the primary backend only, since the library configures a
+// single logger per process. Routed tenants' traces end at this service: the
+// downstream services keep tracing with their own backend.
+type tenantRouter struct {
+	primary     *observability.Factory
+	secondary   *observability.Factory
+	primaryType string
+	routedType  string
+	tenants     map[string]bool
+}
+
+var _ obsmiddleware.SpanStarter = (*tenantRouter)(nil)
+
+// StartSpanFromRequest starts the request span with the backend of the
+// request's tenant and tags the span with both.
+func (t *tenantRouter) StartSpanFromRequest(r *http.Request, customAttrs ...observability.SpanAttributes) (*http.Request, context.Context, observability.Span, *observability.Observability) {
+	tenant := r.Header.Get(TenantHeader)
+	factory, apmType := t.primary, t.primaryType
+	if t.tenants[tenant] {
+		factory, apmType = t.secondary, t.routedType
+		// Child spans started with obsmiddleware.StartSpan must follow the
+		// request span.
+		r = r.WithContext(obsmiddleware.WithAPMType(r.Context(), apmType))
+	}
+
+	r, ctx, span, obs := factory.StartSpanFromRequest(r, customAttrs...)
+	span.SetAttributes(observability.String("apm.backend", apmType))
+	if tenant != "" {
+		span.SetAttributes(observability.String("tenant.id", tenant))
+	}
+	return r, ctx, span, obs
+}
+
+// setupTenantRouting returns what obsmiddleware.WithObservability should use to
+// start request spans: the primary factory, or a tenantRouter when
+// SECONDARY_APM_TYPE and SECONDARY_APM_TENANTS are set. The returned
+// Shutdowner, if any, shuts the secondary backend down.
+func setupTenantRouting(primary *observability.Factory, obs *observability.Observability) (obsmiddleware.SpanStarter, observability.Shutdowner, error) {
+	secondaryType := servicekit.Getenv(EnvSecondaryAPMType, "")
+	tenantList := servicekit.Getenv(EnvSecondaryAPMTenants, "")
+	if secondaryType == "" || tenantList == "" {
+		return primary, nil, nil
+	}
+	if secondaryType == obsmiddleware.APMType() {
+		return nil, nil, fmt.Errorf("secondary APM type %q is already the primary one", secondaryType)
+	}
+
+	tenants := make(map[string]bool)
+	for _, tenant := range strings.Split(tenantList, ",") {
+		if tenant = strings.TrimSpace(tenant); tenant != "" {
+			tenants[tenant] = true
+		}
+	}
+
+	// Runtime metrics are already reported through the primary backend.
+	secondary := observability.NewFactory(
+		observability.WithApmType(secondaryType),
+		observability.WithApmURL(servicekit.Getenv(EnvSecondaryAPMURL, "")),
+		observability.WithMetricsType("none"),
+	)
+	shutdowner, err := secondary.Setup(context.Background())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to setup secondary APM backend: %w", err)
+	}
+
+	obs.Log.Info("Tenant APM routing enabled",
+		"secondaryAPMType", secondaryType,
+		"tenants", len(tenants),
+	)
+	return &tenantRouter{
+		primary:     primary,
+		secondary:   secondary,
+		primaryType: obsmiddleware.APMType(),
+		routedType:  secondaryType,
+		tenants:     tenants,
+	}, shutdowner, nil
+}
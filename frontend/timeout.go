@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"apierror"
+)
+
+var (
+	EnvProductServiceTimeout = "PRODUCT_SERVICE_TIMEOUT"
+	EnvUserServiceTimeout    = "USER_SERVICE_TIMEOUT"
+	DefaultDependencyTimeout = "3s"
+)
+
+// dependencyTimeoutError is the cause of the cancellation of a call to a
+// dependency that did not answer within its timeout, retries included.
+type dependencyTimeoutError struct {
+	service string
+	timeout time.Duration
+}
+
+func (e *dependencyTimeoutError) Error() string {
+	return fmt.Sprintf("%s service did not answer within %s", e.service, e.timeout)
+}
+
+func (e *dependencyTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// parseDependencyTimeout parses the timeout of calls to a dependency. "0"
+// disables it, leaving the calls bounded by downstreamClient only.
+func parseDependencyTimeout(value string) (time.Duration, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timeout %q", value)
+	}
+	return d, nil
+}
+
+// withDependencyTimeout returns ctx with a deadline timeout from now, unless
+// timeout is zero, for the calls to service. Start the call's span under the
+// returned context, so the span records the deadline as its
+// context.cancel_cause when it fires.
+func withDependencyTimeout(ctx context.Context, service string, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout == 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeoutCause(ctx, timeout, &dependencyTimeoutError{service: service, timeout: timeout})
+}
+
+// deadlineCause returns the cause of the expiry of ctx's deadline in place of
+// err, which is then only the client's report of it. Other errors are
+// returned as they are.
+func deadlineCause(ctx context.Context, err error) error {
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return context.Cause(ctx)
+	}
+	return err
+}
+
+// isTimeout reports whether err comes from a deadline that expired, set for
+// the dependency or by the caller, or is an UPSTREAM_TIMEOUT error of the
+// dependency, which timed out calling one of its own.
+func isTimeout(err error) bool {
+	var se *statusError
+	if errors.As(err, &se) && se.errorCode() == apierror.UpstreamTimeout {
+		return true
+	}
+	return errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,168 @@
+// Package grpcobs carries trace context through gRPC calls for services
+// built on the observability library. The client interceptor starts a client
+// span for each call and injects its trace context into the outgoing
+// metadata; the server interceptor extracts it, continues the trace under a
+// server span and hands the handler an Observability instance bound to it.
+//
+// Like kafkaobs, the interceptors use OpenTelemetry span kinds
+// (client/server), so the spans are only exported when the service runs with
+// the OTLP APM type.
+package grpcobs
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const tracerName = "grpcobs"
+
+// metadataCarrier adapts gRPC metadata to a propagation.TextMapCarrier.
+type metadataCarrier metadata.MD
+
+var _ propagation.TextMapCarrier = metadataCarrier{}
+
+func (c metadataCarrier) Get(key string) string {
+	if values := metadata.MD(c).Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// obsKey is a private type to prevent collisions with other packages.
+type obsKey struct{}
+
+// ObsFromCtx returns the Observability instance that the server interceptor
+// bound to the server span of the call. Outside of such a call, it falls back
+// to observability.ObsFromCtx.
+func ObsFromCtx(ctx context.Context) *observability.Observability {
+	if obs, ok := ctx.Value(obsKey{}).(*observability.Observability); ok {
+		return obs
+	}
+	return observability.ObsFromCtx(ctx)
+}
+
+// rpcAttributes returns the attributes that identify the call to fullMethod,
+// such as "/product.v1.ProductService/GetProduct".
+func rpcAttributes(fullMethod string) []attribute.KeyValue {
+	service, method, _ := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	return []attribute.KeyValue{
+		attribute.String("rpc.system", "grpc"),
+		attribute.String("rpc.service", service),
+		attribute.String("rpc.method", method),
+	}
+}
+
+// serverFaults are the status codes that mark a server span as failed. The
+// others, such as NOT_FOUND, are the caller's doing.
+var serverFaults = map[grpccodes.Code]bool{
+	grpccodes.Unknown:          true,
+	grpccodes.DeadlineExceeded: true,
+	grpccodes.Unimplemented:    true,
+	grpccodes.Internal:         true,
+	grpccodes.Unavailable:      true,
+	grpccodes.DataLoss:         true,
+}
+
+// UnaryClientInterceptor starts a client span for every unary call, named
+// after the called method, and injects its trace context (and baggage) into
+// the outgoing metadata. The span records the status code of the call in
+// rpc.grpc.status_code and is marked as failed for any status but OK.
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := otel.Tracer(tracerName).Start(ctx, strings.TrimPrefix(method, "/"),
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(rpcAttributes(method)...),
+			trace.WithAttributes(attribute.String("server.address", cc.Target())),
+		)
+		defer span.End()
+
+		md, ok := metadata.FromOutgoingContext(ctx)
+		if ok {
+			md = md.Copy()
+		} else {
+			md = metadata.MD{}
+		}
+		otel.GetTextMapPropagator().Inject(ctx, metadataCarrier(md))
+		ctx = metadata.NewOutgoingContext(ctx, md)
+
+		err := invoker(ctx, method, req, reply, cc, opts...)
+		st := status.Convert(err)
+		span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(st.Code())))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, st.Message())
+		}
+		return err
+	}
+}
+
+// UnaryServerInterceptor continues the caller's trace from the incoming
+// metadata and starts a server span for every unary call, named after the
+// method. The handler's context carries an Observability instance bound to
+// that span: get it with ObsFromCtx to log and to start child spans. The span
+// records rpc.grpc.status_code and is marked as failed for the status codes
+// that point at the server, but not for NOT_FOUND or INVALID_ARGUMENT. A
+// panicking handler fails the call with INTERNAL rather than crashing the
+// server, as the HTTP recoverer does for requests.
+func UnaryServerInterceptor(factory *observability.Factory) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, strings.TrimPrefix(info.FullMethod, "/"),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(rpcAttributes(info.FullMethod)...),
+		)
+		defer span.End()
+		obs := factory.NewBackgroundObservability(ctx)
+		ctx = context.WithValue(ctx, obsKey{}, obs)
+
+		resp, err := recoverHandler(ctx, obs, req, handler)
+		st := status.Convert(err)
+		span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(st.Code())))
+		if serverFaults[st.Code()] {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, st.Message())
+		}
+		return resp, err
+	}
+}
+
+// recoverHandler calls handler, recovering a panic into an INTERNAL error
+// after recording it, with its stack trace, through obs.
+func recoverHandler(ctx context.Context, obs *observability.Observability, req any, handler grpc.UnaryHandler) (resp any, err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			obs.Log.Error("Recovered from panic",
+				"error", fmt.Errorf("panic: %v", rec),
+				"exception.stacktrace", string(debug.Stack()),
+			)
+			resp, err = nil, status.Error(grpccodes.Internal, "internal error")
+		}
+	}()
+	return handler(ctx, req)
+}
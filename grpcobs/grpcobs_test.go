@@ -0,0 +1,133 @@
+package grpcobs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	testFactory = observability.NewFactory(
+		observability.WithServiceName("grpcobs-test"),
+		observability.WithApmType("none"),
+		observability.WithMetricsType("none"),
+	)
+	recorder = tracetest.NewSpanRecorder()
+)
+
+func TestMain(m *testing.M) {
+	if _, err := testFactory.Setup(context.Background()); err != nil {
+		panic(err)
+	}
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	os.Exit(m.Run())
+}
+
+// endedSpan returns the ended span with the span context sc.
+func endedSpan(t *testing.T, sc trace.SpanContext) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, s := range recorder.Ended() {
+		if s.SpanContext().SpanID() == sc.SpanID() {
+			return s
+		}
+	}
+	t.Fatalf("span %s not ended", sc.SpanID())
+	return nil
+}
+
+const testMethod = "/product.v1.ProductService/GetProduct"
+
+func TestMetadataRoundTrip(t *testing.T) {
+	conn, err := grpc.NewClient("passthrough:///product-service:9086", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer abc")
+
+	// The client interceptor injects the client span into the outgoing
+	// metadata, which the server interceptor continues from.
+	var client, server trace.SpanContext
+	invoker := func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		client = trace.SpanContextFromContext(ctx)
+		md, _ := metadata.FromOutgoingContext(ctx)
+		if got := md.Get("authorization"); len(got) != 1 {
+			t.Errorf("authorization = %v, want the caller's metadata kept", got)
+		}
+		incoming := metadata.NewIncomingContext(context.Background(), md)
+		_, err := UnaryServerInterceptor(testFactory)(incoming, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req any) (any, error) {
+			server = trace.SpanContextFromContext(ctx)
+			return nil, nil
+		})
+		return err
+	}
+	if err := UnaryClientInterceptor()(ctx, testMethod, nil, nil, conn, invoker); err != nil {
+		t.Fatalf("call = %v", err)
+	}
+
+	if server.TraceID() != client.TraceID() {
+		t.Errorf("server span in trace %s, want the client's %s", server.TraceID(), client.TraceID())
+	}
+	if parent := endedSpan(t, server).Parent(); parent.SpanID() != client.SpanID() {
+		t.Errorf("server span parent = %s, want the client span %s", parent.SpanID(), client.SpanID())
+	}
+}
+
+func TestUnaryServerInterceptorStatus(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		panics     bool
+		wantCode   grpccodes.Code
+		wantStatus codes.Code
+	}{
+		{"ok", nil, false, grpccodes.OK, codes.Unset},
+		{"not found", status.Error(grpccodes.NotFound, "no product"), false, grpccodes.NotFound, codes.Unset},
+		{"invalid argument", status.Error(grpccodes.InvalidArgument, "bad id"), false, grpccodes.InvalidArgument, codes.Unset},
+		{"unauthenticated", status.Error(grpccodes.Unauthenticated, "no token"), false, grpccodes.Unauthenticated, codes.Unset},
+		{"internal", status.Error(grpccodes.Internal, "boom"), false, grpccodes.Internal, codes.Error},
+		{"unavailable", status.Error(grpccodes.Unavailable, "down"), false, grpccodes.Unavailable, codes.Error},
+		{"deadline exceeded", status.Error(grpccodes.DeadlineExceeded, "slow"), false, grpccodes.DeadlineExceeded, codes.Error},
+		{"plain error", context.Canceled, false, grpccodes.Unknown, codes.Error},
+		{"panic", nil, true, grpccodes.Internal, codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sc trace.SpanContext
+			_, err := UnaryServerInterceptor(testFactory)(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: testMethod}, func(ctx context.Context, req any) (any, error) {
+				sc = trace.SpanContextFromContext(ctx)
+				if _, ok := ctx.Value(obsKey{}).(*observability.Observability); !ok {
+					t.Error("handler context carries no Observability bound to the call")
+				}
+				if tt.panics {
+					panic("handler bug")
+				}
+				return nil, tt.err
+			})
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("code = %v, want %v", got, tt.wantCode)
+			}
+			span := endedSpan(t, sc)
+			if span.Status().Code != tt.wantStatus {
+				t.Errorf("span status = %v, want %v", span.Status().Code, tt.wantStatus)
+			}
+			if span.Name() != "product.v1.ProductService/GetProduct" {
+				t.Errorf("span name = %q, want the method", span.Name())
+			}
+		})
+	}
+}
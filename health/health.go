@@ -0,0 +1,244 @@
+// Package health serves liveness and readiness endpoints for the example
+// services. Components such as telemetry exporters, downstream services and
+// databases register a Checker; the readiness endpoint runs them all and
+// reports whether the service can take traffic.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Checker reports whether a component is usable.
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a function to Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// Cached returns a Checker that runs c at most once every ttl and answers
+// with its last result in between, so frequent probes of several replicas do
+// not each reach the component. A run of c gets timeout, whatever the context
+// of the check that started it; checks arriving meanwhile wait for that run,
+// or until their own context is done.
+func Cached(c Checker, ttl, timeout time.Duration) Checker {
+	return &cachedChecker{checker: c, ttl: ttl, timeout: timeout}
+}
+
+// HTTPChecker returns a Checker of the service at baseURL, such as
+// http://product-service:8086, that gets its /healthz. It probes liveness
+// rather than readiness, so that one failing service does not mark every
+// service calling it, directly or not, as not ready. The result is Cached
+// for ttl, and a probe not answered within timeout fails.
+func HTTPChecker(baseURL string, ttl, timeout time.Duration) Checker {
+	url := strings.TrimSuffix(baseURL, "/") + "/healthz"
+	return Cached(CheckerFunc(func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("health check returned status %d", resp.StatusCode)
+		}
+		return nil
+	}), ttl, timeout)
+}
+
+type cachedChecker struct {
+	checker Checker
+	ttl     time.Duration
+	timeout time.Duration
+
+	mu      sync.Mutex
+	checked time.Time     // of the last run; zero before the first one ends
+	err     error         // of the last run
+	running chan struct{} // closed when the current run ends; nil when idle
+}
+
+func (c *cachedChecker) Check(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.checked.IsZero() && time.Since(c.checked) < c.ttl {
+		err := c.err
+		c.mu.Unlock()
+		return err
+	}
+	if c.running == nil {
+		c.running = make(chan struct{})
+		go c.run(context.WithoutCancel(ctx))
+	}
+	running := c.running
+	c.mu.Unlock()
+
+	select {
+	case <-running:
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *cachedChecker) run(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	err := c.checker.Check(ctx)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.err = err
+	c.checked = time.Now()
+	close(c.running)
+	c.running = nil
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Critical bool   `json:"critical"`
+	Duration string `json:"duration"`
+}
+
+// Report is the body served by the readiness endpoint.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+const (
+	StatusOK   = "ok"
+	StatusFail = "fail"
+	// StatusDraining reports a service that is shutting down: it finishes
+	// the requests it has but should get no new ones.
+	StatusDraining = "draining"
+)
+
+type check struct {
+	name     string
+	checker  Checker
+	critical bool
+}
+
+// Registry holds the checks of a service. It is safe for concurrent use.
+type Registry struct {
+	timeout  time.Duration
+	draining atomic.Bool
+
+	mu     sync.RWMutex
+	checks []check
+}
+
+// New creates an empty registry whose checks each get timeout to complete.
+func New(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a check the service cannot serve without: while it fails,
+// the service is reported as not ready.
+func (r *Registry) Register(name string, c Checker) {
+	r.add(check{name: name, checker: c, critical: true})
+}
+
+// RegisterNonCritical adds a check that is reported but does not affect
+// readiness, for components the service degrades gracefully without.
+func (r *Registry) RegisterNonCritical(name string, c Checker) {
+	r.add(check{name: name, checker: c})
+}
+
+func (r *Registry) add(c check) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.checks = append(r.checks, c)
+}
+
+// Check runs all checks concurrently and reports the service as failing if
+// any critical one fails.
+func (r *Registry) Check(ctx context.Context) Report {
+	r.mu.RLock()
+	checks := r.checks
+	r.mu.RUnlock()
+
+	report := Report{Status: StatusOK, Checks: make(map[string]Result, len(checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, r.timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.checker.Check(ctx)
+			result := Result{Status: StatusOK, Critical: c.critical, Duration: time.Since(start).String()}
+			if err != nil {
+				result.Status = StatusFail
+				result.Error = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[c.name] = result
+			if err != nil && c.critical {
+				report.Status = StatusFail
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// Drain reports the service as not ready from now on, whatever its checks,
+// so load balancers and Kubernetes stop sending it traffic before it shuts
+// down. Liveness is not affected: a draining service must not be restarted.
+func (r *Registry) Drain() {
+	r.draining.Store(true)
+}
+
+// HandleLiveness reports that the process is up and serving HTTP. It runs no
+// checks: a failing dependency is no reason to restart the service.
+func (r *Registry) HandleLiveness(w http.ResponseWriter, _ *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: StatusOK})
+}
+
+// HandleReadiness runs the checks and answers 200 if the service can take
+// traffic, or 503 if a critical check failed. Once Drain is called, it
+// answers 503 with StatusDraining without running them.
+func (r *Registry) HandleReadiness(w http.ResponseWriter, req *http.Request) {
+	if r.draining.Load() {
+		writeReport(w, http.StatusServiceUnavailable, Report{Status: StatusDraining})
+		return
+	}
+	report := r.Check(req.Context())
+	status := http.StatusOK
+	if report.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, status, report)
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
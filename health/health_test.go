@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingChecker counts its runs and answers with err.
+type countingChecker struct {
+	runs atomic.Int32
+	err  error
+}
+
+func (c *countingChecker) Check(context.Context) error {
+	c.runs.Add(1)
+	return c.err
+}
+
+func TestCached(t *testing.T) {
+	errDown := errors.New("down")
+	tests := []struct {
+		name     string
+		ttl      time.Duration
+		err      error
+		checks   int
+		wantRuns int32
+	}{
+		{"first check runs", time.Minute, nil, 1, 1},
+		{"result reused within ttl", time.Minute, nil, 3, 1},
+		{"error reused within ttl", time.Minute, errDown, 3, 1},
+		{"zero ttl runs every time", 0, nil, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &countingChecker{err: tt.err}
+			cached := Cached(c, tt.ttl, time.Second)
+			for i := range tt.checks {
+				if err := cached.Check(context.Background()); !errors.Is(err, tt.err) {
+					t.Fatalf("check %d = %v, want %v", i, err, tt.err)
+				}
+			}
+			if got := c.runs.Load(); got != tt.wantRuns {
+				t.Errorf("runs = %d, want %d", got, tt.wantRuns)
+			}
+		})
+	}
+}
+
+func TestCachedConcurrentChecksShareRun(t *testing.T) {
+	release := make(chan struct{})
+	var runs atomic.Int32
+	cached := Cached(CheckerFunc(func(context.Context) error {
+		runs.Add(1)
+		<-release
+		return nil
+	}), time.Minute, time.Second)
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := cached.Check(context.Background()); err != nil {
+				t.Errorf("Check = %v", err)
+			}
+		}()
+	}
+	// Let every check reach the wait before the run ends.
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if got := runs.Load(); got != 1 {
+		t.Errorf("runs = %d, want 1", got)
+	}
+}
+
+func TestCachedCallerContext(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	cached := Cached(CheckerFunc(func(ctx context.Context) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return ctx.Err()
+	}), time.Minute, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := cached.Check(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("Check with a canceled context = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCachedRunTimeout(t *testing.T) {
+	cached := Cached(CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}), time.Minute, 10*time.Millisecond)
+
+	if err := cached.Check(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Check = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestHTTPChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"up", http.StatusOK, false},
+		{"down", http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var path string
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				w.WriteHeader(tt.status)
+			}))
+			defer srv.Close()
+
+			err := HTTPChecker(srv.URL+"/", time.Minute, time.Second).Check(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Check = %v, want error %v", err, tt.wantErr)
+			}
+			if path != "/healthz" {
+				t.Errorf("probed %q, want /healthz", path)
+			}
+		})
+	}
+}
+
+func TestHTTPCheckerTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	if err := HTTPChecker(srv.URL, time.Minute, 10*time.Millisecond).Check(context.Background()); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Check = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
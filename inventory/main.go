@@ -0,0 +1,9 @@
+package main
+
+import "servicekit"
+
+var DefaultPort = "8092"
+
+func main() {
+	servicekit.Run("inventory", registerRoutes, servicekit.WithDefaultPort(DefaultPort))
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+
+	"sqlobs"
+)
+
+// migrations holds the schema changes, applied in file name order.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// migrate applies the embedded migrations that were not applied yet, each in
+// a transaction together with its row in schema_migrations, and returns how
+// many it applied.
+func migrate(ctx context.Context, db *sqlobs.DB) (int, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "Inventory.migrate")
+	defer span.End()
+
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return 0, fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return 0, err
+	}
+	sort.Strings(names)
+
+	applied := 0
+	for _, name := range names {
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+		done, err := applyMigration(ctx, db, version, name)
+		if err != nil {
+			return applied, fmt.Errorf("migration %s: %w", version, err)
+		}
+		if done {
+			obs.Log.Info("Migration applied", "version", version)
+			applied++
+		}
+	}
+	span.SetAttributes(observability.Int("db.migrations.applied", applied))
+	return applied, nil
+}
+
+// applyMigration applies the migration in file name unless version was
+// applied already. It reports whether it applied it.
+func applyMigration(ctx context.Context, db *sqlobs.DB, version, name string) (bool, error) {
+	statements, err := migrations.ReadFile(name)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	// Another instance may be migrating at the same time; the insert only
+	// succeeds for the first one.
+	res, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1) ON CONFLICT DO NOTHING", version)
+	if err != nil {
+		return false, err
+	}
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, string(statements)); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
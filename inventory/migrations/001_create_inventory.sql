@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS inventory (
+    product_id TEXT PRIMARY KEY,
+    available  INTEGER NOT NULL CHECK (available >= 0),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,6 @@
+-- Stock for the product IDs used in the README examples.
+INSERT INTO inventory (product_id, available) VALUES
+    ('123', 100),
+    ('456', 100),
+    ('789', 5)
+ON CONFLICT (product_id) DO NOTHING;
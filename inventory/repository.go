@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/app-obs/go/observability"
+
+	"sqlobs"
+)
+
+var (
+	// ErrProductNotFound is returned for products without an inventory row.
+	ErrProductNotFound = errors.New("product not found")
+	// ErrInsufficientStock is returned when fewer items are available than
+	// requested.
+	ErrInsufficientStock = errors.New("insufficient stock")
+)
+
+// InventoryRepository reads and changes stock levels in Postgres. Every
+// statement gets a db.* span with its text from sqlobs.
+type InventoryRepository struct {
+	db *sqlobs.DB
+}
+
+func NewInventoryRepository(db *sqlobs.DB) *InventoryRepository {
+	return &InventoryRepository{db: db}
+}
+
+// Available returns the stock of productID.
+func (r *InventoryRepository) Available(ctx context.Context, productID string) (int, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "InventoryRepository.Available",
+		observability.String("product.id", productID),
+	)
+	defer span.End()
+
+	var available int
+	err := r.db.QueryRowContext(ctx, "SELECT available FROM inventory WHERE product_id = $1", productID).Scan(&available)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrProductNotFound
+	}
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error reading stock")
+		return 0, err
+	}
+	return available, nil
+}
+
+// Reserve takes quantity items of productID out of stock, if that many are
+// available, and returns the remaining stock. The check and the update are a
+// single statement, so concurrent reservations never oversell.
+func (r *InventoryRepository) Reserve(ctx context.Context, productID string, quantity int) (int, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "InventoryRepository.Reserve",
+		observability.String("product.id", productID),
+		observability.Int("inventory.quantity", quantity),
+	)
+	defer span.End()
+
+	var available int
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE inventory SET available = available - $2, updated_at = now()
+		WHERE product_id = $1 AND available >= $2
+		RETURNING available`, productID, quantity).Scan(&available)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Either the product is unknown or its stock is too low.
+		available, err := r.Available(ctx, productID)
+		if err != nil {
+			return 0, err
+		}
+		return 0, fmt.Errorf("%w: %d of product %s available, %d requested", ErrInsufficientStock, available, productID, quantity)
+	}
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error reserving stock")
+		return 0, err
+	}
+	return available, nil
+}
+
+// Release puts quantity items of productID back in stock and returns the
+// new stock.
+func (r *InventoryRepository) Release(ctx context.Context, productID string, quantity int) (int, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "InventoryRepository.Release",
+		observability.String("product.id", productID),
+		observability.Int("inventory.quantity", quantity),
+	)
+	defer span.End()
+
+	var available int
+	err := r.db.QueryRowContext(ctx,
+		`UPDATE inventory SET available = available + $2, updated_at = now()
+		WHERE product_id = $1
+		RETURNING available`, productID, quantity).Scan(&available)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrProductNotFound
+	}
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error releasing stock")
+		return 0, err
+	}
+	return available, nil
+}
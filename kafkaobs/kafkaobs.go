@@ -0,0 +1,123 @@
+// Package kafkaobs carries trace context through Kafka messages for services
+// built on the observability library. Producers inject the current trace
+// context into the message headers, and consumers extract it to continue the
+// trace under a consumer span, or to link a new trace back to it.
+//
+// The helpers use OpenTelemetry span kinds (producer/consumer) and links, so
+// the spans are only exported when the service runs with the OTLP APM type.
+package kafkaobs
+
+import (
+	"context"
+
+	"github.com/app-obs/go/observability"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "kafkaobs"
+
+// headerCarrier adapts Kafka message headers to a propagation.TextMapCarrier.
+type headerCarrier struct {
+	msg *kafka.Message
+}
+
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+func (c headerCarrier) Get(key string) string {
+	for _, h := range c.msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func (c headerCarrier) Set(key, value string) {
+	for i, h := range c.msg.Headers {
+		if h.Key == key {
+			c.msg.Headers[i].Value = []byte(value)
+			return
+		}
+	}
+	c.msg.Headers = append(c.msg.Headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(c.msg.Headers))
+	for i, h := range c.msg.Headers {
+		keys[i] = h.Key
+	}
+	return keys
+}
+
+// InjectKafkaHeaders writes the trace context (and baggage) of ctx into the
+// headers of msg. Call it after StartProducerSpan so the consumer becomes a
+// child of the producer span.
+func InjectKafkaHeaders(ctx context.Context, msg *kafka.Message) {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{msg: msg})
+}
+
+// ExtractKafkaHeaders returns a copy of ctx carrying the trace context found
+// in the headers of msg.
+func ExtractKafkaHeaders(ctx context.Context, msg kafka.Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier{msg: &msg})
+}
+
+// StartProducerSpan starts a producer span for publishing a message to topic.
+// The caller must end the span once the message has been written.
+func StartProducerSpan(ctx context.Context, topic string) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.operation", "publish"),
+			attribute.String("messaging.destination.name", topic),
+		),
+	)
+}
+
+// StartConsumerSpan continues the producer's trace from the headers of msg
+// and starts a consumer span for processing it. The returned Observability
+// instance is bound to the consumer span: use it (rather than ObsFromCtx) to
+// log and to start child spans while handling the message. The caller must
+// end the span once the message has been processed.
+func StartConsumerSpan(factory *observability.Factory, msg kafka.Message) (context.Context, *observability.Observability, trace.Span) {
+	ctx := ExtractKafkaHeaders(context.Background(), msg)
+	ctx, span := otel.Tracer(tracerName).Start(ctx, msg.Topic+" process",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.operation", "process"),
+			attribute.String("messaging.destination.name", msg.Topic),
+			attribute.Int("messaging.kafka.destination.partition", msg.Partition),
+			attribute.Int64("messaging.kafka.message.offset", msg.Offset),
+		),
+	)
+	return ctx, factory.NewBackgroundObservability(ctx), span
+}
+
+// StartLinkedConsumerSpan is like StartConsumerSpan, but the consumer span
+// starts a new trace with a link back to the producer's trace instead of
+// joining it. Use it when messages are processed long after they were
+// published, or in batches, so the publishing request's trace does not stay
+// open until the message is consumed.
+func StartLinkedConsumerSpan(factory *observability.Factory, msg kafka.Message) (context.Context, *observability.Observability, trace.Span) {
+	publishCtx := ExtractKafkaHeaders(context.Background(), msg)
+	ctx, span := otel.Tracer(tracerName).Start(context.Background(), msg.Topic+" process",
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(publishCtx)),
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			attribute.String("messaging.system", "kafka"),
+			attribute.String("messaging.operation", "process"),
+			attribute.String("messaging.destination.name", msg.Topic),
+			attribute.Int("messaging.kafka.destination.partition", msg.Partition),
+			attribute.Int64("messaging.kafka.message.offset", msg.Offset),
+		),
+	)
+	return ctx, factory.NewBackgroundObservability(ctx), span
+}
@@ -0,0 +1,65 @@
+package kafkaobs
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestMain(m *testing.M) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	otel.SetTracerProvider(sdktrace.NewTracerProvider())
+	os.Exit(m.Run())
+}
+
+func TestKafkaHeadersRoundTrip(t *testing.T) {
+	ctx, span := StartProducerSpan(context.Background(), "orders")
+	defer span.End()
+	msg := kafka.Message{Topic: "orders", Headers: []kafka.Header{{Key: "order-id", Value: []byte("1")}}}
+
+	InjectKafkaHeaders(ctx, &msg)
+	if got := (headerCarrier{msg: &msg}).Get("traceparent"); got == "" {
+		t.Fatalf("headers = %v, want a traceparent", msg.Headers)
+	}
+	got := trace.SpanContextFromContext(ExtractKafkaHeaders(context.Background(), msg))
+	want := span.SpanContext()
+	if got.TraceID() != want.TraceID() || got.SpanID() != want.SpanID() || !got.IsRemote() {
+		t.Errorf("extracted %v, want the remote producer span %v", got, want)
+	}
+}
+
+func TestHeaderCarrierSet(t *testing.T) {
+	msg := kafka.Message{Headers: []kafka.Header{{Key: "traceparent", Value: []byte("old")}}}
+	c := headerCarrier{msg: &msg}
+
+	c.Set("traceparent", "new")
+	c.Set("tracestate", "k=v")
+	if len(msg.Headers) != 2 {
+		t.Fatalf("headers = %v, want traceparent replaced and tracestate added", msg.Headers)
+	}
+	tests := []struct {
+		key, want string
+	}{
+		{"traceparent", "new"},
+		{"tracestate", "k=v"},
+		{"baggage", ""},
+	}
+	for _, tt := range tests {
+		if got := c.Get(tt.key); got != tt.want {
+			t.Errorf("Get(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestExtractKafkaHeadersWithoutTraceContext(t *testing.T) {
+	ctx := ExtractKafkaHeaders(context.Background(), kafka.Message{Topic: "orders"})
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		t.Errorf("extracted %v from a message without headers, want none", sc)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// zipfExponent shapes the popularity of product IDs: with 1.1, a few
+// products get most requests and the rest a long tail, like a real catalog.
+const zipfExponent = 1.1
+
+func invalidSetting(env, want string) error {
+	return fmt.Errorf("%s must be %s", env, want)
+}
+
+// loadStats counts the requests sent, by outcome.
+type loadStats struct {
+	sent, ok, clientErrors, serverErrors, failed, skipped atomic.Int64
+}
+
+func (s *loadStats) logArgs() []any {
+	return []any{
+		"sent", s.sent.Load(),
+		"ok", s.ok.Load(),
+		"clientErrors", s.clientErrors.Load(),
+		"serverErrors", s.serverErrors.Load(),
+		"failed", s.failed.Load(),
+		"skipped", s.skipped.Load(),
+	}
+}
+
+// generator sends GET /product-detail requests at a steady rate, each under
+// a root client span of its own, so the downstream traces start at the load
+// generator.
+type generator struct {
+	factory  *observability.Factory
+	cfg      config
+	client   *http.Client
+	ids      *rand.Zipf
+	inFlight chan struct{}
+	stats    loadStats
+}
+
+func newGenerator(factory *observability.Factory, cfg config) *generator {
+	return &generator{
+		factory: factory,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		// Zipf draws from [0, products-1]; IDs start at 1.
+		ids:      rand.NewZipf(rand.New(rand.NewPCG(rand.Uint64(), rand.Uint64())), zipfExponent, 1, cfg.products-1),
+		inFlight: make(chan struct{}, cfg.maxInFlight),
+	}
+}
+
+// Run sends requests until ctx is done, then waits for those in flight. A
+// request that would exceed the in-flight limit is skipped rather than
+// queued, so a slow target does not build up a backlog.
+func (g *generator) Run(ctx context.Context, obs *observability.Observability) {
+	send := time.NewTicker(time.Duration(float64(time.Second) / g.cfg.rps))
+	defer send.Stop()
+	report := time.NewTicker(g.cfg.reportInterval)
+	defer report.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-report.C:
+			obs.Log.Info("Load generator progress", g.stats.logArgs()...)
+		case <-send.C:
+			select {
+			case g.inFlight <- struct{}{}:
+			default:
+				g.stats.skipped.Add(1)
+				continue
+			}
+			productID := g.nextProductID()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-g.inFlight }()
+				g.request(productID)
+			}()
+		}
+	}
+}
+
+// nextProductID draws the ID of the next product to request: a missing-*
+// ID for missingPercent of requests, else a product drawn by popularity.
+func (g *generator) nextProductID() string {
+	if rand.Float64()*100 < g.cfg.missingPercent {
+		return "missing-" + strconv.Itoa(rand.IntN(1000))
+	}
+	return strconv.FormatUint(g.ids.Uint64()+1, 10)
+}
+
+// request requests the detail page of productID under a new root span.
+// Failures are recorded on the span and counted, but not logged: a target
+// that is down would otherwise flood the log.
+func (g *generator) request(productID string) {
+	target := g.cfg.targetURL + "/product-detail?id=" + url.QueryEscape(productID)
+	ctx, obs, span := g.factory.NewBackgroundObservability(context.Background()).StartSpanWith("LoadGen GET /product-detail",
+		observability.String("http.request.method", http.MethodGet),
+		observability.String("url.full", target),
+		observability.String("product.id", productID),
+	)
+	defer span.End()
+	g.stats.sent.Add(1)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		g.fail(span, err)
+		return
+	}
+	// The frontend counts its daily quota per API key, so generated traffic
+	// does not use up the quota of manual requests.
+	req.Header.Set("X-API-Key", g.cfg.apiKey)
+	obs.Trace.InjectHTTP(req)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		g.fail(span, err)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+
+	span.SetAttributes(observability.Int("http.response.status_code", resp.StatusCode))
+	switch {
+	case resp.StatusCode >= 500:
+		g.stats.serverErrors.Add(1)
+		span.SetStatus(codes.Error, resp.Status)
+	case resp.StatusCode >= 400:
+		g.stats.clientErrors.Add(1)
+	default:
+		g.stats.ok.Add(1)
+	}
+}
+
+func (g *generator) fail(span observability.Span, err error) {
+	g.stats.failed.Add(1)
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
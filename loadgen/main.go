@@ -0,0 +1,7 @@
+package main
+
+import "servicekit"
+
+func main() {
+	servicekit.RunWorker("loadgen", work)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"servicekit"
+)
+
+var (
+	EnvTargetURL          = "TARGET_URL"
+	DefaultTargetURL      = "http://frontend:8085"
+	EnvRPS                = "LOADGEN_RPS"
+	DefaultRPS            = "2"
+	EnvMissingPercent     = "LOADGEN_MISSING_PERCENT"
+	DefaultMissingPercent = "5"
+	EnvProducts           = "LOADGEN_PRODUCTS"
+	DefaultProducts       = "1000"
+	EnvMaxInFlight        = "LOADGEN_MAX_IN_FLIGHT"
+	DefaultMaxInFlight    = "50"
+	EnvReportInterval     = "LOADGEN_REPORT_INTERVAL"
+	DefaultReportInterval = "30s"
+	EnvAPIKey             = "LOADGEN_API_KEY"
+	DefaultAPIKey         = "loadgen"
+)
+
+// work sends requests to the target until ctx is done.
+func work(ctx context.Context, s *servicekit.Service) error {
+	cfg, err := configFromEnv()
+	if err != nil {
+		s.Fatal("Invalid load generator settings", "error", err)
+	}
+
+	s.Obs.Log.Info("Load generator started",
+		"target", cfg.targetURL,
+		"rps", cfg.rps,
+		"missingPercent", cfg.missingPercent,
+		"products", cfg.products,
+	)
+	gen := newGenerator(s.Factory, cfg)
+	gen.Run(ctx, s.Obs)
+	s.Obs.Log.Info("Load generator stopped", gen.stats.logArgs()...)
+	return nil
+}
+
+// config holds the load generator settings.
+type config struct {
+	targetURL      string
+	rps            float64
+	missingPercent float64
+	products       uint64
+	maxInFlight    int
+	reportInterval time.Duration
+	apiKey         string
+}
+
+func configFromEnv() (config, error) {
+	cfg := config{
+		targetURL: servicekit.Getenv(EnvTargetURL, DefaultTargetURL),
+		apiKey:    servicekit.Getenv(EnvAPIKey, DefaultAPIKey),
+	}
+	var err error
+	if cfg.rps, err = strconv.ParseFloat(servicekit.Getenv(EnvRPS, DefaultRPS), 64); err != nil || cfg.rps <= 0 {
+		return config{}, invalidSetting(EnvRPS, "a positive number")
+	}
+	if cfg.missingPercent, err = strconv.ParseFloat(servicekit.Getenv(EnvMissingPercent, DefaultMissingPercent), 64); err != nil || cfg.missingPercent < 0 || cfg.missingPercent > 100 {
+		return config{}, invalidSetting(EnvMissingPercent, "a percentage between 0 and 100")
+	}
+	if cfg.products, err = strconv.ParseUint(servicekit.Getenv(EnvProducts, DefaultProducts), 10, 64); err != nil || cfg.products == 0 {
+		return config{}, invalidSetting(EnvProducts, "a positive integer")
+	}
+	if cfg.maxInFlight, err = strconv.Atoi(servicekit.Getenv(EnvMaxInFlight, DefaultMaxInFlight)); err != nil || cfg.maxInFlight <= 0 {
+		return config{}, invalidSetting(EnvMaxInFlight, "a positive integer")
+	}
+	if cfg.reportInterval, err = time.ParseDuration(servicekit.Getenv(EnvReportInterval, DefaultReportInterval)); err != nil || cfg.reportInterval <= 0 {
+		return config{}, invalidSetting(EnvReportInterval, "a positive duration")
+	}
+	return cfg, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"github.com/segmentio/kafka-go"
+
+	"kafkaobs"
+)
+
+// ErrUndeliverable is returned for users whose ID starts with "bounce-", to
+// demo error traces in a service without requests.
+var ErrUndeliverable = errors.New("notification undeliverable")
+
+// orderCreated holds the fields of the order service's OrderCreated message
+// that a notification needs.
+type orderCreated struct {
+	ID        string `json:"id"`
+	UserID    string `json:"userId"`
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// Notifier tells users that their order was received.
+type Notifier struct {
+	factory *observability.Factory
+}
+
+func NewNotifier(factory *observability.Factory) *Notifier {
+	return &Notifier{factory: factory}
+}
+
+// Handle sends the notification for msg under a consumer span that starts a
+// new trace linked to the request that created the order.
+func (n *Notifier) Handle(msg kafka.Message) error {
+	_, obs, span := kafkaobs.StartLinkedConsumerSpan(n.factory, msg)
+	defer span.End()
+
+	var order orderCreated
+	if err := json.Unmarshal(msg.Value, &order); err != nil {
+		obs.ErrorHandler.Record(err, "Error decoding order")
+		return err
+	}
+	span.SetAttributes(observability.String("order.id", order.ID))
+
+	if err := n.send(obs, order); err != nil {
+		obs.ErrorHandler.Record(err, "Failed to send notification")
+		return err
+	}
+	return nil
+}
+
+// send simulates emailing the user of order.
+func (n *Notifier) send(obs *observability.Observability, order orderCreated) error {
+	_, obs, span := obs.StartSpanWith("Notification.send",
+		observability.String("notification.channel", "email"),
+		observability.String("order.id", order.ID),
+	)
+	defer span.End()
+
+	// Simulate calling an email provider.
+	time.Sleep(time.Duration(20+rand.IntN(80)) * time.Millisecond)
+
+	if strings.HasPrefix(order.UserID, "bounce-") {
+		return fmt.Errorf("%w: order %s", ErrUndeliverable, order.ID)
+	}
+
+	obs.Log.Info("Order confirmation sent", "orderID", order.ID, "userID", order.UserID)
+	return nil
+}
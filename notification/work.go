@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+	"github.com/segmentio/kafka-go"
+
+	"servicekit"
+)
+
+var (
+	EnvKafkaBrokers     = "KAFKA_BROKERS"
+	DefaultKafkaBrokers = "kafka:9092"
+)
+
+// ordersTopic is the topic the order service publishes OrderCreated
+// messages to.
+const ordersTopic = "orders"
+
+// notificationsGroup is the consumer group of the notification worker. It
+// differs from the order service's own group, so both receive every order.
+const notificationsGroup = "notification"
+
+// work sends a notification for every order until ctx is done. With no
+// requests to serve, s.Obs logs the worker's whole lifecycle.
+func work(ctx context.Context, s *servicekit.Service) error {
+	brokers := strings.Split(servicekit.Getenv(EnvKafkaBrokers, DefaultKafkaBrokers), ",")
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: brokers,
+		Topic:   ordersTopic,
+		GroupID: notificationsGroup,
+	})
+
+	s.Obs.Log.Info("Notification worker started", "brokers", brokers, "topic", ordersTopic, "group", notificationsGroup)
+	stats := consume(ctx, s.Obs, reader, NewNotifier(s.Factory))
+
+	if err := reader.Close(); err != nil {
+		s.Obs.ErrorHandler.Record(err, "Failed to close order reader")
+	}
+	s.Obs.Log.Info("Notification worker stopped", "sent", stats.sent, "failed", stats.failed)
+	return nil
+}
+
+// consumeStats counts the notifications handled by consume.
+type consumeStats struct {
+	sent, failed int
+}
+
+// consume hands every message of reader to notifier and commits it
+// afterwards, until ctx is done or the reader fails. Failed notifications are
+// committed too, so they are not retried.
+func consume(ctx context.Context, obs *observability.Observability, reader *kafka.Reader, notifier *Notifier) consumeStats {
+	var stats consumeStats
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				obs.Log.Info("Notification worker stopping", "reason", "signal received")
+			} else {
+				obs.ErrorHandler.Record(err, "Order reader failed, notification worker stopping")
+			}
+			return stats
+		}
+
+		// The notifier records its own errors on the consumer span.
+		if err := notifier.Handle(msg); err != nil {
+			stats.failed++
+		} else {
+			stats.sent++
+		}
+		// Commit even while stopping, so the message is not handled twice.
+		if err := reader.CommitMessages(context.WithoutCancel(ctx), msg); err != nil {
+			obs.ErrorHandler.Record(err, "Failed to commit order message")
+		}
+	}
+}
@@ -0,0 +1,36 @@
+// Package obschi instruments chi routers with the observability library.
+// Its middleware is obsmiddleware.WithObservability: it continues the
+// caller's trace, starts the root span, puts the Observability instance in
+// the request context, where observability.ObsFromCtx finds it, and records
+// the response status and body size on the span filtered by
+// obsmiddleware.FilterSpan. Once chi has routed the request, the span is
+// named after the method and route pattern, as in "GET /products/{id}".
+package obschi
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+
+	"obsmiddleware"
+)
+
+// Middleware returns chi middleware that traces every request with spans
+// from starter, usually the service's *observability.Factory. Mount it with
+// Router.Use on the root router, so it runs before any other middleware that
+// logs or starts spans.
+func Middleware(starter obsmiddleware.SpanStarter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return obsmiddleware.WithObservability(starter, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			next.ServeHTTP(w, r)
+
+			// chi fills in the route pattern while it routes, so it is only
+			// complete once the handler has run.
+			route := ""
+			if rctx := chi.RouteContext(r.Context()); rctx != nil {
+				route = rctx.RoutePattern()
+			}
+			obsmiddleware.NameSpan(r.Context(), r.Method, route)
+		}))
+	}
+}
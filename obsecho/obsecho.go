@@ -0,0 +1,54 @@
+// Package obsecho instruments echo servers with the observability library.
+// Its middleware does for every request what
+// obsmiddleware.WithObservability does: it continues the caller's trace,
+// starts the root span, puts the Observability instance in the request
+// context, where observability.ObsFromCtx(c.Request().Context()) finds it,
+// and records the response status and body size on the span filtered by
+// obsmiddleware.FilterSpan. The span is named after the method and route, as
+// in "GET /products/:id", and records the error the handler returns.
+package obsecho
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+
+	"obsmiddleware"
+)
+
+// Middleware returns echo middleware that traces every request with spans
+// from starter, usually the service's *observability.Factory. Register it
+// with Echo.Use, not Echo.Pre: the route is only known once echo has routed
+// the request.
+func Middleware(starter obsmiddleware.SpanStarter) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			obsmiddleware.Instrument(starter, c.Response(), c.Request(), func(r *http.Request) (int, int64) {
+				c.SetRequest(r)
+				// The path is empty for requests no route matches.
+				obsmiddleware.NameSpan(r.Context(), r.Method, c.Path())
+
+				err = next(c)
+
+				// A returned error is only written by echo's error handler
+				// after the middleware has returned, so take its status from
+				// the error.
+				status := c.Response().Status
+				if err != nil {
+					if span, ok := obsmiddleware.SpanFromContext(r.Context()); ok {
+						span.RecordError(err)
+					}
+					status = http.StatusInternalServerError
+					var httpErr *echo.HTTPError
+					if errors.As(err, &httpErr) {
+						status = httpErr.Code
+					}
+				}
+				return status, c.Response().Size
+			})
+			return err
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Package obsgin instruments gin engines with the observability library.
+// Its middleware does for every request what obsmiddleware.WithObservability
+// does: it continues the caller's trace, starts the root span, puts the
+// Observability instance in the request context, where
+// observability.ObsFromCtx(c.Request.Context()) finds it, and records the
+// response status and body size on the span filtered by
+// obsmiddleware.FilterSpan. The span is named after the method and route, as
+// in "GET /products/:id", and records the errors that handlers attach with
+// c.Error.
+package obsgin
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"obsmiddleware"
+)
+
+// Middleware returns gin middleware that traces every request with spans
+// from starter, usually the service's *observability.Factory. Register it
+// with Engine.Use before any other middleware that logs or starts spans.
+func Middleware(starter obsmiddleware.SpanStarter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		obsmiddleware.Instrument(starter, c.Writer, c.Request, func(r *http.Request) (int, int64) {
+			c.Request = r
+			// gin routes before it runs the handler chain, so the route is
+			// already known; it is empty for requests no route matches.
+			obsmiddleware.NameSpan(r.Context(), r.Method, c.FullPath())
+
+			c.Next()
+
+			if span, ok := obsmiddleware.SpanFromContext(r.Context()); ok {
+				for _, err := range c.Errors {
+					span.RecordError(err.Err)
+				}
+			}
+			// gin's writer records the status, preset for unmatched routes,
+			// and a size of -1 until the body is written.
+			return c.Writer.Status(), int64(max(c.Writer.Size(), 0))
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package obsgin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/app-obs/go/observability"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// sdkStarter starts the request spans with an SDK tracer whose ended spans
+// are kept by recorder, as the library's factory does with the OTLP APM
+// type.
+type sdkStarter struct {
+	tracer   trace.Tracer
+	recorder *tracetest.SpanRecorder
+}
+
+func newSDKStarter() *sdkStarter {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	return &sdkStarter{tracer: tp.Tracer("test"), recorder: recorder}
+}
+
+func (s *sdkStarter) StartSpanFromRequest(r *http.Request, _ ...observability.SpanAttributes) (*http.Request, context.Context, observability.Span, *observability.Observability) {
+	ctx, span := s.tracer.Start(r.Context(), r.URL.Path)
+	return r.WithContext(ctx), ctx, sdkSpan{span}, nil
+}
+
+// sdkSpan is an SDK span as an observability.Span.
+type sdkSpan struct {
+	trace.Span
+}
+
+func (s sdkSpan) End() {
+	s.Span.End()
+}
+
+// attr returns the value of the attribute key of span.
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		path       string
+		wantName   string
+		wantRoute  string
+		wantStatus codes.Code
+		wantBytes  int64
+	}{
+		{"route", "/products/1", "GET /products/:id", "/products/:id", codes.Unset, 2},
+		{"server error", "/fail", "GET /fail", "/fail", codes.Error, 7},
+		{"no route", "/missing", "GET", "", codes.Unset, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			starter := newSDKStarter()
+			r := gin.New()
+			r.Use(Middleware(starter))
+			r.GET("/products/:id", func(c *gin.Context) {
+				c.String(http.StatusOK, "ok")
+			})
+			r.GET("/fail", func(c *gin.Context) {
+				c.String(http.StatusInternalServerError, "failed\n")
+			})
+
+			r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, tt.path, nil))
+			ended := starter.recorder.Ended()
+			if len(ended) != 1 {
+				t.Fatalf("recorded %d spans, want 1", len(ended))
+			}
+			span := ended[0]
+			if span.Name() != tt.wantName {
+				t.Errorf("name = %q, want %q", span.Name(), tt.wantName)
+			}
+			if span.Status().Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", span.Status().Code, tt.wantStatus)
+			}
+			if got := attr(span, "http.route").AsString(); got != tt.wantRoute {
+				t.Errorf("http.route = %q, want %q", got, tt.wantRoute)
+			}
+			if got := attr(span, "http.response.body.size").AsInt64(); got != tt.wantBytes {
+				t.Errorf("http.response.body.size = %d, want %d", got, tt.wantBytes)
+			}
+		})
+	}
+}
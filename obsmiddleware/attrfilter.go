@@ -0,0 +1,168 @@
+package obsmiddleware
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var (
+	EnvRedactAttributes     = "OBS_REDACT_ATTRIBUTES"
+	EnvHashAttributes       = "OBS_HASH_ATTRIBUTES"
+	EnvStripQueryAttributes = "OBS_STRIP_QUERY_ATTRIBUTES"
+)
+
+// RedactedValue replaces the value of a redacted attribute.
+const RedactedValue = "[REDACTED]"
+
+// AttributeFilter rewrites span attributes that must not leave the service
+// as they are. Redacted attributes keep their key with the value replaced,
+// hashed attributes keep a short SHA-256 of their value, which still lets
+// spans be grouped by it, and URL attributes can have their query string
+// removed.
+type AttributeFilter struct {
+	redact     map[attribute.Key]bool
+	hash       map[attribute.Key]bool
+	stripQuery map[attribute.Key]bool
+}
+
+// NewAttributeFilter creates a filter for the given attribute keys.
+func NewAttributeFilter(redact, hash, stripQuery []string) *AttributeFilter {
+	return &AttributeFilter{
+		redact:     KeySet(redact),
+		hash:       KeySet(hash),
+		stripQuery: KeySet(stripQuery),
+	}
+}
+
+// SpanAttributeFilter is the filter configured by OBS_REDACT_ATTRIBUTES,
+// OBS_HASH_ATTRIBUTES and OBS_STRIP_QUERY_ATTRIBUTES, each a comma-separated
+// list of attribute keys.
+var SpanAttributeFilter = NewAttributeFilter(
+	strings.Split(getenv(EnvRedactAttributes, ""), ","),
+	strings.Split(getenv(EnvHashAttributes, ""), ","),
+	strings.Split(getenv(EnvStripQueryAttributes, ""), ","),
+)
+
+// KeySet returns the set of the trimmed keys, skipping empty keys and "none",
+// as read from a comma-separated environment variable.
+func KeySet(keys []string) map[attribute.Key]bool {
+	set := make(map[attribute.Key]bool)
+	for _, key := range keys {
+		if key = strings.TrimSpace(key); key != "" && key != "none" {
+			set[attribute.Key(key)] = true
+		}
+	}
+	return set
+}
+
+func (f *AttributeFilter) empty() bool {
+	return len(f.redact) == 0 && len(f.hash) == 0 && len(f.stripQuery) == 0
+}
+
+// Filter returns attrs with the configured attributes rewritten. attrs is
+// not modified.
+func (f *AttributeFilter) Filter(attrs []attribute.KeyValue) []attribute.KeyValue {
+	if f.empty() {
+		return attrs
+	}
+	filtered := make([]attribute.KeyValue, len(attrs))
+	for i, kv := range attrs {
+		filtered[i], _ = f.filter(kv)
+	}
+	return filtered
+}
+
+// filter rewrites kv if its key is configured, and reports whether it did.
+// Redaction wins over hashing, and hashing over stripping the query.
+func (f *AttributeFilter) filter(kv attribute.KeyValue) (attribute.KeyValue, bool) {
+	switch {
+	case f.redact[kv.Key]:
+		return kv.Key.String(RedactedValue), true
+	case f.hash[kv.Key]:
+		sum := sha256.Sum256([]byte(kv.Value.Emit()))
+		return kv.Key.String(hex.EncodeToString(sum[:8])), true
+	case f.stripQuery[kv.Key] && kv.Value.Type() == attribute.STRING:
+		if url, _, found := strings.Cut(kv.Value.AsString(), "?"); found {
+			return kv.Key.String(url), true
+		}
+	}
+	return kv, false
+}
+
+// filteredSpan passes the attributes set on a running span through
+// SpanAttributeFilter, which span processors never see.
+type filteredSpan struct {
+	observability.Span
+}
+
+func (s filteredSpan) SetAttributes(attrs ...attribute.KeyValue) {
+	s.Span.SetAttributes(SpanAttributeFilter.Filter(attrs)...)
+}
+
+// FilterSpan returns span with every attribute set on it from now on
+// rewritten by SpanAttributeFilter. A span that is already filtered is
+// returned as is, so hashed values are not hashed twice.
+//
+// Some attributes cannot be filtered this way: those set directly on the
+// OpenTelemetry span through trace.SpanFromContext, as the ratelimit and
+// wsobs packages and the connection timings of the frontend's client do,
+// span events and their attributes, including the log records the library
+// turns into events, span names, and resource attributes.
+func FilterSpan(span observability.Span) observability.Span {
+	if _, ok := span.(filteredSpan); ok || SpanAttributeFilter.empty() {
+		return span
+	}
+	return filteredSpan{span}
+}
+
+// attributeFilterProcessor applies an AttributeFilter to spans as they
+// start. Attributes set later are not seen by span processors; they are
+// filtered by FilterSpan instead.
+type attributeFilterProcessor struct {
+	filter *AttributeFilter
+}
+
+var _ sdktrace.SpanProcessor = (*attributeFilterProcessor)(nil)
+
+func (p *attributeFilterProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	var changed []attribute.KeyValue
+	for _, kv := range s.Attributes() {
+		if filtered, ok := p.filter.filter(kv); ok {
+			changed = append(changed, filtered)
+		}
+	}
+	// Setting an existing key replaces its value.
+	if len(changed) > 0 {
+		s.SetAttributes(changed...)
+	}
+}
+
+func (p *attributeFilterProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (p *attributeFilterProcessor) Shutdown(context.Context) error   { return nil }
+func (p *attributeFilterProcessor) ForceFlush(context.Context) error { return nil }
+
+// FilterAttributes registers SpanAttributeFilter as a span processor, if any
+// attribute is configured. It needs the OpenTelemetry SDK, so it only works
+// with the OTLP APM type.
+func FilterAttributes(obs *observability.Observability) {
+	if SpanAttributeFilter.empty() {
+		return
+	}
+	tp, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	if !ok {
+		return
+	}
+	tp.RegisterSpanProcessor(&attributeFilterProcessor{filter: SpanAttributeFilter})
+	obs.Log.Info("Span attribute filter enabled",
+		"redact", getenv(EnvRedactAttributes, ""),
+		"hash", getenv(EnvHashAttributes, ""),
+		"stripQuery", getenv(EnvStripQueryAttributes, ""),
+	)
+}
@@ -0,0 +1,155 @@
+package obsmiddleware
+
+import (
+	"context"
+	"testing"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// aliceHash is the first 8 bytes of the SHA-256 of "alice", hex-encoded.
+const aliceHash = "2bd806c97f0e00af"
+
+func TestKeySet(t *testing.T) {
+	tests := []struct {
+		name string
+		keys []string
+		want []attribute.Key
+	}{
+		{"empty", []string{""}, nil},
+		{"none", []string{"none"}, nil},
+		{"trimmed", []string{" user.id ", "http.url"}, []attribute.Key{"user.id", "http.url"}},
+		{"skips blanks", []string{"user.id", " ", ""}, []attribute.Key{"user.id"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := KeySet(tt.keys)
+			if len(got) != len(tt.want) {
+				t.Fatalf("KeySet(%q) = %v, want %v", tt.keys, got, tt.want)
+			}
+			for _, key := range tt.want {
+				if !got[key] {
+					t.Errorf("KeySet(%q) is missing %q", tt.keys, key)
+				}
+			}
+		})
+	}
+}
+
+func TestAttributeFilterFilter(t *testing.T) {
+	f := NewAttributeFilter(
+		[]string{"password", "both"},
+		[]string{"user.id", "both"},
+		[]string{"http.url"},
+	)
+	tests := []struct {
+		name string
+		in   attribute.KeyValue
+		want attribute.KeyValue
+	}{
+		{"redacted", attribute.String("password", "hunter2"), attribute.String("password", RedactedValue)},
+		{"hashed", attribute.String("user.id", "alice"), attribute.String("user.id", aliceHash)},
+		{"hashed non-string", attribute.Int("user.id", 42), attribute.String("user.id", "73475cb40a568e8d")},
+		{"redaction wins over hashing", attribute.String("both", "x"), attribute.String("both", RedactedValue)},
+		{"query stripped", attribute.String("http.url", "/a?token=x"), attribute.String("http.url", "/a")},
+		{"no query", attribute.String("http.url", "/a"), attribute.String("http.url", "/a")},
+		{"strip ignores non-string", attribute.Int("http.url", 1), attribute.Int("http.url", 1)},
+		{"unconfigured", attribute.String("http.method", "GET"), attribute.String("http.method", "GET")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := f.Filter([]attribute.KeyValue{tt.in})
+			if got[0] != tt.want {
+				t.Errorf("Filter(%v) = %v, want %v", tt.in, got[0], tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributeFilterFilterDoesNotModifyInput(t *testing.T) {
+	f := NewAttributeFilter([]string{"password"}, nil, nil)
+	in := []attribute.KeyValue{attribute.String("password", "hunter2")}
+	f.Filter(in)
+	if got := in[0].Value.AsString(); got != "hunter2" {
+		t.Errorf("Filter modified its input to %q", got)
+	}
+}
+
+// recordingSpan is an observability.Span that keeps the attributes set on it.
+type recordingSpan struct {
+	observability.Span
+	attrs []attribute.KeyValue
+}
+
+func (s *recordingSpan) SetAttributes(attrs ...attribute.KeyValue) {
+	s.attrs = append(s.attrs, attrs...)
+}
+
+func withSpanAttributeFilter(t *testing.T, f *AttributeFilter) {
+	t.Helper()
+	saved := SpanAttributeFilter
+	SpanAttributeFilter = f
+	t.Cleanup(func() { SpanAttributeFilter = saved })
+}
+
+func TestFilterSpan(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter *AttributeFilter
+		wraps  int
+		want   string
+	}{
+		{"no filter", NewAttributeFilter(nil, nil, nil), 1, "alice"},
+		{"hashed", NewAttributeFilter(nil, []string{"user.id"}, nil), 1, aliceHash},
+		{"wrapped twice hashes once", NewAttributeFilter(nil, []string{"user.id"}, nil), 2, aliceHash},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSpanAttributeFilter(t, tt.filter)
+			rec := &recordingSpan{}
+			var span observability.Span = rec
+			for range tt.wraps {
+				span = FilterSpan(span)
+			}
+			span.SetAttributes(attribute.String("user.id", "alice"))
+			if got := rec.attrs[0].Value.AsString(); got != tt.want {
+				t.Errorf("user.id = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttributeFilterProcessor(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithSpanProcessor(&attributeFilterProcessor{
+			filter: NewAttributeFilter([]string{"password"}, nil, []string{"http.url"}),
+		}),
+		sdktrace.WithSpanProcessor(recorder),
+	)
+	_, span := tp.Tracer("test").Start(context.Background(), "op", trace.WithAttributes(
+		attribute.String("password", "hunter2"),
+		attribute.String("http.url", "/a?b=c"),
+		attribute.String("http.method", "GET"),
+	))
+	span.End()
+
+	want := map[attribute.Key]string{
+		"password":    RedactedValue,
+		"http.url":    "/a",
+		"http.method": "GET",
+	}
+	ended := recorder.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(ended))
+	}
+	for _, kv := range ended[0].Attributes() {
+		if w, ok := want[kv.Key]; ok && kv.Value.AsString() != w {
+			t.Errorf("%s = %q, want %q", kv.Key, kv.Value.AsString(), w)
+		}
+	}
+}
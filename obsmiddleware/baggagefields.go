@@ -0,0 +1,61 @@
+package obsmiddleware
+
+import (
+	"context"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+)
+
+var (
+	EnvBaggageLogKeys     = "OBS_BAGGAGE_LOG_KEYS"
+	DefaultBaggageLogKeys = "tenant.id,user.id,session.id"
+)
+
+// baggageLogKeys are the baggage members that are copied onto spans and log
+// records, from OBS_BAGGAGE_LOG_KEYS (comma-separated, "none" for none).
+var baggageLogKeys = func() []string {
+	var keys []string
+	for _, key := range strings.Split(getenv(EnvBaggageLogKeys, DefaultBaggageLogKeys), ",") {
+		if key = strings.TrimSpace(key); key != "" && key != "none" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}()
+
+// baggageFields returns the baggage members of ctx named in baggageLogKeys,
+// as alternating keys and values.
+func baggageFields(ctx context.Context) []any {
+	if len(baggageLogKeys) == 0 {
+		return nil
+	}
+	bag := baggage.FromContext(ctx)
+	var fields []any
+	for _, key := range baggageLogKeys {
+		if m := bag.Member(key); m.Key() != "" {
+			fields = append(fields, key, m.Value())
+		}
+	}
+	return fields
+}
+
+// WithBaggageFields sets the baggage members of ctx named in baggageLogKeys
+// as attributes of span and adds them to every record logged through obs, so
+// that values set once upstream, such as the tenant or user, can be searched
+// for in every service a request reaches.
+func WithBaggageFields(ctx context.Context, obs *observability.Observability, span observability.Span) {
+	fields := baggageFields(ctx)
+	if len(fields) == 0 {
+		return
+	}
+	attrs := make([]attribute.KeyValue, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		attrs = append(attrs, attribute.String(fields[i].(string), fields[i+1].(string)))
+	}
+	// The span has started, so the attribute filter's processor would miss these.
+	FilterSpan(span).SetAttributes(attrs...)
+	obs.Log = obs.Log.With(fields...)
+}
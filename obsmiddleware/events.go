@@ -0,0 +1,71 @@
+package obsmiddleware
+
+import (
+	"log/slog"
+	"sort"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// DomainEvent is a kind of business event. Each kind is declared once, as a
+// package variable, so every place that emits it uses the same name.
+type DomainEvent struct {
+	Name string
+	// Counted events are also counted by the domain.events metric, by name.
+	Counted bool
+}
+
+// traceLogLevel is the level from which the factory's logger also adds log
+// records to the current span as events.
+var traceLogLevel = ParseLogLevel(getenv("OBS_TRACE_LOG_LEVEL", "info"))
+
+// DomainEvents records business events on all signals in one call: as an
+// Info log record, as an event on the current span and, for counted events,
+// in a counter. Emitting an event this way rather than by hand keeps its
+// name and fields the same in logs, traces and metrics.
+type DomainEvents struct {
+	count metric.Int64Counter
+}
+
+// NewDomainEvents returns DomainEvents that count events with meter.
+func NewDomainEvents(meter metric.Meter) (*DomainEvents, error) {
+	count, err := meter.Int64Counter("domain.events",
+		metric.WithDescription("Business events, by event name"),
+		metric.WithUnit("{event}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &DomainEvents{count: count}, nil
+}
+
+// Emit records ev with fields on the span and logger of obs.
+func (e *DomainEvents) Emit(obs *observability.Observability, ev DomainEvent, fields map[string]any) {
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	args := make([]any, 0, 2*len(keys))
+	for _, k := range keys {
+		args = append(args, k, fields[k])
+	}
+
+	// Call Logc directly so the log source still points at our caller.
+	obs.Log.Logc(slog.LevelInfo, 3, ev.Name, args...)
+	// The logger adds Info records to the span itself, unless its levels
+	// filter them out.
+	if ConfiguredLogLevel > slog.LevelInfo || traceLogLevel > slog.LevelInfo {
+		attrs := make([]attribute.KeyValue, 0, len(keys))
+		for _, k := range keys {
+			attrs = append(attrs, observability.ToAttribute(k, fields[k]))
+		}
+		trace.SpanFromContext(obs.Context()).AddEvent(ev.Name, trace.WithAttributes(attrs...))
+	}
+	if ev.Counted {
+		e.count.Add(obs.Context(), 1, metric.WithAttributes(attribute.String("event.name", ev.Name)))
+	}
+}
@@ -0,0 +1,81 @@
+package obsmiddleware
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var (
+	EnvMaxResultSize     = "MAX_RESULT_SIZE"
+	DefaultMaxResultSize = "100"
+)
+
+// maxResultSize is the number of items above which a result set is reported
+// as oversized.
+var maxResultSize = parseMaxResultSize()
+
+// Traced runs call in a new span named name and records the error it
+// returns, if any, on that span. call receives the span's context and
+// Observability. It is meant as the whole body of a decorator method, so that
+// tracing a service layer does not have to be mixed into its implementation:
+//
+//	func (r *tracedUserRepository) GetUserByID(ctx context.Context, obs *observability.Observability, id string) (User, error) {
+//		return obsmiddleware.Traced(ctx, "UserRepository.GetUserByID", func(ctx context.Context, obs *observability.Observability) (User, error) {
+//			return r.next.GetUserByID(ctx, obs, id)
+//		}, observability.String("user.id", id))
+//	}
+//
+// Go cannot implement an interface at run time (reflect.MakeFunc builds
+// functions, not methods), so the decorator itself is written by hand, one
+// line per method.
+//
+// Results that are slices or maps are also checked by CheckResultSize.
+func Traced[R any](ctx context.Context, name string, call func(context.Context, *observability.Observability) (R, error), attrs ...attribute.KeyValue) (R, error) {
+	ctx, obs, span := StartSpan(ctx, name, attrs...)
+	defer span.End()
+
+	result, err := call(ctx, obs)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return result, err
+	}
+	if v := reflect.ValueOf(result); v.Kind() == reflect.Slice || v.Kind() == reflect.Map {
+		CheckResultSize(obs, span, name, v.Len())
+	}
+	return result, nil
+}
+
+// CheckResultSize records the size of a result set on span and flags it with
+// result.oversized when it holds more than MAX_RESULT_SIZE items. Unbounded
+// queries tend to grow unnoticed until they are slow; the flag makes them
+// searchable in traces before then, and marks the calls that need
+// pagination.
+func CheckResultSize(obs *observability.Observability, span observability.Span, operation string, count int) {
+	span.SetAttributes(observability.Int("result.count", count))
+	if count <= maxResultSize {
+		return
+	}
+	span.SetAttributes(
+		observability.Bool("result.oversized", true),
+		observability.Int("result.limit", maxResultSize),
+	)
+	obs.Log.Warn("Oversized result set, consider paginating",
+		"operation", operation,
+		"count", count,
+		"limit", maxResultSize,
+	)
+}
+
+func parseMaxResultSize() int {
+	n, err := strconv.Atoi(getenv(EnvMaxResultSize, DefaultMaxResultSize))
+	if err != nil || n <= 0 {
+		n, _ = strconv.Atoi(DefaultMaxResultSize)
+	}
+	return n
+}
@@ -0,0 +1,51 @@
+package obsmiddleware
+
+import (
+	"context"
+
+	"github.com/app-obs/go/observability"
+)
+
+// logFieldsKey is a private type to prevent collisions with other packages.
+type logFieldsKey struct{}
+
+// ContextWith adds args, as alternating keys and values, to every record
+// logged through obs and through the Observability of every span started
+// from the returned context, once WithContextFields is applied. Records that
+// become span events carry them too. Set a field once where it is known,
+// such as an ID at the top of a handler, instead of passing it to each layer:
+//
+//	ctx = ContextWith(ctx, obs, "orderID", orderID)
+func ContextWith(ctx context.Context, obs *observability.Observability, args ...any) context.Context {
+	if len(args) == 0 {
+		return ctx
+	}
+	obs.Log = obs.Log.With(args...)
+	parent := ContextLogFields(ctx)
+	// Copy, so that contexts derived from the same parent never share fields.
+	fields := make([]any, 0, len(parent)+len(args))
+	fields = append(append(fields, parent...), args...)
+	return context.WithValue(ctx, logFieldsKey{}, fields)
+}
+
+// ContextLogFields returns the fields added to ctx with ContextWith.
+func ContextLogFields(ctx context.Context) []any {
+	fields, _ := ctx.Value(logFieldsKey{}).([]any)
+	return fields
+}
+
+// WithContextFields adds the fields of ctx to obs, which was started from ctx.
+func WithContextFields(ctx context.Context, obs *observability.Observability) {
+	if fields := ContextLogFields(ctx); len(fields) > 0 {
+		obs.Log = obs.Log.With(fields...)
+	}
+}
+
+// CopyContextFields returns ctx with the fields added to from with
+// ContextWith, for work started from ctx on behalf of from.
+func CopyContextFields(ctx, from context.Context) context.Context {
+	if fields := ContextLogFields(from); len(fields) > 0 {
+		return context.WithValue(ctx, logFieldsKey{}, fields)
+	}
+	return ctx
+}
@@ -0,0 +1,133 @@
+package obsmiddleware
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var EnvDebugLogsSampledOnly = "DEBUG_LOGS_SAMPLED_ONLY"
+
+// debugLogsSampledOnly keeps Debug logs consistent with traces: when set, Debug
+// logs are only written for requests whose trace is sampled.
+var debugLogsSampledOnly, _ = strconv.ParseBool(getenv(EnvDebugLogsSampledOnly, "false"))
+
+// LogDebug logs a Debug message through obs. It is dropped when logLevel is
+// above Debug, or when
+// DEBUG_LOGS_SAMPLED_ONLY is set and the current trace is not sampled, unless
+// obs was elevated with ElevateFor.
+func LogDebug(obs *observability.Observability, msg string, args ...any) {
+	level, ok := elevated.Load(obs)
+	isElevated := ok && level.(slog.Level) <= slog.LevelDebug
+	if !isElevated {
+		if logLevel.Level() > slog.LevelDebug {
+			return
+		}
+		if debugLogsSampledOnly && !isSampled(obs.Context()) {
+			return
+		}
+	}
+	if ConfiguredLogLevel > slog.LevelDebug {
+		// The factory's logger drops Debug records, so write this one
+		// ourselves.
+		logDirect(obs.Context(), isElevated, msg, args...)
+		return
+	}
+	// Call Logc directly so the log source still points at our caller.
+	obs.Log.Logc(slog.LevelDebug, 3, msg, args...)
+}
+
+// ConfiguredLogLevel is the level the factory's logger was set up with.
+var ConfiguredLogLevel = ParseLogLevel(getenv("OBS_LOG_LEVEL", "debug"))
+
+// logLevel is the current level for the Debug logs written with LogDebug. It
+// starts at ConfiguredLogLevel.
+var logLevel = func() *slog.LevelVar {
+	v := new(slog.LevelVar)
+	v.Set(ConfiguredLogLevel)
+	return v
+}()
+
+// LogLevel returns the current level of the Debug logs written with LogDebug.
+func LogLevel() slog.Level {
+	return logLevel.Level()
+}
+
+// SetLogLevel changes the level of the Debug logs written with LogDebug. The
+// factory's logger keeps the level it was set up with for other records.
+func SetLogLevel(level slog.Level) {
+	logLevel.Set(level)
+}
+
+// elevated holds the log level of each Observability instance whose logs
+// were elevated with ElevateFor.
+var elevated sync.Map // *observability.Observability -> slog.Level
+
+// directHandler writes the Debug records the factory's logger would drop, in
+// the same format, minus the span events.
+var directHandler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{AddSource: true, Level: slog.LevelDebug})
+
+// ElevateFor lowers the log level of obs to level until the span obs belongs
+// to ends, without changing the service-wide level. Use it to get verbose
+// logs from one code path only, such as a retry loop after the first failure.
+// It applies to the Debug logs written with LogDebug, which are then written
+// even when DEBUG_LOGS_SAMPLED_ONLY would drop them.
+func ElevateFor(obs *observability.Observability, level slog.Level) {
+	elevated.Store(obs, level)
+}
+
+// EndElevation undoes ElevateFor once the span of obs has ended.
+func EndElevation(obs *observability.Observability) {
+	elevated.Delete(obs)
+}
+
+// logDirect writes a Debug record for the caller of LogDebug, with the same
+// trace correlation fields the factory's logger adds. Records written because
+// of ElevateFor are marked with log.elevated.
+func logDirect(ctx context.Context, elevated bool, msg string, args ...any) {
+	var pcs [1]uintptr
+	runtime.Callers(3, pcs[:]) // skip [Callers, logDirect, LogDebug]
+	r := slog.NewRecord(time.Now(), slog.LevelDebug, msg, pcs[0])
+	r.Add(args...)
+	r.Add(baggageFields(ctx)...)
+	r.Add(ContextLogFields(ctx)...)
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace.id", sc.TraceID().String()),
+			slog.String("span.id", sc.SpanID().String()),
+		)
+	}
+	if elevated {
+		r.AddAttrs(slog.Bool("log.elevated", true))
+	}
+	_ = directHandler.Handle(ctx, r)
+}
+
+// ParseLogLevel parses OBS_LOG_LEVEL the way the observability library does.
+func ParseLogLevel(level string) slog.Level {
+	switch level {
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// isSampled reports whether the trace in ctx is sampled. Without an
+// OpenTelemetry span (Datadog or no APM) there is no decision to follow, so
+// everything counts as sampled.
+func isSampled(ctx context.Context) bool {
+	sc := trace.SpanContextFromContext(ctx)
+	return !sc.IsValid() || sc.IsSampled()
+}
@@ -0,0 +1,372 @@
+// Package obsmiddleware is the request instrumentation shared by the example
+// services: the middleware that starts the root span of every request and
+// records its route and response, the recovery of panics, the Debug logging
+// that can be elevated per request, the log fields carried in the context,
+// the copying of baggage onto spans and logs, the span attribute filter, and
+// the helpers that start child and background spans and annotate the current
+// one, the Traced helper for tracing decorators, the content negotiation of
+// responses, and the business events recorded on all signals at once.
+//
+// Mount WithObservability once around the mux, with Recoverer and WithRoute
+// inside it:
+//
+//	handler := obsmiddleware.WithObservability(s.Factory, obsmiddleware.Recoverer(obsmiddleware.WithRoute(mux)))
+//
+// It is configured from the environment, with the same OBS_ variables as the
+// observability library.
+package obsmiddleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	EnvTraceResponse    = "OBS_TRACE_RESPONSE"
+	EnvExcludedRoutes   = "OBS_EXCLUDED_ROUTES"
+	EnvRUMSessionHeader = "OBS_RUM_SESSION_HEADER"
+)
+
+// TraceIDHeader carries the trace ID of the request in every response, so it
+// can be pasted straight into the APM's trace search.
+const TraceIDHeader = "X-Trace-Id"
+
+// TimeoutHeader carries the time in milliseconds a caller will wait for the
+// response. WithObservability turns it into a deadline on the request context,
+// so a service stops working on requests its caller has given up on, and
+// passes the remaining time on to its own dependencies.
+const TimeoutHeader = "X-Request-Timeout-Ms"
+
+// errCallerDeadline is the cause of the cancellation of requests whose
+// TimeoutHeader deadline expired.
+var errCallerDeadline = fmt.Errorf("deadline set by the caller: %w", context.DeadlineExceeded)
+
+// traceResponse additionally sets the W3C traceresponse header, for clients
+// that continue the trace.
+var traceResponse, _ = strconv.ParseBool(getenv(EnvTraceResponse, "false"))
+
+// rumSessionHeader names the request header, from OBS_RUM_SESSION_HEADER,
+// in which a browser's real-user monitoring SDK sends its session ID. The ID
+// is put into the request's baggage as session.id, so it reaches the spans
+// and logs of every service the request goes through. Empty disables it.
+var rumSessionHeader = strings.TrimSpace(getenv(EnvRUMSessionHeader, ""))
+
+// maxRUMSessionIDLen bounds the session IDs taken from rumSessionHeader, which
+// the client picks freely.
+const maxRUMSessionIDLen = 128
+
+// excludedRoutes lists the paths, from the comma-separated
+// OBS_EXCLUDED_ROUTES, whose requests WithObservability leaves alone, such as
+// a metrics endpoint scraped every few seconds. As with ServeMux patterns, a
+// path ending in a slash covers everything below it.
+var excludedRoutes = parseExcludedRoutes(getenv(EnvExcludedRoutes, ""))
+
+func parseExcludedRoutes(value string) []string {
+	var routes []string
+	for _, route := range strings.Split(value, ",") {
+		if route = strings.TrimSpace(route); strings.HasPrefix(route, "/") {
+			routes = append(routes, route)
+		}
+	}
+	return routes
+}
+
+// isExcluded reports whether requests to path are excluded from telemetry.
+func isExcluded(path string) bool {
+	for _, route := range excludedRoutes {
+		if path == route || strings.HasSuffix(route, "/") && strings.HasPrefix(path, route) {
+			return true
+		}
+	}
+	return false
+}
+
+// spanKey is a private type to prevent collisions with other packages.
+type spanKey struct{}
+
+// SpanFromContext returns the root request span stored by WithObservability.
+func SpanFromContext(ctx context.Context) (observability.Span, bool) {
+	span, ok := ctx.Value(spanKey{}).(observability.Span)
+	return span, ok
+}
+
+// statusRecorder captures the status code and the size of the body written
+// by the wrapped handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusRecorder) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// SpanStarter starts the root span of an incoming request.
+// *observability.Factory implements it.
+type SpanStarter interface {
+	StartSpanFromRequest(r *http.Request, customAttrs ...observability.SpanAttributes) (*http.Request, context.Context, observability.Span, *observability.Observability)
+}
+
+// WithObservability instruments every request passing through next: it
+// extracts the incoming trace context, starts the root span, makes the
+// Observability instance available through observability.ObsFromCtx and
+// records the response status and body size on the span, marking it as
+// failed for 5xx responses so handlers need not. With the OTLP APM type,
+// responses also carry the trace ID in X-Trace-Id. Wrap the mux with it once
+// instead of calling StartSpanFromRequest in each handler. Requests to the
+// routes in OBS_EXCLUDED_ROUTES go straight to next, without a span.
+func WithObservability(starter SpanStarter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Instrument(starter, w, r, func(r *http.Request) (int, int64) {
+			rec := &statusRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			return rec.status, rec.bytes
+		})
+	})
+}
+
+// Instrument does for one request what WithObservability does, for routers
+// whose response writer already records the response, such as gin's and
+// echo's: serve handles r, which carries the span and the Observability
+// instance, and returns the status, or 0 for 200 OK, and the size of the
+// body it wrote.
+func Instrument(starter SpanStarter, w http.ResponseWriter, r *http.Request, serve func(r *http.Request) (status int, bytes int64)) {
+	if isExcluded(r.URL.Path) {
+		serve(r)
+		return
+	}
+	r, ctx, span, obs := starter.StartSpanFromRequest(r)
+	span = FilterSpan(span)
+	defer span.End()
+	defer EndElevation(obs)
+	span.SetAttributes(serverAttributes(r)...)
+	ctx = withRUMSession(ctx, r)
+	ctx, cancel := withCallerDeadline(ctx, r, span)
+	defer cancel()
+	WithBaggageFields(ctx, obs, span)
+
+	r = r.WithContext(context.WithValue(ctx, spanKey{}, span))
+	setTraceHeaders(w.Header(), ctx)
+	status, bytes := serve(r)
+
+	if status == 0 {
+		status = http.StatusOK
+	}
+	span.SetAttributes(
+		observability.Int("http.status_code", status),
+		observability.Int("http.response.status_code", status),
+		attribute.Int64("http.response.body.size", bytes),
+	)
+	if status >= http.StatusInternalServerError {
+		span.SetAttributes(observability.String("error.type", strconv.Itoa(status)))
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
+
+// serverAttributes returns the attributes that the current HTTP semantic
+// conventions define for a server span. The library still sets the older
+// http.method, http.url, http.target, http.host and http.scheme, which are
+// kept so existing dashboards go on working.
+func serverAttributes(r *http.Request) []attribute.KeyValue {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("http.request.method", r.Method),
+		attribute.String("url.path", r.URL.Path),
+		attribute.String("url.scheme", scheme),
+	}
+	host, port, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		// No port in the Host header.
+		return append(attrs, attribute.String("server.address", r.Host))
+	}
+	attrs = append(attrs, attribute.String("server.address", host))
+	if n, err := strconv.Atoi(port); err == nil {
+		attrs = append(attrs, attribute.Int("server.port", n))
+	}
+	return attrs
+}
+
+// WithRoute names the request span after the mux pattern that matches the
+// request, as "<method> <route>" such as "GET /products/{id}", and sets
+// http.route to the route, so requests can be grouped by endpoint whatever
+// their path parameters. The library names the span after the raw path,
+// which would give every product its own span name. Requests no pattern
+// matches are named after their method alone. It must run inside
+// WithObservability.
+func WithRoute(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := ""
+		if _, pattern := mux.Handler(r); pattern != "" {
+			route = RouteOf(pattern)
+		}
+		NameSpan(r.Context(), r.Method, route)
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// NameSpan names the request span in ctx, started by WithObservability or
+// Instrument, as "<method> <route>", and sets http.route to route. An empty
+// route, for requests no route matches, names the span after the method
+// alone. Routers other than the mux call it with their own route templates,
+// such as "/products/{id}" or "/products/:id".
+//
+// The Span interface cannot rename, so this goes to the OpenTelemetry span
+// where there is one, and otherwise sets the resource name, which Datadog
+// shows instead of the operation name.
+func NameSpan(ctx context.Context, method, route string) {
+	span, ok := SpanFromContext(ctx)
+	if !ok {
+		return
+	}
+	name := SpanMethod(method)
+	if route != "" {
+		name += " " + route
+		span.SetAttributes(observability.String("http.route", route))
+	}
+	if otelSpan := trace.SpanFromContext(ctx); otelSpan.IsRecording() {
+		otelSpan.SetName(name)
+		return
+	}
+	span.SetAttributes(observability.String("resource.name", name))
+}
+
+// SpanMethod returns method for the span name, or "HTTP" for methods outside
+// the standard ones, which clients could otherwise pick freely.
+func SpanMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "HTTP"
+}
+
+// RouteOf returns the path of a mux pattern, without the method and host
+// that may precede it.
+func RouteOf(pattern string) string {
+	if i := strings.IndexByte(pattern, '/'); i >= 0 {
+		return pattern[i:]
+	}
+	return pattern
+}
+
+// setTraceHeaders sets the trace headers of the response to the request
+// whose span is in ctx.
+func setTraceHeaders(h http.Header, ctx context.Context) {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return
+	}
+	h.Set(TraceIDHeader, sc.TraceID().String())
+	exposed := TraceIDHeader
+	if traceResponse {
+		h.Set("traceresponse", fmt.Sprintf("00-%s-%s-%s", sc.TraceID(), sc.SpanID(), sc.TraceFlags()))
+		exposed += ", traceresponse"
+	}
+	// Let browser scripts and RUM SDKs on other origins read them.
+	h.Add("Access-Control-Expose-Headers", exposed)
+}
+
+// withRUMSession returns ctx with the session ID from rumSessionHeader, if r
+// has one, as the session.id baggage member. The header wins over a
+// session.id already in the baggage: the browser knows its session best.
+// Invalid or overlong IDs are ignored.
+func withRUMSession(ctx context.Context, r *http.Request) context.Context {
+	if rumSessionHeader == "" {
+		return ctx
+	}
+	id := strings.TrimSpace(r.Header.Get(rumSessionHeader))
+	if id == "" || len(id) > maxRUMSessionIDLen {
+		return ctx
+	}
+	member, err := baggage.NewMemberRaw("session.id", id)
+	if err != nil {
+		return ctx
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// withCallerDeadline returns ctx with the deadline set by the TimeoutHeader of
+// r, if any, which it records on span as request.timeout_ms.
+func withCallerDeadline(ctx context.Context, r *http.Request, span observability.Span) (context.Context, context.CancelFunc) {
+	ms, err := strconv.ParseInt(r.Header.Get(TimeoutHeader), 10, 64)
+	if err != nil || ms <= 0 {
+		return ctx, func() {}
+	}
+	span.SetAttributes(attribute.Int64("request.timeout_ms", ms))
+	return context.WithTimeoutCause(ctx, time.Duration(ms)*time.Millisecond, errCallerDeadline)
+}
+
+// Recoverer recovers panics raised by next, records the stack trace on the
+// active span through the APM log handler and responds with 500. It must run
+// inside WithObservability so the panic is still recorded before the span ends.
+func Recoverer(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				// Let net/http handle deliberate aborts as usual.
+				panic(rec)
+			}
+
+			obs := observability.ObsFromCtx(r.Context())
+			obs.Log.Error("Recovered from panic",
+				"error", fmt.Errorf("panic: %v", rec),
+				"exception.stacktrace", string(debug.Stack()),
+			)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// getenv returns the value of the environment variable envKey, or
+// defaultValue if it is unset or empty.
+func getenv(envKey, defaultValue string) string {
+	if value := os.Getenv(envKey); value != "" {
+		return value
+	}
+	return defaultValue
+}
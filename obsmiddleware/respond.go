@@ -0,0 +1,104 @@
+package obsmiddleware
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+)
+
+// Media types the services answer with.
+const (
+	MediaTypeJSON = "application/json"
+	MediaTypeText = "text/plain"
+)
+
+// Respond writes v with status in the media type the request prefers: as
+// JSON, or as the text of its String method, which is the default for
+// clients that send no Accept header. Clients accepting neither get a 406.
+func Respond(w http.ResponseWriter, r *http.Request, obs *observability.Observability, status int, v fmt.Stringer) {
+	switch Negotiate(r.Header.Get("Accept"), MediaTypeText, MediaTypeJSON) {
+	case MediaTypeJSON:
+		WriteJSON(w, obs, status, v)
+	case MediaTypeText:
+		WriteText(w, status, v.String())
+	default:
+		obs.ErrorHandler.HTTP(w, "Acceptable media types: text/plain, application/json", http.StatusNotAcceptable)
+	}
+}
+
+// WriteJSON writes v as a JSON response with status. v is marshaled before
+// anything is written, so a value that cannot be marshaled is recorded as an
+// error on the current span and answered with a 500, rather than sent as a
+// truncated body with a success status.
+func WriteJSON(w http.ResponseWriter, obs *observability.Observability, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Failed to encode response")
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", MediaTypeJSON)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// WriteText writes text as a plain text response with status.
+func WriteText(w http.ResponseWriter, status int, text string) {
+	w.Header().Set("Content-Type", MediaTypeText+"; charset=utf-8")
+	w.WriteHeader(status)
+	w.Write([]byte(text))
+}
+
+// Negotiate returns the offer that the Accept header value prefers, using
+// the quality of the most specific media range matching each offer. Offers
+// accepted with the same quality are ranked in the order given, and the
+// first offer is returned when accept is empty. It returns "" when accept
+// rules out every offer.
+func Negotiate(accept string, offers ...string) string {
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		if q := acceptQuality(accept, offer); q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// acceptQuality returns the quality that accept gives to mediaType.
+func acceptQuality(accept, mediaType string) float64 {
+	typ, _, _ := strings.Cut(mediaType, "/")
+	q, specificity := 0.0, 0
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mediaRange := strings.ToLower(strings.TrimSpace(params[0]))
+
+		s := 0
+		switch mediaRange {
+		case mediaType:
+			s = 3
+		case typ + "/*":
+			s = 2
+		case "*/*":
+			s = 1
+		}
+		if s <= specificity {
+			continue
+		}
+		specificity, q = s, 1
+		for _, p := range params[1:] {
+			if k, v, ok := strings.Cut(strings.TrimSpace(p), "="); ok && strings.TrimSpace(k) == "q" {
+				if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+					q = f
+				}
+			}
+		}
+	}
+	return q
+}
@@ -0,0 +1,28 @@
+package obsmiddleware
+
+import "testing"
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   string
+	}{
+		{"no header", "", MediaTypeText},
+		{"JSON", "application/json", MediaTypeJSON},
+		{"any", "*/*", MediaTypeText},
+		{"type wildcard", "application/*", MediaTypeJSON},
+		{"quality", "text/plain;q=0.5, application/json", MediaTypeJSON},
+		{"specific range wins over wildcard", "*/*;q=0.9, text/plain;q=0.1", MediaTypeJSON},
+		{"case insensitive", "Application/JSON", MediaTypeJSON},
+		{"excluded", "text/plain;q=0", ""},
+		{"unsupported", "image/png", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Negotiate(tt.accept, MediaTypeText, MediaTypeJSON); got != tt.want {
+				t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
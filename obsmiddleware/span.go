@@ -0,0 +1,178 @@
+package obsmiddleware
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// The service identity the factory reads from the environment, needed to bind
+// new Observability instances to a context. servicekit sets the name the
+// factory actually got with SetServiceName.
+var (
+	obsServiceName = getenv("OBS_SERVICE_NAME", "unknown-service")
+	obsAPMType     = getenv("OBS_APM_TYPE", "none")
+)
+
+// SetServiceName sets the service name of the spans started with StartSpan
+// and StartBackgroundSpan. Call it once, before serving.
+func SetServiceName(name string) {
+	obsServiceName = name
+}
+
+// ServiceName returns the service name set with SetServiceName.
+func ServiceName() string {
+	return obsServiceName
+}
+
+// APMType returns the APM backend the service is configured with.
+func APMType() string {
+	return obsAPMType
+}
+
+// apmTypeKey is a private type to prevent collisions with other packages.
+type apmTypeKey struct{}
+
+// WithAPMType returns a copy of ctx whose spans, started with StartSpan and
+// StartBackgroundSpan, go to apmType rather than the backend the service is
+// configured with. It is for services that route some requests to another
+// backend, such as the frontend's tenant routing.
+func WithAPMType(ctx context.Context, apmType string) context.Context {
+	return context.WithValue(ctx, apmTypeKey{}, apmType)
+}
+
+// apmTypeFromCtx returns the APM backend for the spans of ctx.
+func apmTypeFromCtx(ctx context.Context) string {
+	if apmType, ok := ctx.Value(apmTypeKey{}).(string); ok {
+		return apmType
+	}
+	return obsAPMType
+}
+
+// StartSpan starts a child span of the span in ctx. Unlike
+// observability.StartSpanFromCtxWith, the returned context derives from ctx,
+// so deadlines and values added by the caller are kept and grandchild spans
+// still find their parent. The returned span also marks itself as failed if
+// its context is canceled or expires before End, so work truncated by a
+// timeout is not mistaken for fast successful work.
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *observability.Observability, observability.Span) {
+	// The log settings passed here are unused: all instances share the
+	// logger configured by the factory.
+	obs := observability.NewObservability(ctx, obsServiceName, apmTypeFromCtx(ctx), true, slog.LevelDebug, slog.LevelInfo, false)
+	ctx, obs, span := obs.StartSpanWith(name, attrs...)
+	WithBaggageFields(ctx, obs, span)
+	WithContextFields(ctx, obs)
+	return withCurrentSpan(ctx, obs, span)
+}
+
+// StartBackgroundSpan starts a new root span for work that outlives the
+// request that triggered it, such as an asynchronous cache refresh. A child
+// span would be cut off or orphaned once the request span ends; a root span
+// gets its own trace and lifetime instead. The returned context carries the
+// baggage and log fields of origin but none of its deadline or cancellation.
+//
+// With the OTLP APM type the span is linked to the span in origin, if any, so
+// the originating trace can still be found. Pass context.Background() as
+// origin for work that has no originating request.
+func StartBackgroundSpan(origin context.Context, name string, attrs ...attribute.KeyValue) (context.Context, *observability.Observability, observability.Span) {
+	ctx := baggage.ContextWithBaggage(context.Background(), baggage.FromContext(origin))
+	ctx = CopyContextFields(ctx, origin)
+	apmType := apmTypeFromCtx(origin)
+	if apmType != obsAPMType {
+		ctx = WithAPMType(ctx, apmType)
+	}
+
+	if apmType != "otlp" {
+		obs := observability.NewObservability(ctx, obsServiceName, apmType, true, slog.LevelDebug, slog.LevelInfo, false)
+		ctx, obs, span := obs.StartSpanWith(name, attrs...)
+		WithBaggageFields(ctx, obs, span)
+		WithContextFields(ctx, obs)
+		return withCurrentSpan(ctx, obs, span)
+	}
+
+	opts := []trace.SpanStartOption{trace.WithNewRoot(), trace.WithAttributes(attrs...)}
+	if link := trace.LinkFromContext(origin); link.SpanContext.IsValid() {
+		opts = append(opts, trace.WithLinks(link))
+	}
+	ctx, span := otel.Tracer(obsServiceName).Start(ctx, name, opts...)
+	obs := observability.NewObservability(ctx, obsServiceName, apmType, true, slog.LevelDebug, slog.LevelInfo, false)
+	WithBaggageFields(ctx, obs, otelSpan{span})
+	WithContextFields(ctx, obs)
+	return withCurrentSpan(ctx, obs, otelSpan{span})
+}
+
+// currentSpanKey is a private type to prevent collisions with other packages.
+type currentSpanKey struct{}
+
+// withCurrentSpan wraps a newly started span, filtered by FilterSpan, in a
+// ctxAwareSpan and records it in ctx as the current span, for AddAttrs and
+// AddEvent.
+func withCurrentSpan(ctx context.Context, obs *observability.Observability, span observability.Span) (context.Context, *observability.Observability, observability.Span) {
+	wrapped := &ctxAwareSpan{Span: FilterSpan(span), ctx: ctx, obs: obs}
+	return context.WithValue(ctx, currentSpanKey{}, wrapped), obs, wrapped
+}
+
+// currentSpan returns the innermost span started in ctx by StartSpan or
+// StartBackgroundSpan, or else the request span.
+func currentSpan(ctx context.Context) (observability.Span, bool) {
+	if span, ok := ctx.Value(currentSpanKey{}).(observability.Span); ok {
+		return span, true
+	}
+	return SpanFromContext(ctx)
+}
+
+// AddAttrs sets attrs on the current span of ctx. Use it for details of an
+// operation that are worth recording but not worth a span of their own, such
+// as the rows a query returned or whether a cache was hit.
+func AddAttrs(ctx context.Context, attrs observability.SpanAttributes) {
+	span, ok := currentSpan(ctx)
+	if !ok {
+		return
+	}
+	for k, v := range attrs {
+		span.SetAttributes(observability.ToAttribute(k, v))
+	}
+}
+
+// AddEvent adds an event named name, with attrs, to the current span of ctx.
+func AddEvent(ctx context.Context, name string, attrs observability.SpanAttributes) {
+	span, ok := currentSpan(ctx)
+	if !ok {
+		return
+	}
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for k, v := range attrs {
+		kvs = append(kvs, observability.ToAttribute(k, v))
+	}
+	span.AddEvent(name, trace.WithAttributes(kvs...))
+}
+
+// otelSpan adapts an OpenTelemetry span to observability.Span.
+type otelSpan struct {
+	trace.Span
+}
+
+func (s otelSpan) End() { s.Span.End() }
+
+// ctxAwareSpan records the context cancellation cause on End, and ends any
+// log elevation of the span's Observability.
+type ctxAwareSpan struct {
+	observability.Span
+	ctx context.Context
+	obs *observability.Observability
+}
+
+func (s *ctxAwareSpan) End() {
+	EndElevation(s.obs)
+	if err := s.ctx.Err(); err != nil {
+		s.Span.SetAttributes(observability.String("context.cancel_cause", context.Cause(s.ctx).Error()))
+		s.Span.SetStatus(codes.Error, err.Error())
+	}
+	s.Span.End()
+}
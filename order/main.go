@@ -0,0 +1,9 @@
+package main
+
+import "servicekit"
+
+var DefaultPort = "8089"
+
+func main() {
+	servicekit.Run("order", registerRoutes, servicekit.WithDefaultPort(DefaultPort))
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"kafkaobs"
+)
+
+// ErrOrderFailed is returned for orders of product "fail", to demo error
+// traces on the consumer side.
+var ErrOrderFailed = errors.New("order failed")
+
+// orderCreatedType is the value of the "type" header of OrderCreated messages.
+const orderCreatedType = "OrderCreated"
+
+// OrderCreated is the message published for every new order.
+type OrderCreated struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"userId"`
+	ProductID string    `json:"productId"`
+	Quantity  int       `json:"quantity"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// OrderPublisher publishes OrderCreated messages to the orders topic.
+type OrderPublisher struct {
+	writer *kafka.Writer
+	nextID atomic.Uint64
+}
+
+func NewOrderPublisher(writer *kafka.Writer) *OrderPublisher {
+	return &OrderPublisher{writer: writer}
+}
+
+// Publish publishes an OrderCreated message for req under a producer span,
+// with the trace context in the message headers, and returns the order.
+func (p *OrderPublisher) Publish(ctx context.Context, obs *observability.Observability, req createOrderRequest) (OrderCreated, error) {
+	order := OrderCreated{
+		ID:        "order-" + strconv.FormatUint(p.nextID.Add(1), 10),
+		UserID:    req.UserID,
+		ProductID: req.ProductID,
+		Quantity:  req.Quantity,
+		CreatedAt: time.Now().UTC(),
+	}
+	value, err := json.Marshal(order)
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error encoding order")
+		return OrderCreated{}, err
+	}
+
+	ctx, span := kafkaobs.StartProducerSpan(ctx, ordersTopic)
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("messaging.kafka.message.key", order.ID),
+		attribute.String("order.id", order.ID),
+	)
+
+	msg := kafka.Message{
+		Key:     []byte(order.ID),
+		Value:   value,
+		Headers: []kafka.Header{{Key: "type", Value: []byte(orderCreatedType)}},
+	}
+	// Inject after starting the producer span, so the consumer links to it.
+	kafkaobs.InjectKafkaHeaders(ctx, &msg)
+
+	if err := p.writer.WriteMessages(ctx, msg); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "publish failed")
+		obs.ErrorHandler.Record(err, "Error publishing order")
+		return OrderCreated{}, fmt.Errorf("failed to publish order: %w", err)
+	}
+	return order, nil
+}
+
+// consumeOrders hands every message of reader to processor and commits it
+// afterwards, until ctx is canceled. Failed orders are committed too, so they
+// are not redelivered. The order being processed when ctx is canceled is
+// still committed, so another replica does not process it again.
+func consumeOrders(ctx context.Context, obs *observability.Observability, reader *kafka.Reader, processor *OrderProcessor) {
+	for {
+		msg, err := reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				obs.Log.Info("Order consumer stopping")
+				return
+			}
+			obs.Log.Warn("Order reader stopped, no longer consuming orders", "error", err)
+			return
+		}
+		// The processor records its own errors on the consumer span.
+		_ = processor.Handle(msg)
+		if err := reader.CommitMessages(context.WithoutCancel(ctx), msg); err != nil {
+			obs.ErrorHandler.Record(err, "Failed to commit order message")
+		}
+	}
+}
+
+// OrderProcessor processes OrderCreated messages, each under its own
+// consumer span.
+type OrderProcessor struct {
+	factory *observability.Factory
+}
+
+func NewOrderProcessor(factory *observability.Factory) *OrderProcessor {
+	return &OrderProcessor{factory: factory}
+}
+
+// Handle processes msg under a consumer span that starts a new trace linked
+// to the request that published the order.
+func (p *OrderProcessor) Handle(msg kafka.Message) error {
+	_, obs, span := kafkaobs.StartLinkedConsumerSpan(p.factory, msg)
+	defer span.End()
+
+	var order OrderCreated
+	if err := json.Unmarshal(msg.Value, &order); err != nil {
+		obs.ErrorHandler.Record(err, "Error decoding order")
+		return err
+	}
+	span.SetAttributes(attribute.String("order.id", order.ID))
+
+	if err := p.process(obs, order); err != nil {
+		obs.ErrorHandler.Record(err, "Failed to process order")
+		return err
+	}
+	return nil
+}
+
+// process simulates fulfilling order.
+func (p *OrderProcessor) process(obs *observability.Observability, order OrderCreated) error {
+	_, obs, span := obs.StartSpanWith("Order.process",
+		observability.String("order.id", order.ID),
+		observability.String("order.product_id", order.ProductID),
+		observability.Int("order.quantity", order.Quantity),
+	)
+	defer span.End()
+
+	obs.Log.Info("Processing order", "orderID", order.ID, "productID", order.ProductID, "quantity", order.Quantity)
+
+	// Simulate some work.
+	time.Sleep(time.Duration(50+rand.IntN(150)) * time.Millisecond)
+
+	if order.ProductID == "fail" {
+		err := fmt.Errorf("%w: %s", ErrOrderFailed, order.ID)
+		obs.ErrorHandler.Record(err, "Error processing order")
+		return err
+	}
+
+	obs.Log.Info("Order processed", "orderID", order.ID)
+	return nil
+}
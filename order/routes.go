@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"github.com/segmentio/kafka-go"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvKafkaBrokers     = "KAFKA_BROKERS"
+	DefaultKafkaBrokers = "kafka:9092"
+)
+
+// ordersTopic is the topic OrderCreated messages are published to and
+// consumed from.
+const ordersTopic = "orders"
+
+// ordersGroup is the consumer group of the order processor.
+const ordersGroup = "order-processor"
+
+// registerRoutes connects to Kafka, starts the order consumer and returns the order API.
+func registerRoutes(s *servicekit.Service) http.Handler {
+	brokers := strings.Split(servicekit.Getenv(EnvKafkaBrokers, DefaultKafkaBrokers), ",")
+
+	writer := &kafka.Writer{
+		Addr:  kafka.TCP(brokers...),
+		Topic: ordersTopic,
+		// The broker usually starts after the service in docker compose, so
+		// the topic is created by the first publish.
+		AllowAutoTopicCreation: true,
+		RequiredAcks:           kafka.RequireOne,
+		// Orders are published one at a time from requests.
+		BatchTimeout: 10 * time.Millisecond,
+	}
+	s.OnShutdown(func() { writer.Close() })
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers: brokers,
+		Topic:   ordersTopic,
+		GroupID: ordersGroup,
+	})
+	s.OnShutdown(func() { reader.Close() })
+	processor := NewOrderProcessor(s.Factory)
+	s.Go(func(ctx context.Context) {
+		consumeOrders(ctx, s.Obs, reader, processor)
+	})
+
+	publisher := NewOrderPublisher(writer)
+
+	mux := http.NewServeMux()
+	s.Handle(mux, "POST /order", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCreateOrder(r.Context(), w, r, observability.ObsFromCtx(r.Context()), publisher)
+	}))
+
+	return obsmiddleware.WithRoute(mux)
+}
+
+// createOrderRequest is the body of POST /order.
+type createOrderRequest struct {
+	UserID    string `json:"userId"`
+	ProductID string `json:"productId"`
+	Quantity  int    `json:"quantity"`
+}
+
+// handleCreateOrder publishes an OrderCreated message and answers with the
+// order ID before the order is processed.
+func handleCreateOrder(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	publisher *OrderPublisher) {
+	var req createOrderRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		obs.ErrorHandler.HTTP(w, "Invalid order", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == "" || req.ProductID == "" || req.Quantity <= 0 {
+		obs.ErrorHandler.HTTP(w, "Order needs a userId, a productId and a positive quantity", http.StatusBadRequest)
+		return
+	}
+
+	order, err := publisher.Publish(ctx, obs, req)
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, "Failed to create order", http.StatusInternalServerError)
+		return
+	}
+
+	obs.Log.Info("Order created", "orderID", order.ID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(order)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/url"
+	"strconv"
+	"time"
+
+	"servicekit"
+)
+
+var (
+	EnvLatencyMedian     = "PAYMENT_LATENCY_MEDIAN"
+	DefaultLatencyMedian = "50ms"
+	EnvLatencyP99        = "PAYMENT_LATENCY_P99"
+	DefaultLatencyP99    = "500ms"
+	EnvErrorRate         = "PAYMENT_ERROR_RATE"
+	DefaultErrorRate     = "0.02"
+)
+
+// z99 is the 99th percentile of the standard normal distribution.
+const z99 = 2.326
+
+// faults is the latency distribution and error rate the payment provider
+// simulates. Latencies follow a log-normal distribution, like real service
+// latencies: most calls are close to the median, with a long tail up to and
+// beyond the 99th percentile.
+type faults struct {
+	median    time.Duration
+	p99       time.Duration
+	errorRate float64
+}
+
+// faultsFromEnv reads the faults from PAYMENT_LATENCY_MEDIAN,
+// PAYMENT_LATENCY_P99 and PAYMENT_ERROR_RATE.
+func faultsFromEnv() (faults, error) {
+	return parseFaults(faults{},
+		servicekit.Getenv(EnvLatencyMedian, DefaultLatencyMedian),
+		servicekit.Getenv(EnvLatencyP99, DefaultLatencyP99),
+		servicekit.Getenv(EnvErrorRate, DefaultErrorRate),
+	)
+}
+
+// withQuery returns f overridden by the latency_median, latency_p99 and
+// error_rate query parameters, so a single request can be made slow or
+// failing whatever the service's settings.
+func (f faults) withQuery(q url.Values) (faults, error) {
+	return parseFaults(f, q.Get("latency_median"), q.Get("latency_p99"), q.Get("error_rate"))
+}
+
+// parseFaults returns base with the non-empty settings replaced.
+func parseFaults(base faults, median, p99, errorRate string) (faults, error) {
+	f := base
+	var err error
+	if median != "" {
+		if f.median, err = time.ParseDuration(median); err != nil || f.median <= 0 {
+			return faults{}, fmt.Errorf("invalid latency median %q", median)
+		}
+	}
+	if p99 != "" {
+		if f.p99, err = time.ParseDuration(p99); err != nil {
+			return faults{}, fmt.Errorf("invalid latency p99 %q", p99)
+		}
+	}
+	if f.p99 < f.median {
+		// A p99 below the median means no spread at all.
+		f.p99 = f.median
+	}
+	if errorRate != "" {
+		if f.errorRate, err = strconv.ParseFloat(errorRate, 64); err != nil || f.errorRate < 0 || f.errorRate > 1 {
+			return faults{}, fmt.Errorf("invalid error rate %q, want a value between 0 and 1", errorRate)
+		}
+	}
+	return f, nil
+}
+
+// latency draws a latency from the distribution.
+func (f faults) latency() time.Duration {
+	mu := math.Log(float64(f.median))
+	sigma := (math.Log(float64(f.p99)) - mu) / z99
+	return time.Duration(math.Exp(mu + sigma*rand.NormFloat64()))
+}
+
+// fail reports whether a call should fail.
+func (f faults) fail() bool {
+	return rand.Float64() < f.errorRate
+}
@@ -0,0 +1,9 @@
+package main
+
+import "servicekit"
+
+var DefaultPort = "8093"
+
+func main() {
+	servicekit.Run("payment", registerRoutes, servicekit.WithDefaultPort(DefaultPort))
+}
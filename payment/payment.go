@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/app-obs/go/observability"
+)
+
+var (
+	// ErrProviderUnavailable is returned for calls the faults make fail.
+	ErrProviderUnavailable = errors.New("payment provider unavailable")
+	// ErrChargeNotFound is returned when refunding an unknown charge.
+	ErrChargeNotFound = errors.New("charge not found")
+)
+
+// charge is a payment taken for an order.
+type charge struct {
+	orderID  string
+	userID   string
+	amount   int64
+	refunded bool
+}
+
+// PaymentProvider simulates a payment provider, in memory, whose calls take
+// as long and fail as often as its faults say.
+type PaymentProvider struct {
+	mu      sync.Mutex
+	charges map[string]*charge // by charge ID
+	nextID  atomic.Uint64
+}
+
+func NewPaymentProvider() *PaymentProvider {
+	return &PaymentProvider{charges: map[string]*charge{}}
+}
+
+// Charge charges amount, in cents, to userID for orderID and returns the
+// charge ID.
+func (p *PaymentProvider) Charge(ctx context.Context, f faults, orderID, userID string, amount int64) (string, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "PaymentProvider.Charge",
+		observability.String("order.id", orderID),
+	)
+	defer span.End()
+
+	if err := simulate(ctx, obs, span, f); err != nil {
+		return "", err
+	}
+
+	chargeID := "ch-" + strconv.FormatUint(p.nextID.Add(1), 10)
+	p.mu.Lock()
+	p.charges[chargeID] = &charge{orderID: orderID, userID: userID, amount: amount}
+	p.mu.Unlock()
+	span.SetAttributes(observability.String("payment.charge_id", chargeID))
+	return chargeID, nil
+}
+
+// Refund refunds the charge chargeID. Refunding a charge twice is not an
+// error.
+func (p *PaymentProvider) Refund(ctx context.Context, f faults, chargeID string) error {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "PaymentProvider.Refund",
+		observability.String("payment.charge_id", chargeID),
+	)
+	defer span.End()
+
+	if err := simulate(ctx, obs, span, f); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	c, ok := p.charges[chargeID]
+	if !ok {
+		return ErrChargeNotFound
+	}
+	c.refunded = true
+	return nil
+}
+
+// simulate waits for a latency drawn from f and fails as often as f says,
+// recording both on span.
+func simulate(ctx context.Context, obs *observability.Observability, span observability.Span, f faults) error {
+	latency := f.latency()
+	fail := f.fail()
+	span.SetAttributes(
+		observability.Int("payment.simulated_latency_ms", int(latency.Milliseconds())),
+		observability.Bool("payment.injected_error", fail),
+	)
+
+	select {
+	case <-time.After(latency):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if fail {
+		obs.ErrorHandler.Record(ErrProviderUnavailable, "Payment provider call failed")
+		return ErrProviderUnavailable
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+// registerRoutes sets the simulated payment provider up and returns the payment API.
+func registerRoutes(s *servicekit.Service) http.Handler {
+	defaults, err := faultsFromEnv()
+	if err != nil {
+		s.Fatal("Invalid payment faults", "error", err)
+	}
+	s.Obs.Log.Info("Payment faults configured",
+		"latencyMedian", defaults.median.String(),
+		"latencyP99", defaults.p99.String(),
+		"errorRate", defaults.errorRate,
+	)
+
+	// The provider is simulated in process, so it has no readiness check.
+	provider := NewPaymentProvider()
+
+	mux := http.NewServeMux()
+	s.Handle(mux, "POST /charges", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCharge(r.Context(), w, r, observability.ObsFromCtx(r.Context()), provider, defaults)
+	}))
+	s.Handle(mux, "POST /charges/{chargeID}/refund", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleRefund(r.Context(), w, r, observability.ObsFromCtx(r.Context()), provider, defaults)
+	}))
+
+	return obsmiddleware.WithRoute(mux)
+}
+
+// chargeRequest is the body of POST /charges.
+type chargeRequest struct {
+	OrderID string `json:"orderId"`
+	UserID  string `json:"userId"`
+	// Amount is in cents.
+	Amount int64 `json:"amount"`
+}
+
+// chargeResponse is the body of a successful POST /charges.
+type chargeResponse struct {
+	ChargeID string `json:"chargeId"`
+}
+
+// handleCharge charges the payment in the request body.
+func handleCharge(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	provider *PaymentProvider, defaults faults) {
+	f, err := defaults.withQuery(r.URL.Query())
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req chargeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		obs.ErrorHandler.HTTP(w, "Invalid charge", http.StatusBadRequest)
+		return
+	}
+	if req.OrderID == "" || req.UserID == "" || req.Amount <= 0 {
+		obs.ErrorHandler.HTTP(w, "Charge needs an orderId, a userId and a positive amount", http.StatusBadRequest)
+		return
+	}
+
+	chargeID, err := provider.Charge(ctx, f, req.OrderID, req.UserID, req.Amount)
+	if err != nil {
+		writeProviderError(w, obs, err)
+		return
+	}
+
+	obs.Log.Info("Payment charged", "orderID", req.OrderID, "chargeID", chargeID)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(chargeResponse{ChargeID: chargeID})
+}
+
+// handleRefund refunds the charge in the path.
+func handleRefund(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	provider *PaymentProvider, defaults faults) {
+	f, err := defaults.withQuery(r.URL.Query())
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	chargeID := r.PathValue("chargeID")
+	if err := provider.Refund(ctx, f, chargeID); err != nil {
+		writeProviderError(w, obs, err)
+		return
+	}
+
+	obs.Log.Info("Payment refunded", "chargeID", chargeID)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeProviderError answers with the status matching a provider error.
+func writeProviderError(w http.ResponseWriter, obs *observability.Observability, err error) {
+	switch {
+	case errors.Is(err, ErrChargeNotFound):
+		obs.ErrorHandler.HTTP(w, "Charge not found", http.StatusNotFound)
+	case errors.Is(err, ErrProviderUnavailable):
+		obs.ErrorHandler.HTTP(w, "Payment provider unavailable", http.StatusServiceUnavailable)
+	default:
+		obs.ErrorHandler.HTTP(w, "Payment failed", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,9 @@
+package main
+
+import "servicekit"
+
+var DefaultPort = "8095"
+
+func main() {
+	servicekit.Run("pricing", registerRoutes, servicekit.WithDefaultPort(DefaultPort))
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ErrRatesUnavailable is returned when the exchange rates of a currency can
+// be neither fetched nor served from the cache.
+var ErrRatesUnavailable = errors.New("exchange rates unavailable")
+
+// rateTable is the exchange rates from one base currency.
+type rateTable struct {
+	Base      string
+	Rates     map[string]float64 // units of each currency per unit of Base
+	FetchedAt time.Time
+	// Stale is set on a table served past its TTL because it could not be
+	// refreshed.
+	Stale bool
+}
+
+// externalClient is used for the calls to the exchange-rate API. It is kept
+// apart from any client used within the application: a third party gets
+// shorter timeouts and fewer idle connections than our own services.
+var externalClient = &http.Client{
+	Timeout: 5 * time.Second,
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   2 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   3 * time.Second,
+		ResponseHeaderTimeout: 4 * time.Second,
+	},
+}
+
+// rateClient fetches exchange rates from an API answering
+// GET <baseURL>/<currency> like https://open.er-api.com/v6/latest does.
+type rateClient struct {
+	baseURL string
+	host    string
+}
+
+func newRateClient(baseURL string) (*rateClient, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid exchange rate API URL %q", baseURL)
+	}
+	return &rateClient{baseURL: strings.TrimSuffix(baseURL, "/"), host: u.Hostname()}, nil
+}
+
+// Fetch fetches the rates from base in an ExchangeRateAPI.GetRates span. The
+// span describes the call as one to a third party, with peer.service and
+// server.address, so it shows up as an external dependency in service maps.
+// No trace context is sent: the trace IDs of our requests are none of the
+// third party's business.
+func (c *rateClient) Fetch(ctx context.Context, base string) (rateTable, error) {
+	target := c.baseURL + "/" + url.PathEscape(base)
+	ctx, _, span := observability.StartSpanFromCtxWith(ctx, "ExchangeRateAPI.GetRates",
+		observability.String("peer.service", "exchange-rate-api"),
+		observability.String("server.address", c.host),
+		observability.String("http.request.method", http.MethodGet),
+		observability.String("url.full", target),
+		observability.String("pricing.base_currency", base),
+	)
+	defer span.End()
+
+	table, err := c.fetch(ctx, target, span)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return rateTable{}, err
+	}
+	span.SetAttributes(observability.Int("pricing.rates.count", len(table.Rates)))
+	return table, nil
+}
+
+func (c *rateClient) fetch(ctx context.Context, target string, span observability.Span) (rateTable, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return rateTable{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := externalClient.Do(req)
+	if err != nil {
+		return rateTable{}, err
+	}
+	defer resp.Body.Close()
+	span.SetAttributes(observability.Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode != http.StatusOK {
+		return rateTable{}, fmt.Errorf("exchange rate API returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Result    string             `json:"result"`
+		ErrorType string             `json:"error-type"`
+		Base      string             `json:"base_code"`
+		Rates     map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return rateTable{}, fmt.Errorf("invalid exchange rate API response: %w", err)
+	}
+	if body.Result == "error" {
+		return rateTable{}, fmt.Errorf("exchange rate API error: %s", body.ErrorType)
+	}
+	if len(body.Rates) == 0 {
+		return rateTable{}, errors.New("exchange rate API returned no rates")
+	}
+	return rateTable{Base: body.Base, Rates: body.Rates, FetchedAt: time.Now()}, nil
+}
+
+// RateCache keeps the rates fetched from the exchange-rate API for ttl, and
+// serves them for up to maxStale more when they cannot be refreshed
+// (stale-if-error): slightly old rates beat failing every conversion while
+// the third party is down.
+type RateCache struct {
+	client   *rateClient
+	ttl      time.Duration
+	maxStale time.Duration
+
+	mu     sync.Mutex
+	tables map[string]rateTable // by base currency
+}
+
+func NewRateCache(client *rateClient, ttl, maxStale time.Duration) *RateCache {
+	return &RateCache{client: client, ttl: ttl, maxStale: maxStale, tables: map[string]rateTable{}}
+}
+
+// GetRates returns the rates from base in a RateCache.GetRates span, which
+// records cache.hit and, when the API failed and older rates were served in
+// its place, a pricing.rates.stale_fallback event and pricing.rates.stale.
+func (c *RateCache) GetRates(ctx context.Context, base string) (rateTable, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "RateCache.GetRates",
+		observability.String("pricing.base_currency", base),
+	)
+	defer span.End()
+
+	c.mu.Lock()
+	cached, ok := c.tables[base]
+	c.mu.Unlock()
+	age := time.Since(cached.FetchedAt)
+	if ok && age < c.ttl {
+		span.SetAttributes(observability.Bool("cache.hit", true), observability.Bool("pricing.rates.stale", false))
+		return cached, nil
+	}
+	span.SetAttributes(observability.Bool("cache.hit", false))
+
+	table, err := c.client.Fetch(ctx, base)
+	if err == nil {
+		c.mu.Lock()
+		c.tables[base] = table
+		c.mu.Unlock()
+		span.SetAttributes(observability.Bool("pricing.rates.stale", false))
+		return table, nil
+	}
+
+	if !ok || age > c.ttl+c.maxStale {
+		obs.ErrorHandler.Record(err, "Failed to fetch exchange rates")
+		return rateTable{}, fmt.Errorf("%w: %w", ErrRatesUnavailable, err)
+	}
+	// The API's failure is handled, so it is an event here rather than an
+	// error; the API's own span keeps the error status.
+	span.AddEvent("pricing.rates.stale_fallback", trace.WithAttributes(
+		observability.String("exception.message", err.Error()),
+		observability.Int("pricing.rates.age_s", int(age.Seconds())),
+	))
+	span.SetAttributes(observability.Bool("pricing.rates.stale", true))
+	obs.Log.Warn("Serving stale exchange rates", "baseCurrency", base, "ageSeconds", int(age.Seconds()), "error", err)
+	cached.Stale = true
+	return cached, nil
+}
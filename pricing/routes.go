@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvExchangeRateAPIURL       = "EXCHANGE_RATE_API_URL"
+	DefaultExchangeRateAPIURL   = "https://open.er-api.com/v6/latest"
+	EnvExchangeRateTTL          = "EXCHANGE_RATE_TTL"
+	DefaultExchangeRateTTL      = "1h"
+	EnvExchangeRateMaxStale     = "EXCHANGE_RATE_MAX_STALE"
+	DefaultExchangeRateMaxStale = "24h"
+)
+
+// currencyCode matches ISO 4217 currency codes.
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// registerRoutes sets the exchange-rate cache up and returns the conversion API.
+func registerRoutes(s *servicekit.Service) http.Handler {
+	ttl, err := time.ParseDuration(servicekit.Getenv(EnvExchangeRateTTL, DefaultExchangeRateTTL))
+	if err != nil || ttl <= 0 {
+		s.Fatal("Invalid exchange rate TTL", "value", servicekit.Getenv(EnvExchangeRateTTL, DefaultExchangeRateTTL))
+	}
+	maxStale, err := time.ParseDuration(servicekit.Getenv(EnvExchangeRateMaxStale, DefaultExchangeRateMaxStale))
+	if err != nil || maxStale < 0 {
+		s.Fatal("Invalid exchange rate max staleness", "value", servicekit.Getenv(EnvExchangeRateMaxStale, DefaultExchangeRateMaxStale))
+	}
+	apiURL := servicekit.Getenv(EnvExchangeRateAPIURL, DefaultExchangeRateAPIURL)
+	client, err := newRateClient(apiURL)
+	if err != nil {
+		s.Fatal("Invalid exchange rate API URL", "error", err)
+	}
+	// The exchange-rate API is a third party whose outages are covered by
+	// stale rates, so it is not a readiness check.
+	rates := NewRateCache(client, ttl, maxStale)
+	s.Obs.Log.Info("Exchange rates cached", "exchangeRateAPI", apiURL, "rateTTL", ttl.String(), "rateMaxStale", maxStale.String())
+
+	mux := http.NewServeMux()
+	s.Handle(mux, "GET /convert", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleConvert(r.Context(), w, r, observability.ObsFromCtx(r.Context()), rates)
+	}))
+
+	return obsmiddleware.WithRoute(mux)
+}
+
+// conversion is the answer of GET /convert.
+type conversion struct {
+	Amount    float64   `json:"amount"`
+	From      string    `json:"from"`
+	To        string    `json:"to"`
+	Rate      float64   `json:"rate"`
+	Converted float64   `json:"converted"`
+	Stale     bool      `json:"stale"`
+	RatesAsOf time.Time `json:"ratesAsOf"`
+}
+
+// handleConvert converts the amount in the query from one currency to
+// another, at the latest rates the cache has. Converted amounts are rounded
+// to two decimals.
+func handleConvert(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	rates *RateCache) {
+	query := r.URL.Query()
+	from := strings.ToUpper(query.Get("from"))
+	to := strings.ToUpper(query.Get("to"))
+	amount, err := strconv.ParseFloat(query.Get("amount"), 64)
+	if err != nil || amount < 0 || math.IsInf(amount, 0) {
+		obs.ErrorHandler.HTTP(w, "Invalid amount", http.StatusBadRequest)
+		return
+	}
+	if !currencyCode.MatchString(from) || !currencyCode.MatchString(to) {
+		obs.ErrorHandler.HTTP(w, "Invalid currency code", http.StatusBadRequest)
+		return
+	}
+	if span, ok := obsmiddleware.SpanFromContext(ctx); ok {
+		span.SetAttributes(
+			observability.String("pricing.from_currency", from),
+			observability.String("pricing.to_currency", to),
+		)
+	}
+
+	result := conversion{Amount: amount, From: from, To: to, Rate: 1, Converted: amount, RatesAsOf: time.Now()}
+	if from != to {
+		table, err := rates.GetRates(ctx, from)
+		if err != nil {
+			obs.ErrorHandler.HTTP(w, "Exchange rates unavailable", http.StatusBadGateway)
+			return
+		}
+		rate, ok := table.Rates[to]
+		if !ok {
+			obs.ErrorHandler.HTTP(w, "Unknown currency "+to, http.StatusBadRequest)
+			return
+		}
+		result.Rate = rate
+		result.Converted = math.Round(amount*rate*100) / 100
+		result.Stale = table.Stale
+		result.RatesAsOf = table.FetchedAt
+	}
+
+	obs.Log.Info("Amount converted", "from", from, "to", to, "stale", result.Stale)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
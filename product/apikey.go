@@ -0,0 +1,101 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+)
+
+var EnvAPIKeys = "PRODUCT_API_KEYS"
+
+const (
+	// APIKeyHeader carries the caller's API key.
+	APIKeyHeader = "X-API-Key"
+	// apiKeyParam is the query parameter some clients send the key in
+	// instead, which scrubAPIKey moves to APIKeyHeader.
+	apiKeyParam = "api_key"
+)
+
+// apiKeyAuth lets through the requests that carry one of a set of API keys.
+// Only the SHA-256 of each key is kept, so the keys cannot leak from the
+// process's memory into a dump or a profile. A nil *apiKeyAuth lets every
+// request through.
+type apiKeyAuth struct {
+	keys map[[sha256.Size]byte]bool
+}
+
+// newAPIKeyAuth returns the check of the comma-separated keys of
+// PRODUCT_API_KEYS, or nil if there are none.
+func newAPIKeyAuth(value string) *apiKeyAuth {
+	keys := make(map[[sha256.Size]byte]bool)
+	for _, key := range strings.Split(value, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys[sha256.Sum256([]byte(key))] = true
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	return &apiKeyAuth{keys: keys}
+}
+
+// Middleware answers the requests without a known key in APIKeyHeader with
+// 401. The key is only ever recorded as api.key_id, a short hash of it, on
+// the request span and the request's log records.
+func (a *apiKeyAuth) Middleware(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		obs := observability.ObsFromCtx(r.Context())
+		key := r.Header.Get(APIKeyHeader)
+		if key == "" {
+			obs.ErrorHandler.HTTP(w, "Missing API key", http.StatusUnauthorized)
+			return
+		}
+		sum := sha256.Sum256([]byte(key))
+		keyID := hex.EncodeToString(sum[:6])
+		if span, ok := obsmiddleware.SpanFromContext(r.Context()); ok {
+			span.SetAttributes(observability.String("api.key_id", keyID))
+		}
+		obs.Log = obs.Log.With("api.key_id", keyID)
+		if !a.keys[sum] {
+			obs.ErrorHandler.HTTP(w, "Invalid API key", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// scrubAPIKey moves an API key sent in the apiKeyParam query parameter to
+// APIKeyHeader, unless the header is already set, and replaces the parameter's
+// value with obsmiddleware.RedactedValue. It must wrap
+// obsmiddleware.WithObservability: the library records the URL as http.url and
+// http.target when it starts the request span, so the key has to be out of the
+// URL by then. Unlike OBS_STRIP_QUERY_ATTRIBUTES, this works with every APM
+// type and keeps the rest of the query. Headers are not recorded on spans, so
+// the key cannot reach them from APIKeyHeader either.
+func scrubAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if !query.Has(apiKeyParam) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := query.Get(apiKeyParam)
+		query.Set(apiKeyParam, obsmiddleware.RedactedValue)
+
+		scrubbed := r.Clone(r.Context())
+		scrubbed.URL.RawQuery = query.Encode()
+		scrubbed.RequestURI = scrubbed.URL.RequestURI()
+		if key != "" && scrubbed.Header.Get(APIKeyHeader) == "" {
+			scrubbed.Header.Set(APIKeyHeader, key)
+		}
+		next.ServeHTTP(w, scrubbed)
+	})
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+)
+
+var (
+	EnvProductCacheTTL      = "PRODUCT_CACHE_TTL"
+	DefaultCacheTTL         = "30s"
+	EnvProductCacheSize     = "PRODUCT_CACHE_SIZE"
+	DefaultProductCacheSize = "1000"
+)
+
+// Business events of the product cache.
+var (
+	eventProductCacheMiss  = obsmiddleware.DomainEvent{Name: "product.cache.miss", Counted: true}
+	eventProductCacheStale = obsmiddleware.DomainEvent{Name: "product.cache.stale", Counted: true}
+)
+
+// cacheEntry is a cached product lookup.
+type cacheEntry struct {
+	product   Product
+	fetchedAt time.Time
+}
+
+// cachedProductService keeps product info in memory. Once an entry is older
+// than ttl it is still served, and refreshed in the background so the next
+// request gets fresh data without waiting for the repository. It holds up to
+// size products; when full, it drops the stale ones, or else any one, to make
+// room.
+type cachedProductService struct {
+	next   ProductService
+	ttl    time.Duration
+	size   int
+	events *obsmiddleware.DomainEvents
+
+	mu         sync.Mutex
+	entries    map[string]cacheEntry
+	refreshing map[string]bool
+	// generation counts evictions. A lookup stores its result only if no
+	// entry was evicted since it started, so a load that raced with an update
+	// or a delete cannot bring the old product back.
+	generation uint64
+}
+
+func (s *cachedProductService) GetProductInfo(ctx context.Context, obs *observability.Observability, productID string) (Product, error) {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ProductCache.GetProductInfo", observability.String("product.id", productID))
+	defer span.End()
+
+	s.mu.Lock()
+	generation := s.generation
+	entry, found := s.entries[productID]
+	stale := found && time.Since(entry.fetchedAt) > s.ttl
+	refresh := stale && !s.refreshing[productID]
+	if refresh {
+		s.refreshing[productID] = true
+	}
+	s.mu.Unlock()
+
+	span.SetAttributes(
+		observability.Bool("cache.hit", found),
+		observability.Bool("cache.stale", stale),
+	)
+	if found {
+		if refresh {
+			s.events.Emit(obs, eventProductCacheStale, map[string]any{
+				"product.id":   productID,
+				"cache.age_ms": time.Since(entry.fetchedAt).Milliseconds(),
+			})
+			go s.refresh(ctx, productID, generation)
+		}
+		return entry.product, nil
+	}
+	s.events.Emit(obs, eventProductCacheMiss, map[string]any{"product.id": productID})
+
+	product, err := s.next.GetProductInfo(ctx, obs, productID)
+	if err != nil {
+		return Product{}, err
+	}
+	s.store(productID, product, generation)
+	return product, nil
+}
+
+// refresh reloads a stale entry. It runs after the request that found the
+// entry stale may have ended, so it records its own trace, linked to the
+// request's. generation is that of the cache when the entry was found stale.
+func (s *cachedProductService) refresh(origin context.Context, productID string, generation uint64) {
+	ctx, obs, span := obsmiddleware.StartBackgroundSpan(origin, "ProductCache.refresh", observability.String("product.id", productID))
+	defer span.End()
+	defer func() {
+		s.mu.Lock()
+		delete(s.refreshing, productID)
+		s.mu.Unlock()
+	}()
+
+	product, err := s.next.GetProductInfo(ctx, obs, productID)
+	if err != nil {
+		// Keep serving the stale entry; the next request retries the refresh.
+		obs.ErrorHandler.Record(err, "Failed to refresh cached product")
+		return
+	}
+	if !s.store(productID, product, generation) {
+		obsmiddleware.LogDebug(obs, "Dropped refreshed product evicted meanwhile", "productID", productID)
+		return
+	}
+	obsmiddleware.LogDebug(obs, "Refreshed cached product", "productID", productID)
+}
+
+// GetProducts, ListProducts, SearchProducts and CreateProduct go straight to
+// the wrapped service: only lookups of a single product are cached.
+func (s *cachedProductService) GetProducts(ctx context.Context, obs *observability.Observability, productIDs []string) ([]Product, error) {
+	return s.next.GetProducts(ctx, obs, productIDs)
+}
+
+func (s *cachedProductService) ListProducts(ctx context.Context, obs *observability.Observability) ([]Product, error) {
+	return s.next.ListProducts(ctx, obs)
+}
+
+func (s *cachedProductService) SearchProducts(ctx context.Context, obs *observability.Observability, query string, page, size int) (searchResult, error) {
+	return s.next.SearchProducts(ctx, obs, query, page, size)
+}
+
+func (s *cachedProductService) CreateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error) {
+	return s.next.CreateProduct(ctx, obs, product)
+}
+
+// UpdateProduct and DeleteProduct drop the cached entry of the product, so
+// lookups do not serve it until the TTL expires.
+func (s *cachedProductService) UpdateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error) {
+	updated, err := s.next.UpdateProduct(ctx, obs, product)
+	s.evict(product.ID)
+	return updated, err
+}
+
+func (s *cachedProductService) DeleteProduct(ctx context.Context, obs *observability.Observability, productID string) error {
+	err := s.next.DeleteProduct(ctx, obs, productID)
+	s.evict(productID)
+	return err
+}
+
+func (s *cachedProductService) evict(productID string) {
+	s.mu.Lock()
+	delete(s.entries, productID)
+	s.generation++
+	s.mu.Unlock()
+}
+
+// store caches product, loaded at generation, and reports whether it did: a
+// product loaded before an eviction may be outdated and is not stored.
+func (s *cachedProductService) store(productID string, product Product, generation uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.generation != generation {
+		return false
+	}
+	now := time.Now()
+	if _, ok := s.entries[productID]; !ok && len(s.entries) >= s.size {
+		for id, e := range s.entries {
+			if now.Sub(e.fetchedAt) > s.ttl {
+				delete(s.entries, id)
+			}
+		}
+		for id := range s.entries {
+			if len(s.entries) < s.size {
+				break
+			}
+			delete(s.entries, id)
+		}
+	}
+	s.entries[productID] = cacheEntry{product: product, fetchedAt: now}
+	return true
+}
+
+// NewCachedProductService wraps next with an in-memory cache of size products
+// whose entries are refreshed in the background once they are older than ttl.
+// Misses and stale entries are recorded through events.
+func NewCachedProductService(next ProductService, size int, ttl time.Duration, events *obsmiddleware.DomainEvents) ProductService {
+	return &cachedProductService{
+		next:       next,
+		ttl:        ttl,
+		size:       size,
+		events:     events,
+		entries:    make(map[string]cacheEntry, size),
+		refreshing: make(map[string]bool),
+	}
+}
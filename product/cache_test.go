@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheStoreSize(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name    string
+		entries map[string]time.Time // fetchedAt by product ID
+		store   string
+		want    []string
+	}{
+		{"room left", map[string]time.Time{"1": now}, "2", []string{"1", "2"}},
+		{"stale dropped first", map[string]time.Time{"1": now, "2": now.Add(-time.Hour)}, "3", []string{"1", "3"}},
+		{"refresh of a cached product", map[string]time.Time{"1": now, "2": now}, "2", []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &cachedProductService{ttl: time.Minute, size: 2, entries: make(map[string]cacheEntry)}
+			for id, fetchedAt := range tt.entries {
+				s.entries[id] = cacheEntry{product: Product{ID: id}, fetchedAt: fetchedAt}
+			}
+			if !s.store(tt.store, Product{ID: tt.store}, 0) {
+				t.Fatalf("store(%q) = false, want true", tt.store)
+			}
+			if len(s.entries) != len(tt.want) {
+				t.Errorf("entries = %d, want %d", len(s.entries), len(tt.want))
+			}
+			for _, id := range tt.want {
+				if _, ok := s.entries[id]; !ok {
+					t.Errorf("entry %q missing", id)
+				}
+			}
+		})
+	}
+}
+
+func TestCacheStoreFull(t *testing.T) {
+	now := time.Now()
+	s := &cachedProductService{ttl: time.Minute, size: 2, entries: map[string]cacheEntry{
+		"1": {product: Product{ID: "1"}, fetchedAt: now},
+		"2": {product: Product{ID: "2"}, fetchedAt: now},
+	}}
+	s.store("3", Product{ID: "3"}, 0)
+	if len(s.entries) != 2 {
+		t.Errorf("entries = %d, want 2", len(s.entries))
+	}
+	if _, ok := s.entries["3"]; !ok {
+		t.Error(`entry "3" missing`)
+	}
+}
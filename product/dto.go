@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Product is a product as the API returns it.
+type Product struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Price int64  `json:"price"` // in cents
+	Stock int    `json:"stock"`
+}
+
+// String returns the plain text form of p, served to clients that do not ask
+// for JSON.
+func (p Product) String() string {
+	return fmt.Sprintf("%s with ID %s", p.Name, p.ID)
+}
+
+// productList is a list of products as the API returns it.
+type productList []Product
+
+// String returns the plain text form of l, one product per line.
+func (l productList) String() string {
+	var b strings.Builder
+	for _, p := range l {
+		b.WriteString(p.String())
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// searchResult is a page of search results as the API returns it.
+type searchResult struct {
+	Query    string    `json:"query"`
+	Page     int       `json:"page"`
+	Size     int       `json:"size"`
+	Total    int       `json:"total"`
+	Pages    int       `json:"pages"`
+	Products []Product `json:"products"`
+}
+
+// String returns the plain text form of r: a summary line, then the products
+// of the page, one per line.
+func (r searchResult) String() string {
+	if r.Total == 0 {
+		return fmt.Sprintf("No products match %q\n", r.Query)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d products match %q, page %d of %d\n", r.Total, r.Query, r.Page, r.Pages)
+	b.WriteString(productList(r.Products).String())
+	return b.String()
+}
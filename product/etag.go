@@ -0,0 +1,72 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+)
+
+// respondCacheable answers a GET like obsmiddleware.Respond does with a 200,
+// but with an ETag computed from the body, so clients can revalidate their
+// copy. A request whose If-None-Match names the current ETag gets a 304
+// without a body. The request span records whether the client's copy was still fresh
+// in http.cache.hit.
+func respondCacheable(w http.ResponseWriter, r *http.Request, obs *observability.Observability, v fmt.Stringer) {
+	var body []byte
+	mediaType := obsmiddleware.Negotiate(r.Header.Get("Accept"), obsmiddleware.MediaTypeText, obsmiddleware.MediaTypeJSON)
+	switch mediaType {
+	case obsmiddleware.MediaTypeJSON:
+		var err error
+		if body, err = json.Marshal(v); err != nil {
+			obs.ErrorHandler.Record(err, "Failed to encode response")
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+	case obsmiddleware.MediaTypeText:
+		body = []byte(v.String())
+		mediaType += "; charset=utf-8"
+	default:
+		obs.ErrorHandler.HTTP(w, "Acceptable media types: text/plain, application/json", http.StatusNotAcceptable)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	w.Header().Set("ETag", etag)
+	// The body, and so the ETag, depends on the negotiated media type.
+	w.Header().Add("Vary", "Accept")
+
+	hit := etagMatches(r.Header.Get("If-None-Match"), etag)
+	if span, ok := obsmiddleware.SpanFromContext(r.Context()); ok {
+		span.SetAttributes(observability.Bool("http.cache.hit", hit))
+	}
+	if hit {
+		obsmiddleware.LogDebug(obs, "Client copy still fresh", "etag", etag)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", mediaType)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// etagMatches reports whether the If-None-Match header value ifNoneMatch
+// names etag, comparing weakly as RFC 9110 requires for it.
+func etagMatches(ifNoneMatch, etag string) bool {
+	if strings.TrimSpace(ifNoneMatch) == "*" {
+		return true
+	}
+	for _, tag := range strings.Split(ifNoneMatch, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
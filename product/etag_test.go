@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestETagMatches(t *testing.T) {
+	const etag = `"abc"`
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		want        bool
+	}{
+		{"no header", "", false},
+		{"same", `"abc"`, true},
+		{"different", `"xyz"`, false},
+		{"weak", `W/"abc"`, true},
+		{"any", "*", true},
+		{"any with spaces", " * ", true},
+		{"one of several", `"xyz", W/"abc"`, true},
+		{"none of several", `"xyz","uvw"`, false},
+		{"unquoted", "abc", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := etagMatches(tt.ifNoneMatch, etag); got != tt.want {
+				t.Errorf("etagMatches(%q, %q) = %v, want %v", tt.ifNoneMatch, etag, got, tt.want)
+			}
+		})
+	}
+}
@@ -2,9 +2,20 @@ module product
 
 go 1.24.2
 
-require github.com/app-obs/go v0.250805.5
+require (
+	github.com/app-obs/go v0.250805.5
+	github.com/coder/websocket v1.8.13
+	go.opentelemetry.io/otel v1.37.0
+	google.golang.org/grpc v1.73.0
+	grpcobs v0.0.0
+	obsmiddleware v0.0.0
+	proto v0.0.0
+	servicekit v0.0.0
+	wsobs v0.0.0
+)
 
 require (
+	apierror v0.0.0 // indirect
 	github.com/DataDog/appsec-internal-go v1.13.0 // indirect
 	github.com/DataDog/datadog-agent/pkg/obfuscate v0.66.1 // indirect
 	github.com/DataDog/datadog-agent/pkg/remoteconfig/state v0.66.1 // indirect
@@ -39,7 +50,6 @@ require (
 	github.com/tklauser/numcpus v0.8.0 // indirect
 	github.com/yusufpapurcu/wmi v1.2.4 // indirect
 	go.opentelemetry.io/auto/sdk v1.1.0 // indirect
-	go.opentelemetry.io/otel v1.37.0 // indirect
 	go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp v1.37.0 // indirect
 	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.37.0 // indirect
 	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.37.0 // indirect
@@ -56,7 +66,21 @@ require (
 	golang.org/x/xerrors v0.0.0-20231012003039-104605ab7028 // indirect
 	google.golang.org/genproto/googleapis/api v0.0.0-20250603155806-513f23925822 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250603155806-513f23925822 // indirect
-	google.golang.org/grpc v1.73.0 // indirect
 	google.golang.org/protobuf v1.36.6 // indirect
 	gopkg.in/DataDog/dd-trace-go.v1 v1.62.0 // indirect
+	health v0.0.0 // indirect
 )
+
+replace health => ../health
+
+replace grpcobs => ../grpcobs
+
+replace proto => ../proto
+
+replace wsobs => ../wsobs
+
+replace servicekit => ../servicekit
+
+replace apierror => ../apierror
+
+replace obsmiddleware => ../obsmiddleware
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"grpcobs"
+	"proto/productpb"
+	"servicekit"
+)
+
+var (
+	EnvGRPCPort     = "GRPC_PORT"
+	DefaultGRPCPort = "9086"
+)
+
+// productServer serves the product lookups of GET /product over gRPC, with
+// the same ProductService.
+type productServer struct {
+	productpb.UnimplementedProductServiceServer
+	service ProductService
+}
+
+func (s *productServer) GetProduct(ctx context.Context, req *productpb.GetProductRequest) (*productpb.GetProductResponse, error) {
+	obs := grpcobs.ObsFromCtx(ctx)
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing product ID")
+	}
+
+	productInfo, err := s.service.GetProductInfo(ctx, obs, req.GetId())
+	if err != nil {
+		if errors.Is(err, ErrProductNotFound) {
+			return nil, status.Error(codes.NotFound, "product not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to fetch product info")
+	}
+
+	obs.Log.Info("Product info fetched successfully", "productInfo", productInfo)
+	return &productpb.GetProductResponse{Info: productInfo.String()}, nil
+}
+
+// startGRPCServer serves ProductService on GRPC_PORT in the background, next
+// to the HTTP API. Every call gets a server span from grpcobs that continues
+// the caller's trace. The server drains along with the API when the service
+// stops.
+func startGRPCServer(s *servicekit.Service, service ProductService) error {
+	lis, err := net.Listen("tcp", ":"+servicekit.Getenv(EnvGRPCPort, DefaultGRPCPort))
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(grpcobs.UnaryServerInterceptor(s.Factory)))
+	productpb.RegisterProductServiceServer(server, &productServer{service: service})
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			s.Obs.ErrorHandler.Record(err, "gRPC server stopped")
+		}
+	}()
+	s.OnDrain(func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			// Cancel the calls still running.
+			server.Stop()
+			return ctx.Err()
+		}
+	})
+	s.Obs.Log.Info("gRPC server running", "address", lis.Addr().String())
+	return nil
+}
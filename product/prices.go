@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"github.com/coder/websocket"
+
+	"obsmiddleware"
+	"wsobs"
+)
+
+var (
+	EnvPriceUpdateInterval     = "PRICE_UPDATE_INTERVAL"
+	DefaultPriceUpdateInterval = "2s"
+)
+
+// priceUpdatesRoute is the pattern of the WebSocket price feed.
+const priceUpdatesRoute = "/products/{id}/price-updates"
+
+// priceUpdate is a message of the price feed.
+type priceUpdate struct {
+	ProductID string    `json:"productId"`
+	Price     int64     `json:"price"` // in cents
+	At        time.Time `json:"at"`
+}
+
+// newPriceUpdatesHandler serves GET /products/{id}/price-updates: a
+// WebSocket that sends the product's current price every interval, until the
+// client closes it. The connection and each message are traced through
+// wsobs. Messages from the client are only logged.
+func newPriceUpdatesHandler(factory *observability.Factory, service ProductService, interval time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		obs := observability.ObsFromCtx(ctx)
+		productID := r.PathValue("id")
+
+		// Answer unknown products with a 404 rather than an empty feed.
+		if _, err := service.GetProductInfo(ctx, obs, productID); err != nil {
+			writeProductError(w, obs, err, "Failed to fetch product info")
+			return
+		}
+
+		conn, err := wsobs.Accept(factory, w, r, priceUpdatesRoute, nil)
+		if err != nil {
+			// Accept has answered the request already.
+			obs.Log.Warn("WebSocket upgrade failed", "error", err)
+			return
+		}
+		conn.Obs().Log.Info("Price feed opened", "productID", productID)
+
+		// The client's messages, and its close, arrive through Receive.
+		closed := make(chan error, 1)
+		go func() {
+			for {
+				err := conn.Receive(ctx, func(ctx context.Context, obs *observability.Observability, _ websocket.MessageType, data []byte) error {
+					obsmiddleware.LogDebug(obs, "Ignoring message from price feed client", "productID", productID, "size", len(data))
+					return nil
+				})
+				if err != nil {
+					closed <- err
+					return
+				}
+			}
+		}()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				err := conn.Send(func(ctx context.Context, obs *observability.Observability) error {
+					return sendPrice(ctx, obs, conn, service, productID)
+				})
+				if err != nil {
+					conn.Close(err)
+					return
+				}
+			case err := <-closed:
+				conn.Obs().Log.Info("Price feed closed", "productID", productID)
+				conn.Close(err)
+				return
+			}
+		}
+	})
+}
+
+// sendPrice sends the current price of the product as one message.
+func sendPrice(ctx context.Context, obs *observability.Observability, conn *wsobs.Conn, service ProductService, productID string) error {
+	product, err := service.GetProductInfo(ctx, obs, productID)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(priceUpdate{ProductID: product.ID, Price: product.Price, At: time.Now()})
+	if err != nil {
+		return err
+	}
+	return conn.Write(ctx, websocket.MessageText, data)
+}
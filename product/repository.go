@@ -1,42 +1,269 @@
 package main
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvProductSeedCount     = "PRODUCT_SEED_COUNT"
+	DefaultProductSeedCount = "5000"
 )
 
 // ErrProductNotFound is returned when a product is not found.
 var ErrProductNotFound = errors.New("product not found")
 
+// ErrProductExists is returned when creating a product whose ID is taken.
+var ErrProductExists = errors.New("product already exists")
+
 type ProductRepository interface {
-	GetProductByID(ctx context.Context, obs *observability.Observability, id string) (string, error)
+	GetProductByID(ctx context.Context, obs *observability.Observability, id string) (Product, error)
+	GetProductsByIDs(ctx context.Context, obs *observability.Observability, ids []string) ([]Product, error)
+	ListProducts(ctx context.Context, obs *observability.Observability) ([]Product, error)
+	SearchProducts(ctx context.Context, obs *observability.Observability, terms []string, offset, limit int) (productPage, error)
+	CreateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error)
+	UpdateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error)
+	DeleteProduct(ctx context.Context, obs *observability.Observability, id string) error
 }
 
-type productRepositoryImpl struct{}
+// productPage is one page of the products matching a search.
+type productPage struct {
+	Products []Product
+	// Total is the number of products matching the search, on every page.
+	Total int
+}
 
-func (r *productRepositoryImpl) GetProductByID(ctx context.Context, obs *observability.Observability, id string) (string, error) {
-	ctx, obs, span := observability.StartSpanFromCtx(ctx, "ProductRepository.GetProductByID", observability.SpanAttributes{"product.id": id})
-	defer span.End()
+// productRepositoryImpl keeps products in memory, standing in for a
+// database. It starts with products "1" to "n", so the IDs the demos and the
+// load generator ask for exist.
+type productRepositoryImpl struct {
+	mu       sync.RWMutex
+	products map[string]Product
+	nextID   int
+}
 
-	obs.Log.With(
-		"productID", id,
-	).Debug("Fetching product data")
+func newProductRepositoryImpl(n int) *productRepositoryImpl {
+	r := &productRepositoryImpl{products: make(map[string]Product, n), nextID: n + 1}
+	for i := 1; i <= n; i++ {
+		id := strconv.Itoa(i)
+		r.products[id] = Product{ID: id, Name: seedName(i), Price: int64(999 + i%50*100), Stock: i % 200}
+	}
+	return r
+}
+
+// Words the names of the seeded products are made of. Their lengths are
+// coprime, so the names only repeat every 9*10*13 products and searches for
+// one or two words match varied numbers of products.
+var (
+	seedAdjectives = []string{"Classic", "Compact", "Deluxe", "Eco", "Modern", "Portable", "Rugged", "Smart", "Vintage"}
+	seedMaterials  = []string{"Bamboo", "Ceramic", "Copper", "Cotton", "Glass", "Leather", "Linen", "Oak", "Steel", "Wool"}
+	seedNouns      = []string{"Backpack", "Blanket", "Bottle", "Chair", "Clock", "Desk", "Headphones", "Kettle", "Lamp", "Mug", "Notebook", "Speaker", "Watch"}
+)
+
+// seedName returns the name of the seeded product i.
+func seedName(i int) string {
+	return seedAdjectives[i%len(seedAdjectives)] + " " + seedMaterials[i%len(seedMaterials)] + " " + seedNouns[i%len(seedNouns)]
+}
+
+func (r *productRepositoryImpl) GetProductByID(ctx context.Context, obs *observability.Observability, id string) (Product, error) {
+	obsmiddleware.LogDebug(obs, "Fetching product data", "productID", id)
+
+	// Simulate a bug: if the ID starts with "panic-", blow up so the
+	// obsmiddleware.Recoverer can be seen in action.
+	if strings.HasPrefix(id, "panic-") {
+		panic(fmt.Sprintf("corrupted product record %s", id))
+	}
 
-	// Simulate DB fetch: if the ID starts with "missing-", return not found.
-	if strings.HasPrefix(id, "missing-") {
+	r.mu.RLock()
+	product, ok := r.products[id]
+	r.mu.RUnlock()
+	if !ok {
 		obs.Log.With("productID", id).Warn("Product not found in repository")
-		return "", ErrProductNotFound
+		obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{"db.response.returned_rows": 0})
+		return Product{}, ErrProductNotFound
+	}
+
+	obsmiddleware.LogDebug(obs, "Product found in repository", "productID", id)
+	obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{"db.response.returned_rows": 1})
+	return product, nil
+}
+
+// GetProductsByIDs returns the products with the given IDs in one lookup, in
+// the order of ids. IDs with no product are skipped.
+func (r *productRepositoryImpl) GetProductsByIDs(ctx context.Context, obs *observability.Observability, ids []string) ([]Product, error) {
+	r.mu.RLock()
+	products := make([]Product, 0, len(ids))
+	for _, id := range ids {
+		if p, ok := r.products[id]; ok {
+			products = append(products, p)
+		}
+	}
+	r.mu.RUnlock()
+
+	obsmiddleware.LogDebug(obs, "Products found in repository", "requested", len(ids), "found", len(products))
+	obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{"db.response.returned_rows": len(products)})
+	return products, nil
+}
+
+// ListProducts returns every product, ordered by ID.
+func (r *productRepositoryImpl) ListProducts(ctx context.Context, obs *observability.Observability) ([]Product, error) {
+	r.mu.RLock()
+	products := make([]Product, 0, len(r.products))
+	for _, p := range r.products {
+		products = append(products, p)
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(products, compareProductIDs)
+	return products, nil
+}
+
+// SearchProducts returns the products whose name contains every one of
+// terms, ignoring case, ordered by ID, from the offset-th on and at most
+// limit of them. The terms must be lower case. No terms match every product.
+func (r *productRepositoryImpl) SearchProducts(ctx context.Context, obs *observability.Observability, terms []string, offset, limit int) (productPage, error) {
+	r.mu.RLock()
+	var matches []Product
+	for _, p := range r.products {
+		name := strings.ToLower(p.Name)
+		if !slices.ContainsFunc(terms, func(term string) bool { return !strings.Contains(name, term) }) {
+			matches = append(matches, p)
+		}
+	}
+	r.mu.RUnlock()
+
+	slices.SortFunc(matches, compareProductIDs)
+	page := productPage{Total: len(matches)}
+	if offset < len(matches) {
+		page.Products = matches[offset:min(offset+limit, len(matches))]
 	}
+	obsmiddleware.LogDebug(obs, "Products searched in repository", "matches", page.Total, "returned", len(page.Products))
+	obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{"db.response.returned_rows": len(page.Products)})
+	return page, nil
+}
+
+// compareProductIDs orders products by ID. Numeric IDs sort by number,
+// before the others.
+func compareProductIDs(a, b Product) int {
+	an, aErr := strconv.Atoi(a.ID)
+	bn, bErr := strconv.Atoi(b.ID)
+	switch {
+	case aErr == nil && bErr == nil:
+		return cmp.Compare(an, bn)
+	case aErr == nil:
+		return -1
+	case bErr == nil:
+		return 1
+	}
+	return strings.Compare(a.ID, b.ID)
+}
+
+// CreateProduct stores product, under the next free numeric ID if it has
+// none.
+func (r *productRepositoryImpl) CreateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if product.ID == "" {
+		for {
+			product.ID = strconv.Itoa(r.nextID)
+			r.nextID++
+			if _, taken := r.products[product.ID]; !taken {
+				break
+			}
+		}
+	} else if _, taken := r.products[product.ID]; taken {
+		return Product{}, ErrProductExists
+	}
+	r.products[product.ID] = product
+	return product, nil
+}
+
+// UpdateProduct replaces the stored product with the ID of product.
+func (r *productRepositoryImpl) UpdateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.products[product.ID]; !ok {
+		return Product{}, ErrProductNotFound
+	}
+	r.products[product.ID] = product
+	return product, nil
+}
+
+func (r *productRepositoryImpl) DeleteProduct(ctx context.Context, obs *observability.Observability, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.products[id]; !ok {
+		return ErrProductNotFound
+	}
+	delete(r.products, id)
+	return nil
+}
+
+// tracedProductRepository traces every call to the wrapped repository.
+type tracedProductRepository struct {
+	next ProductRepository
+}
 
-	// Otherwise, return a dummy product with its ID.
-	obs.Log.With("productID", id).Debug("Product found in repository")
-	return fmt.Sprintf("Product ABC with ID %s", id), nil
+func (r *tracedProductRepository) GetProductByID(ctx context.Context, obs *observability.Observability, id string) (Product, error) {
+	return obsmiddleware.Traced(ctx, "ProductRepository.GetProductByID", func(ctx context.Context, obs *observability.Observability) (Product, error) {
+		return r.next.GetProductByID(ctx, obs, id)
+	}, observability.String("product.id", id))
 }
 
+func (r *tracedProductRepository) GetProductsByIDs(ctx context.Context, obs *observability.Observability, ids []string) ([]Product, error) {
+	return obsmiddleware.Traced(ctx, "ProductRepository.GetProductsByIDs", func(ctx context.Context, obs *observability.Observability) ([]Product, error) {
+		return r.next.GetProductsByIDs(ctx, obs, ids)
+	}, observability.Int("product.requested", len(ids)))
+}
+
+func (r *tracedProductRepository) ListProducts(ctx context.Context, obs *observability.Observability) ([]Product, error) {
+	return obsmiddleware.Traced(ctx, "ProductRepository.ListProducts", func(ctx context.Context, obs *observability.Observability) ([]Product, error) {
+		return r.next.ListProducts(ctx, obs)
+	})
+}
+
+func (r *tracedProductRepository) SearchProducts(ctx context.Context, obs *observability.Observability, terms []string, offset, limit int) (productPage, error) {
+	return obsmiddleware.Traced(ctx, "ProductRepository.SearchProducts", func(ctx context.Context, obs *observability.Observability) (productPage, error) {
+		return r.next.SearchProducts(ctx, obs, terms, offset, limit)
+	}, observability.Int("db.query.offset", offset), observability.Int("db.query.limit", limit))
+}
+
+func (r *tracedProductRepository) CreateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error) {
+	return obsmiddleware.Traced(ctx, "ProductRepository.CreateProduct", func(ctx context.Context, obs *observability.Observability) (Product, error) {
+		return r.next.CreateProduct(ctx, obs, product)
+	}, observability.String("product.id", product.ID))
+}
+
+func (r *tracedProductRepository) UpdateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error) {
+	return obsmiddleware.Traced(ctx, "ProductRepository.UpdateProduct", func(ctx context.Context, obs *observability.Observability) (Product, error) {
+		return r.next.UpdateProduct(ctx, obs, product)
+	}, observability.String("product.id", product.ID))
+}
+
+func (r *tracedProductRepository) DeleteProduct(ctx context.Context, obs *observability.Observability, id string) error {
+	_, err := obsmiddleware.Traced(ctx, "ProductRepository.DeleteProduct", func(ctx context.Context, obs *observability.Observability) (struct{}, error) {
+		return struct{}{}, r.next.DeleteProduct(ctx, obs, id)
+	}, observability.String("product.id", id))
+	return err
+}
+
+// NewProductRepository returns the in-memory repository, seeded with
+// PRODUCT_SEED_COUNT products.
 func NewProductRepository() ProductRepository {
-	return &productRepositoryImpl{}
-}
\ No newline at end of file
+	n, err := strconv.Atoi(servicekit.Getenv(EnvProductSeedCount, DefaultProductSeedCount))
+	if err != nil || n < 0 {
+		n, _ = strconv.Atoi(DefaultProductSeedCount)
+	}
+	return &tracedProductRepository{next: newProductRepositoryImpl(n)}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvReviewsErrorRate     = "REVIEWS_ERROR_RATE"
+	DefaultReviewsErrorRate = "0"
+)
+
+// ErrReviewsUnavailable is returned for the review lookups that
+// REVIEWS_ERROR_RATE makes fail.
+var ErrReviewsUnavailable = errors.New("reviews store unavailable")
+
+// Review is a review of a product as the API returns it.
+type Review struct {
+	UserID  string `json:"userId"`
+	Rating  int    `json:"rating"` // 1 to 5
+	Comment string `json:"comment"`
+}
+
+// reviewSummary is the reviews of a product as the API returns them.
+type reviewSummary struct {
+	ProductID     string   `json:"productId"`
+	Count         int      `json:"count"`
+	AverageRating float64  `json:"averageRating"`
+	Reviews       []Review `json:"reviews"`
+}
+
+// String returns the plain text form of s: the average rating, then one
+// review per line.
+func (s reviewSummary) String() string {
+	if s.Count == 0 {
+		return "No reviews yet\n"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%.1f/5 from %d reviews\n", s.AverageRating, s.Count)
+	for _, r := range s.Reviews {
+		fmt.Fprintf(&b, "%d/5 by user %s: %s\n", r.Rating, r.UserID, r.Comment)
+	}
+	return b.String()
+}
+
+type ReviewRepository interface {
+	ListReviews(ctx context.Context, obs *observability.Observability, productID string) ([]Review, error)
+}
+
+// reviewComments are the comments of the generated reviews, by rating.
+var reviewComments = [...][]string{
+	1: {"Broke within a week.", "Not as described."},
+	2: {"Disappointing for the price.", "Arrived late and scratched."},
+	3: {"Does the job.", "Fine, nothing special."},
+	4: {"Good value.", "Works well, would buy again."},
+	5: {"Excellent quality!", "Exactly what I needed."},
+}
+
+// reviewRepositoryImpl makes up the reviews of each product from its ID, so
+// a product always has the same reviews, standing in for a review store. A
+// share errorRate of lookups fail, so the callers' handling of a missing
+// optional dependency can be seen in traces.
+type reviewRepositoryImpl struct {
+	errorRate float64
+}
+
+func (r *reviewRepositoryImpl) ListReviews(ctx context.Context, obs *observability.Observability, productID string) ([]Review, error) {
+	if rand.Float64() < r.errorRate {
+		obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{"reviews.injected_error": true})
+		return nil, ErrReviewsUnavailable
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(productID))
+	rng := rand.New(rand.NewPCG(h.Sum64(), 0))
+	reviews := make([]Review, rng.IntN(8))
+	for i := range reviews {
+		// Ratings lean towards the positive, as they do in real shops.
+		rating := 1 + rng.IntN(4) + rng.IntN(2)
+		comments := reviewComments[rating]
+		reviews[i] = Review{
+			UserID:  strconv.Itoa(1 + rng.IntN(500)),
+			Rating:  rating,
+			Comment: comments[rng.IntN(len(comments))],
+		}
+	}
+
+	obsmiddleware.LogDebug(obs, "Reviews found in repository", "productID", productID, "count", len(reviews))
+	obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{"db.response.returned_rows": len(reviews)})
+	return reviews, nil
+}
+
+// tracedReviewRepository traces every call to the wrapped repository.
+type tracedReviewRepository struct {
+	next ReviewRepository
+}
+
+func (r *tracedReviewRepository) ListReviews(ctx context.Context, obs *observability.Observability, productID string) ([]Review, error) {
+	return obsmiddleware.Traced(ctx, "ReviewRepository.ListReviews", func(ctx context.Context, obs *observability.Observability) ([]Review, error) {
+		return r.next.ListReviews(ctx, obs, productID)
+	}, observability.String("product.id", productID))
+}
+
+// NewReviewRepository returns the generated review repository, failing a
+// share REVIEWS_ERROR_RATE (0 to 1) of lookups.
+func NewReviewRepository() (ReviewRepository, error) {
+	value := servicekit.Getenv(EnvReviewsErrorRate, DefaultReviewsErrorRate)
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil || rate < 0 || rate > 1 {
+		return nil, fmt.Errorf("invalid reviews error rate %q", value)
+	}
+	return &tracedReviewRepository{next: &reviewRepositoryImpl{errorRate: rate}}, nil
+}
+
+type ReviewService interface {
+	GetReviews(ctx context.Context, obs *observability.Observability, productID string) (reviewSummary, error)
+}
+
+type reviewServiceImpl struct {
+	repo ReviewRepository
+}
+
+// GetReviews returns the reviews of productID with their average rating.
+func (s *reviewServiceImpl) GetReviews(ctx context.Context, obs *observability.Observability, productID string) (reviewSummary, error) {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ReviewService.GetReviews",
+		observability.String("product.id", productID),
+	)
+	defer span.End()
+
+	reviews, err := s.repo.ListReviews(ctx, obs, productID)
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error fetching reviews")
+		return reviewSummary{}, err
+	}
+	summary := reviewSummary{ProductID: productID, Count: len(reviews), Reviews: reviews}
+	if summary.Count > 0 {
+		total := 0
+		for _, r := range reviews {
+			total += r.Rating
+		}
+		summary.AverageRating = float64(total) / float64(summary.Count)
+	}
+	span.SetAttributes(
+		observability.Int("review.count", summary.Count),
+		attribute.Float64("review.average_rating", summary.AverageRating),
+	)
+	return summary, nil
+}
+
+func NewReviewService(repo ReviewRepository) ReviewService {
+	return &reviewServiceImpl{repo: repo}
+}
+
+// handleGetReviews answers with the reviews of the product in the path.
+// Products without reviews, unknown ones included, get an empty list.
+func handleGetReviews(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service ReviewService) {
+	productID := r.PathValue("id")
+	summary, err := service.GetReviews(ctx, obs, productID)
+	if err != nil {
+		if errors.Is(err, ErrReviewsUnavailable) {
+			obs.ErrorHandler.HTTP(w, "Reviews unavailable", http.StatusServiceUnavailable)
+		} else {
+			obs.ErrorHandler.HTTP(w, "Failed to fetch reviews", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	obs.Log.Info("Reviews fetched", "productID", productID, "count", summary.Count)
+	respondCacheable(w, r, obs, summary)
+}
@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+// registerRoutes sets the catalog up, starts the gRPC server, and returns the product API.
+func registerRoutes(s *servicekit.Service) http.Handler {
+	repo := NewProductRepository()
+	cacheTTL, err := time.ParseDuration(servicekit.Getenv(EnvProductCacheTTL, DefaultCacheTTL))
+	if err != nil {
+		s.Fatal("Invalid product cache TTL", "error", err)
+	}
+	cacheSize, err := strconv.Atoi(servicekit.Getenv(EnvProductCacheSize, DefaultProductCacheSize))
+	if err != nil || cacheSize <= 0 {
+		s.Fatal("Invalid product cache size", "value", servicekit.Getenv(EnvProductCacheSize, DefaultProductCacheSize))
+	}
+
+	priceUpdateInterval, err := time.ParseDuration(servicekit.Getenv(EnvPriceUpdateInterval, DefaultPriceUpdateInterval))
+	if err != nil || priceUpdateInterval <= 0 {
+		s.Fatal("Invalid price update interval", "value", servicekit.Getenv(EnvPriceUpdateInterval, DefaultPriceUpdateInterval))
+	}
+
+	events, err := obsmiddleware.NewDomainEvents(s.Meter)
+	if err != nil {
+		s.Fatal("Failed to create domain events", "error", err)
+	}
+	service := NewCachedProductService(NewProductService(repo), cacheSize, cacheTTL, events)
+	reviewRepo, err := NewReviewRepository()
+	if err != nil {
+		s.Fatal("Invalid reviews configuration", "error", err)
+	}
+	reviews := NewReviewService(reviewRepo)
+
+	mux := http.NewServeMux()
+	s.Handle(mux, "GET /product", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleProduct(r.Context(), w, r, observability.ObsFromCtx(r.Context()), service)
+	}))
+
+	// With PRODUCT_API_KEYS set, changing products requires one of its keys.
+	apiKeys := newAPIKeyAuth(servicekit.Getenv(EnvAPIKeys, ""))
+	// Keys sent in the URL are moved to the header before the span records the URL.
+	s.BeforeSpan(scrubAPIKey)
+
+	// The CRUD API.
+	handle := func(pattern string, handler func(context.Context, http.ResponseWriter, *http.Request, *observability.Observability, ProductService)) {
+		var h http.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(r.Context(), w, r, observability.ObsFromCtx(r.Context()), service)
+		})
+		if !strings.HasPrefix(pattern, "GET ") {
+			h = apiKeys.Middleware(h)
+		}
+		s.Handle(mux, pattern, h)
+	}
+	handle("GET /products", handleListProducts)
+	handle("POST /products", handleCreateProduct)
+	handle("GET /products/search", handleSearchProducts)
+	handle("GET /products/{id}", handleGetProduct)
+	handle("PUT /products/{id}", handleUpdateProduct)
+	handle("DELETE /products/{id}", handleDeleteProduct)
+	s.Handle(mux, "GET /products/{id}/reviews", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleGetReviews(r.Context(), w, r, observability.ObsFromCtx(r.Context()), reviews)
+	}))
+	s.Handle(mux, "GET "+priceUpdatesRoute, newPriceUpdatesHandler(s.Factory, service, priceUpdateInterval))
+
+	if err := startGRPCServer(s, service); err != nil {
+		s.Fatal("Failed to start gRPC server", "error", err)
+	}
+
+	return obsmiddleware.WithRoute(mux)
+}
+
+func handleProduct(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service ProductService) {
+	productID := r.URL.Query().Get("id")
+
+	if productID == "" {
+		obs.ErrorHandler.HTTP(w, "Missing product ID", http.StatusBadRequest)
+		return
+	}
+	getProduct(ctx, w, r, obs, service, productID)
+}
+
+// handleGetProduct answers with the product in the path, like GET /product.
+func handleGetProduct(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service ProductService) {
+	getProduct(ctx, w, r, obs, service, r.PathValue("id"))
+}
+
+func getProduct(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service ProductService, productID string) {
+	obsmiddleware.LogDebug(obs, "Searching for product info", "productID", productID)
+
+	productInfo, err := service.GetProductInfo(ctx, obs, productID)
+	if err != nil {
+		if errors.Is(err, ErrProductNotFound) {
+			obs.ErrorHandler.HTTP(w, "Product not found", http.StatusNotFound)
+		} else {
+			obs.ErrorHandler.HTTP(w, "Failed to fetch product info", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	obs.Log.Info("Product info fetched successfully", "productInfo", productInfo)
+	respondCacheable(w, r, obs, productInfo)
+}
+
+// maxBulkProducts bounds the IDs of one GET /products?ids=... request.
+const maxBulkProducts = 100
+
+// handleListProducts answers with every product, or with those listed in the
+// comma-separated ids query parameter, in that order and without the IDs that
+// have no product.
+func handleListProducts(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service ProductService) {
+	if r.URL.Query().Has("ids") {
+		handleGetProducts(ctx, w, r, obs, service)
+		return
+	}
+	products, err := service.ListProducts(ctx, obs)
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, "Failed to list products", http.StatusInternalServerError)
+		return
+	}
+
+	obs.Log.Info("Products listed", "count", len(products))
+	respondCacheable(w, r, obs, productList(products))
+}
+
+func handleGetProducts(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service ProductService) {
+	var ids []string
+	for _, id := range strings.Split(r.URL.Query().Get("ids"), ",") {
+		if id = strings.TrimSpace(id); id != "" && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 || len(ids) > maxBulkProducts {
+		obs.ErrorHandler.HTTP(w, fmt.Sprintf("ids must list between 1 and %d product IDs", maxBulkProducts), http.StatusBadRequest)
+		return
+	}
+
+	products, err := service.GetProducts(ctx, obs, ids)
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, "Failed to fetch products", http.StatusInternalServerError)
+		return
+	}
+
+	obs.Log.Info("Products fetched", "requested", len(ids), "found", len(products))
+	respondCacheable(w, r, obs, productList(products))
+}
+
+// Page sizes of GET /products/search.
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+// handleSearchProducts answers with a page of the products whose name
+// contains every word of the q query parameter. page counts from 1, and size
+// is the number of products per page.
+func handleSearchProducts(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service ProductService) {
+	query := r.URL.Query()
+	page, err := intParam(query, "page", 1)
+	if err != nil || page < 1 {
+		obs.ErrorHandler.HTTP(w, "page must be a positive number", http.StatusBadRequest)
+		return
+	}
+	size, err := intParam(query, "size", defaultSearchPageSize)
+	if err != nil || size < 1 || size > maxSearchPageSize {
+		obs.ErrorHandler.HTTP(w, fmt.Sprintf("size must be between 1 and %d", maxSearchPageSize), http.StatusBadRequest)
+		return
+	}
+
+	result, err := service.SearchProducts(ctx, obs, query.Get("q"), page, size)
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, "Failed to search products", http.StatusInternalServerError)
+		return
+	}
+
+	obs.Log.Info("Products searched", "total", result.Total, "page", result.Page, "returned", len(result.Products))
+	respondCacheable(w, r, obs, result)
+}
+
+// intParam returns the integer value of the query parameter name, or def if
+// it is absent.
+func intParam(query url.Values, name string, def int) (int, error) {
+	if !query.Has(name) {
+		return def, nil
+	}
+	return strconv.Atoi(query.Get(name))
+}
+
+// handleCreateProduct stores the product in the request body and answers
+// with it, under the ID it got if the body has none.
+func handleCreateProduct(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service ProductService) {
+	product, ok := decodeProduct(w, r, obs)
+	if !ok {
+		return
+	}
+
+	created, err := service.CreateProduct(ctx, obs, product)
+	if err != nil {
+		writeProductError(w, obs, err, "Failed to create product")
+		return
+	}
+
+	w.Header().Set("Location", "/products/"+url.PathEscape(created.ID))
+	obsmiddleware.Respond(w, r, obs, http.StatusCreated, created)
+}
+
+// handleUpdateProduct replaces the product in the path with the one in the
+// request body.
+func handleUpdateProduct(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service ProductService) {
+	product, ok := decodeProduct(w, r, obs)
+	if !ok {
+		return
+	}
+	productID := r.PathValue("id")
+	if product.ID != "" && product.ID != productID {
+		obs.ErrorHandler.HTTP(w, "Product ID in the body does not match the path", http.StatusBadRequest)
+		return
+	}
+	product.ID = productID
+
+	updated, err := service.UpdateProduct(ctx, obs, product)
+	if err != nil {
+		writeProductError(w, obs, err, "Failed to update product")
+		return
+	}
+	obsmiddleware.Respond(w, r, obs, http.StatusOK, updated)
+}
+
+// handleDeleteProduct deletes the product in the path.
+func handleDeleteProduct(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service ProductService) {
+	if err := service.DeleteProduct(ctx, obs, r.PathValue("id")); err != nil {
+		writeProductError(w, obs, err, "Failed to delete product")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// decodeProduct reads the product in the request body. On failure it
+// answers with a 400 and returns false.
+func decodeProduct(w http.ResponseWriter, r *http.Request, obs *observability.Observability) (Product, bool) {
+	var product Product
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&product); err != nil {
+		obs.ErrorHandler.HTTP(w, "Invalid product: "+err.Error(), http.StatusBadRequest)
+		return Product{}, false
+	}
+	return product, true
+}
+
+// writeProductError answers with the status that err calls for: 400 for a
+// ValidationError, 404 for an unknown product and 409 for a taken ID, with
+// msg and a 500 otherwise.
+func writeProductError(w http.ResponseWriter, obs *observability.Observability, err error, msg string) {
+	var invalid *ValidationError
+	switch {
+	case errors.As(err, &invalid):
+		obs.ErrorHandler.HTTP(w, invalid.Error(), http.StatusBadRequest)
+	case errors.Is(err, ErrProductNotFound):
+		obs.ErrorHandler.HTTP(w, "Product not found", http.StatusNotFound)
+	case errors.Is(err, ErrProductExists):
+		obs.ErrorHandler.HTTP(w, "Product already exists", http.StatusConflict)
+	default:
+		obs.ErrorHandler.HTTP(w, msg, http.StatusInternalServerError)
+	}
+}
@@ -2,32 +2,70 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
 
 	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
 )
 
 type ProductService interface {
-	GetProductInfo(ctx context.Context, obs *observability.Observability, productID string) (string, error)
+	GetProductInfo(ctx context.Context, obs *observability.Observability, productID string) (Product, error)
+	GetProducts(ctx context.Context, obs *observability.Observability, productIDs []string) ([]Product, error)
+	ListProducts(ctx context.Context, obs *observability.Observability) ([]Product, error)
+	SearchProducts(ctx context.Context, obs *observability.Observability, query string, page, size int) (searchResult, error)
+	CreateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error)
+	UpdateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error)
+	DeleteProduct(ctx context.Context, obs *observability.Observability, productID string) error
+}
+
+// ValidationError is returned for a product that cannot be stored as given.
+// Its message is meant for the client.
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+// validateProduct checks the fields a client sets when creating or updating
+// a product.
+func validateProduct(p Product) error {
+	switch {
+	case strings.TrimSpace(p.Name) == "":
+		return &ValidationError{Field: "name", Reason: "must not be empty"}
+	case p.Price < 0:
+		return &ValidationError{Field: "price", Reason: "must not be negative"}
+	case p.Stock < 0:
+		return &ValidationError{Field: "stock", Reason: "must not be negative"}
+	case strings.ContainsAny(p.ID, "/?#"):
+		return &ValidationError{Field: "id", Reason: "must not contain '/', '?' or '#'"}
+	}
+	return nil
 }
 
 type productServiceImpl struct {
 	repo ProductRepository
 }
 
-func (s *productServiceImpl) GetProductInfo(ctx context.Context, obs *observability.Observability, productID string) (string, error) {
-	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "ProductService.GetProductInfo",
+func (s *productServiceImpl) GetProductInfo(ctx context.Context, obs *observability.Observability, productID string) (Product, error) {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ProductService.GetProductInfo",
 		observability.String("product.id", productID),
 	)
 	defer span.End()
 
-	obs.Log.With(
-		"productID", productID,
-	).Debug("Processing request")
+	obsmiddleware.LogDebug(obs, "Processing request", "productID", productID)
 
 	productInfo, err := s.repo.GetProductByID(ctx, obs, productID)
 	if err != nil {
 		obs.ErrorHandler.Record(err, "Error fetching product")
-		return "", err
+		return Product{}, err
 	}
 
 	obs.Log.With(
@@ -37,6 +75,133 @@ func (s *productServiceImpl) GetProductInfo(ctx context.Context, obs *observabil
 	return productInfo, nil
 }
 
+// GetProducts looks up the products with the given IDs at once, skipping
+// those that do not exist.
+func (s *productServiceImpl) GetProducts(ctx context.Context, obs *observability.Observability, productIDs []string) ([]Product, error) {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ProductService.GetProducts",
+		observability.Int("product.requested", len(productIDs)),
+	)
+	defer span.End()
+
+	products, err := s.repo.GetProductsByIDs(ctx, obs, productIDs)
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error fetching products")
+		return nil, err
+	}
+	span.SetAttributes(observability.Int("product.count", len(products)))
+	return products, nil
+}
+
+func (s *productServiceImpl) ListProducts(ctx context.Context, obs *observability.Observability) ([]Product, error) {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ProductService.ListProducts")
+	defer span.End()
+
+	products, err := s.repo.ListProducts(ctx, obs)
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error listing products")
+		return nil, err
+	}
+	span.SetAttributes(observability.Int("product.count", len(products)))
+	return products, nil
+}
+
+// SearchProducts returns page page, counted from 1, of the products whose
+// name contains every word of query, size products per page. The span
+// records a hash of the query rather than the query itself, which may hold
+// anything a user typed, along with the page and the result counts.
+func (s *productServiceImpl) SearchProducts(ctx context.Context, obs *observability.Observability, query string, page, size int) (searchResult, error) {
+	terms := strings.Fields(strings.ToLower(query))
+	sum := sha256.Sum256([]byte(strings.Join(terms, " ")))
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ProductService.SearchProducts",
+		observability.String("search.query_hash", hex.EncodeToString(sum[:8])),
+		observability.Int("search.terms", len(terms)),
+		observability.Int("search.page", page),
+		observability.Int("search.page_size", size),
+	)
+	defer span.End()
+
+	found, err := s.repo.SearchProducts(ctx, obs, terms, (page-1)*size, size)
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error searching products")
+		return searchResult{}, err
+	}
+	result := searchResult{
+		Query:    query,
+		Page:     page,
+		Size:     size,
+		Total:    found.Total,
+		Pages:    (found.Total + size - 1) / size,
+		Products: found.Products,
+	}
+	if result.Products == nil {
+		result.Products = []Product{}
+	}
+	span.SetAttributes(
+		observability.Int("search.results", result.Total),
+		observability.Int("search.page_count", result.Pages),
+		observability.Int("product.count", len(result.Products)),
+	)
+	return result, nil
+}
+
+func (s *productServiceImpl) CreateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error) {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ProductService.CreateProduct")
+	defer span.End()
+
+	if err := validateProduct(product); err != nil {
+		return Product{}, err
+	}
+	created, err := s.repo.CreateProduct(ctx, obs, product)
+	if err != nil {
+		if !errors.Is(err, ErrProductExists) {
+			obs.ErrorHandler.Record(err, "Error creating product")
+		}
+		return Product{}, err
+	}
+
+	span.SetAttributes(observability.String("product.id", created.ID))
+	obs.Log.Info("Product created", "product", created)
+	return created, nil
+}
+
+func (s *productServiceImpl) UpdateProduct(ctx context.Context, obs *observability.Observability, product Product) (Product, error) {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ProductService.UpdateProduct",
+		observability.String("product.id", product.ID),
+	)
+	defer span.End()
+
+	if err := validateProduct(product); err != nil {
+		return Product{}, err
+	}
+	updated, err := s.repo.UpdateProduct(ctx, obs, product)
+	if err != nil {
+		if !errors.Is(err, ErrProductNotFound) {
+			obs.ErrorHandler.Record(err, "Error updating product")
+		}
+		return Product{}, err
+	}
+
+	obs.Log.Info("Product updated", "product", updated)
+	return updated, nil
+}
+
+func (s *productServiceImpl) DeleteProduct(ctx context.Context, obs *observability.Observability, productID string) error {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "ProductService.DeleteProduct",
+		observability.String("product.id", productID),
+	)
+	defer span.End()
+
+	if err := s.repo.DeleteProduct(ctx, obs, productID); err != nil {
+		if !errors.Is(err, ErrProductNotFound) {
+			obs.ErrorHandler.Record(err, "Error deleting product")
+		}
+		return err
+	}
+
+	obs.Log.Info("Product deleted", "productID", productID)
+	return nil
+}
+
 func NewProductService(repo ProductRepository) ProductService {
 	return &productServiceImpl{repo: repo}
 }
@@ -0,0 +1,176 @@
+// Package ratelimit limits the request rate of each client of a service built
+// on the observability library, with a token bucket per client. A client is
+// identified by its IP address or by an API key header. Requests over the
+// limit are answered with 429 Too Many Requests and a Retry-After header,
+// counted in the ratelimit.rejected counter and logged, and the request span
+// gets ratelimit.exceeded=true.
+//
+// The limiter is created from the service's Factory, which provides its
+// metrics and logger, and mounted as HTTP middleware. Mount it inside the
+// middleware that starts the request span, so the span can record the
+// outcome. Like wsobs, it reaches the span through OpenTelemetry, so the span
+// attributes are only set with the OTLP APM type; the counter and the logs do
+// not depend on it.
+package ratelimit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// KeyFunc identifies the client of a request. It returns the key whose bucket
+// the request draws from and the kind of key, "ip" or "api_key", which is
+// recorded instead of the key itself on metrics.
+type KeyFunc func(r *http.Request) (key, kind string)
+
+// ByIP identifies clients by the IP address the request came from. Behind a
+// proxy, every client shares the proxy's address.
+func ByIP(r *http.Request) (key, kind string) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return host, "ip"
+}
+
+// ByHeader identifies clients by the value of the header name, such as an
+// API key, and clients that do not send it by IP address. The value is
+// replaced by a short hash, so the key never shows up on spans or in logs.
+func ByHeader(name string) KeyFunc {
+	return func(r *http.Request) (key, kind string) {
+		value := r.Header.Get(name)
+		if value == "" {
+			return ByIP(r)
+		}
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:6]), "api_key"
+	}
+}
+
+// Config sets the limit of each client.
+type Config struct {
+	// Rate is the number of requests per second a client may sustain.
+	Rate float64
+	// Burst is the number of requests a client may send at once after a
+	// quiet period; it is at least 1.
+	Burst int
+	// Key identifies the client of a request; ByIP if nil.
+	Key KeyFunc
+}
+
+// bucket is the token bucket of one client.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter limits the request rate of each client.
+type Limiter struct {
+	cfg      Config
+	obs      *observability.Observability
+	rejected metric.Float64Counter
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New creates a Limiter with the settings of cfg, whose counter and logs go
+// through factory.
+func New(factory *observability.Factory, cfg Config) (*Limiter, error) {
+	if cfg.Rate <= 0 || math.IsInf(cfg.Rate, 0) || math.IsNaN(cfg.Rate) {
+		return nil, errors.New("ratelimit: rate must be a positive number")
+	}
+	cfg.Burst = max(cfg.Burst, 1)
+	if cfg.Key == nil {
+		cfg.Key = ByIP
+	}
+	obs := factory.NewBackgroundObservability(context.Background())
+	rejected, err := obs.Metrics.Counter("ratelimit.rejected",
+		metric.WithDescription("Requests rejected because their client exceeded its rate limit"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Limiter{cfg: cfg, obs: obs, rejected: rejected, buckets: make(map[string]*bucket)}, nil
+}
+
+// Middleware lets requests through to next while their client is within its
+// limit, and answers the others with 429.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, kind := l.cfg.Key(r)
+		ok, retryAfter := l.take(key, time.Now())
+
+		span := trace.SpanFromContext(r.Context())
+		span.SetAttributes(
+			attribute.String("ratelimit.key_type", kind),
+			attribute.Bool("ratelimit.exceeded", !ok),
+		)
+		if ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		seconds := int64(math.Ceil(retryAfter.Seconds()))
+		span.SetAttributes(attribute.Int64("ratelimit.retry_after_s", seconds))
+		l.rejected.Add(r.Context(), 1, metric.WithAttributes(attribute.String("ratelimit.key_type", kind)))
+		// Over-limit clients are expected, so they are logged, not recorded
+		// as errors.
+		observability.ObsFromCtx(r.Context()).Log.Info("Request rate limited",
+			"ratelimit.key_type", kind, "ratelimit.key", key, "retryAfterSeconds", seconds)
+
+		w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+		http.Error(w, "Rate limit exceeded", http.StatusTooManyRequests)
+	})
+}
+
+// take takes a token from the bucket of key. If there is none, it reports how
+// long until there will be.
+func (l *Limiter) take(key string, now time.Time) (ok bool, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.sweep(now)
+
+	b := l.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: float64(l.cfg.Burst), last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = min(float64(l.cfg.Burst), b.tokens+now.Sub(b.last).Seconds()*l.cfg.Rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / l.cfg.Rate * float64(time.Second))
+}
+
+// sweep forgets, at most once a minute, the buckets that have refilled, which
+// are no different from new ones. l.mu must be held.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	full := time.Duration(float64(l.cfg.Burst) / l.cfg.Rate * float64(time.Second))
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, key)
+		}
+	}
+}
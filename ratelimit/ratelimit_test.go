@@ -0,0 +1,120 @@
+package ratelimit
+
+import (
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(rate float64, burst int) *Limiter {
+	return &Limiter{cfg: Config{Rate: rate, Burst: burst}, buckets: make(map[string]*bucket)}
+}
+
+func TestLimiterTake(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	type take struct {
+		after     time.Duration // since start
+		wantOK    bool
+		wantRetry time.Duration
+	}
+	tests := []struct {
+		name  string
+		rate  float64
+		burst int
+		takes []take
+	}{
+		{
+			name: "burst then reject", rate: 2, burst: 3,
+			takes: []take{{0, true, 0}, {0, true, 0}, {0, true, 0}, {0, false, 500 * time.Millisecond}},
+		},
+		{
+			name: "refills at rate", rate: 2, burst: 1,
+			takes: []take{{0, true, 0}, {250 * time.Millisecond, false, 250 * time.Millisecond}, {500 * time.Millisecond, true, 0}},
+		},
+		{
+			name: "refill is capped at burst", rate: 10, burst: 2,
+			takes: []take{{0, true, 0}, {0, true, 0}, {time.Hour, true, 0}, {time.Hour, true, 0}, {time.Hour, false, 100 * time.Millisecond}},
+		},
+		{
+			name: "rejected takes do not use tokens", rate: 1, burst: 1,
+			takes: []take{{0, true, 0}, {0, false, time.Second}, {0, false, time.Second}, {time.Second, true, 0}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newTestLimiter(tt.rate, tt.burst)
+			for i, tk := range tt.takes {
+				ok, retry := l.take("client", start.Add(tk.after))
+				if ok != tk.wantOK {
+					t.Fatalf("take %d ok = %v, want %v", i, ok, tk.wantOK)
+				}
+				if diff := retry - tk.wantRetry; diff < -time.Millisecond || diff > time.Millisecond {
+					t.Errorf("take %d retryAfter = %v, want %v", i, retry, tk.wantRetry)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiterKeysHaveTheirOwnBucket(t *testing.T) {
+	l := newTestLimiter(1, 1)
+	now := time.Now()
+	if ok, _ := l.take("a", now); !ok {
+		t.Fatal("first take of a was rejected")
+	}
+	if ok, _ := l.take("b", now); !ok {
+		t.Error("first take of b was rejected after a used its token")
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	l := newTestLimiter(1, 2)
+	start := time.Now()
+	l.take("idle", start)
+	// A minute later, the bucket of idle has long refilled.
+	l.take("busy", start.Add(time.Minute))
+	if _, ok := l.buckets["idle"]; ok {
+		t.Error("the bucket of an idle client was not swept")
+	}
+	if _, ok := l.buckets["busy"]; !ok {
+		t.Error("the bucket of a busy client was swept")
+	}
+}
+
+func TestNewRejectsInvalidRates(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.Inf(1), math.NaN()} {
+		if _, err := New(nil, Config{Rate: rate}); err == nil {
+			t.Errorf("New with rate %v succeeded", rate)
+		}
+	}
+}
+
+func TestKeyFuncs(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      KeyFunc
+		remote   string
+		header   string
+		wantKey  string
+		wantKind string
+	}{
+		{"ip", ByIP, "10.0.0.1:1234", "", "10.0.0.1", "ip"},
+		{"ip without port", ByIP, "10.0.0.1", "", "10.0.0.1", "ip"},
+		{"header", ByHeader("X-API-Key"), "10.0.0.1:1234", "secret", "2bb80d537b1d", "api_key"},
+		{"header missing", ByHeader("X-API-Key"), "10.0.0.1:1234", "", "10.0.0.1", "ip"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.remote
+			if tt.header != "" {
+				r.Header.Set("X-API-Key", tt.header)
+			}
+			key, kind := tt.key(r)
+			if key != tt.wantKey || kind != tt.wantKind {
+				t.Errorf("key = %q, %q, want %q, %q", key, kind, tt.wantKey, tt.wantKind)
+			}
+		})
+	}
+}
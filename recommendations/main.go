@@ -0,0 +1,9 @@
+package main
+
+import "servicekit"
+
+var DefaultPort = "8096"
+
+func main() {
+	servicekit.Run("recommendations", registerRoutes, servicekit.WithDefaultPort(DefaultPort))
+}
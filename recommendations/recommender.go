@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+
+	"clients"
+	"clients/productclient"
+)
+
+// ErrProductsUnavailable is returned when none of the related products could
+// be looked up.
+var ErrProductsUnavailable = errors.New("product service unavailable")
+
+// Product is a product as the product service returns it.
+type Product struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Price int64  `json:"price"` // in cents
+}
+
+// cachedProduct is a product lookup kept by the Recommender. Lookups of IDs
+// without a product are kept too, with found unset, so they are not asked for
+// again on every request.
+type cachedProduct struct {
+	product Product
+	found   bool
+	expires time.Time
+}
+
+// Recommender recommends the products related to a product. The related IDs
+// are made up from the product ID, standing in for a model; their details
+// come from the product service's bulk endpoint, GET /products?ids=..., at
+// most batchSize IDs per call, and are cached for cacheTTL.
+type Recommender struct {
+	products    *productclient.Client
+	catalogSize int
+	batchSize   int
+	cacheTTL    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cachedProduct
+}
+
+func NewRecommender(products *productclient.Client, catalogSize, batchSize int, cacheTTL time.Duration) *Recommender {
+	return &Recommender{
+		products:    products,
+		catalogSize: catalogSize,
+		batchSize:   batchSize,
+		cacheTTL:    cacheTTL,
+		cache:       map[string]cachedProduct{},
+	}
+}
+
+// relatedIDs returns n distinct product IDs related to productID, always the
+// same ones for the same product.
+func (r *Recommender) relatedIDs(productID string, n int) []string {
+	h := fnv.New64a()
+	h.Write([]byte(productID))
+	rng := rand.New(rand.NewPCG(h.Sum64(), 0))
+	n = min(n, r.catalogSize-1)
+	ids := make([]string, 0, n)
+	seen := map[string]bool{productID: true}
+	for len(ids) < n {
+		id := strconv.Itoa(1 + rng.IntN(r.catalogSize))
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Recommend returns up to n products related to productID, in a
+// Recommender.Recommend span. The IDs missing from the cache are looked up in
+// batches, all at once, each in its own ProductService.GetProducts span, so
+// the trace shows one client span per batch rather than one per product. The
+// span records the batch count and how well the cache did: cache.hits,
+// cache.misses and cache.hit_ratio. A failed batch only costs the
+// recommendations of its IDs.
+func (r *Recommender) Recommend(ctx context.Context, productID string, n int) ([]Product, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "Recommender.Recommend",
+		observability.String("product.id", productID),
+		observability.Int("recommendations.requested", n),
+	)
+	defer span.End()
+
+	ids := r.relatedIDs(productID, n)
+	found := make(map[string]Product, len(ids))
+	var misses []string
+	now := time.Now()
+	r.mu.Lock()
+	for _, id := range ids {
+		if c, ok := r.cache[id]; ok && now.Before(c.expires) {
+			if c.found {
+				found[id] = c.product
+			}
+			continue
+		}
+		misses = append(misses, id)
+	}
+	r.mu.Unlock()
+
+	var batches [][]string
+	for ids := misses; len(ids) > 0; {
+		size := min(r.batchSize, len(ids))
+		batches = append(batches, ids[:size])
+		ids = ids[size:]
+	}
+	results := make([][]Product, len(batches))
+	errs := make([]error, len(batches))
+	var wg sync.WaitGroup
+	for i, batch := range batches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], errs[i] = r.getProducts(ctx, i, batch)
+		}()
+	}
+	wg.Wait()
+
+	failed := 0
+	expires := time.Now().Add(r.cacheTTL)
+	r.mu.Lock()
+	for i, batch := range batches {
+		if errs[i] != nil {
+			failed++
+			continue
+		}
+		for _, id := range batch {
+			r.cache[id] = cachedProduct{expires: expires}
+		}
+		for _, p := range results[i] {
+			r.cache[p.ID] = cachedProduct{product: p, found: true, expires: expires}
+			found[p.ID] = p
+		}
+	}
+	r.mu.Unlock()
+
+	hits := len(ids) - len(misses)
+	hitRatio := 1.0
+	if len(ids) > 0 {
+		hitRatio = float64(hits) / float64(len(ids))
+	}
+	span.SetAttributes(
+		observability.Int("cache.hits", hits),
+		observability.Int("cache.misses", len(misses)),
+		attribute.Float64("cache.hit_ratio", hitRatio),
+		observability.Int("batch.count", len(batches)),
+		observability.Int("batch.max_size", r.batchSize),
+		observability.Int("batch.failed", failed),
+	)
+
+	if failed > 0 && failed == len(batches) && len(found) == 0 {
+		err := fmt.Errorf("%w: %w", ErrProductsUnavailable, errors.Join(errs...))
+		obs.ErrorHandler.Record(err, "Failed to look up recommended products")
+		return nil, err
+	}
+	if failed > 0 {
+		obs.Log.Warn("Some recommended products could not be looked up", "failedBatches", failed, "batches", len(batches))
+	}
+
+	products := make([]Product, 0, len(found))
+	for _, id := range ids {
+		if p, ok := found[id]; ok {
+			products = append(products, p)
+		}
+	}
+	span.SetAttributes(observability.Int("recommendations.count", len(products)))
+	return products, nil
+}
+
+// getProducts looks up the products of one batch of IDs with the product
+// service, in a ProductService.GetProducts span recording the batch's index
+// and size.
+func (r *Recommender) getProducts(ctx context.Context, index int, ids []string) ([]Product, error) {
+	ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "ProductService.GetProducts",
+		observability.String("peer.service", "product"),
+		observability.Int("batch.index", index),
+		observability.Int("batch.size", len(ids)),
+	)
+	defer span.End()
+
+	products, err := r.fetchProducts(ctx, obs, span, ids)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(observability.Int("product.count", len(products)))
+	return products, nil
+}
+
+// fetchProducts calls the product service's bulk endpoint and keeps the
+// fields recommendations show.
+func (r *Recommender) fetchProducts(ctx context.Context, obs *observability.Observability, span observability.Span, ids []string) ([]Product, error) {
+	found, err := r.products.GetProducts(ctx, obs, ids)
+	if err != nil {
+		var se *clients.StatusError
+		if errors.As(err, &se) {
+			span.SetAttributes(observability.Int("http.response.status_code", se.StatusCode))
+		}
+		return nil, err
+	}
+	products := make([]Product, len(found))
+	for i, p := range found {
+		products[i] = Product{ID: p.ID, Name: p.Name, Price: p.Price}
+	}
+	return products, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"clients/productclient"
+	"health"
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvCatalogSize     = "RECOMMENDATIONS_CATALOG_SIZE"
+	DefaultCatalogSize = "1000"
+	EnvBatchSize       = "RECOMMENDATIONS_BATCH_SIZE"
+	DefaultBatchSize   = "10"
+	EnvCacheTTL        = "RECOMMENDATIONS_CACHE_TTL"
+	DefaultCacheTTL    = "5m"
+)
+
+var productServiceURL = servicekit.Getenv("PRODUCT_SERVICE_URL", "http://product-service:8086")
+
+const (
+	// maxBatchSize is the product service's limit on the IDs of one
+	// GET /products?ids=... request.
+	maxBatchSize = 100
+
+	// Recommendations per request.
+	defaultRecommendations = 10
+	maxRecommendations     = 50
+)
+
+// registerRoutes sets the recommender up and returns the recommendations API.
+func registerRoutes(s *servicekit.Service) http.Handler {
+	catalogSize, err := strconv.Atoi(servicekit.Getenv(EnvCatalogSize, DefaultCatalogSize))
+	if err != nil || catalogSize < 2 {
+		s.Fatal("Invalid catalog size", "value", servicekit.Getenv(EnvCatalogSize, DefaultCatalogSize))
+	}
+	batchSize, err := strconv.Atoi(servicekit.Getenv(EnvBatchSize, DefaultBatchSize))
+	if err != nil || batchSize < 1 || batchSize > maxBatchSize {
+		s.Fatal("Invalid batch size", "value", servicekit.Getenv(EnvBatchSize, DefaultBatchSize))
+	}
+	cacheTTL, err := time.ParseDuration(servicekit.Getenv(EnvCacheTTL, DefaultCacheTTL))
+	if err != nil || cacheTTL < 0 {
+		s.Fatal("Invalid cache TTL", "value", servicekit.Getenv(EnvCacheTTL, DefaultCacheTTL))
+	}
+
+	// Recommendations cannot be made without the product service.
+	s.Checks.Register("product", health.HTTPChecker(productServiceURL, s.DependencyCheckTTL, s.DependencyCheckTimeout))
+
+	recommender := NewRecommender(productclient.New(productServiceURL), catalogSize, batchSize, cacheTTL)
+	s.Obs.Log.Info("Products looked up in batches", "batchSize", batchSize, "cacheTTL", cacheTTL.String())
+
+	mux := http.NewServeMux()
+	s.Handle(mux, "GET /recommendations", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleRecommendations(r.Context(), w, r, observability.ObsFromCtx(r.Context()), recommender)
+	}))
+
+	return obsmiddleware.WithRoute(mux)
+}
+
+// handleRecommendations answers with the products related to the productId
+// query parameter, limit of them (default 10, at most 50). Related IDs
+// without a product are left out, so there may be fewer.
+func handleRecommendations(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	recommender *Recommender) {
+	query := r.URL.Query()
+	productID := query.Get("productId")
+	if productID == "" {
+		obs.ErrorHandler.HTTP(w, "Missing productId", http.StatusBadRequest)
+		return
+	}
+	limit := defaultRecommendations
+	if value := query.Get("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxRecommendations {
+			obs.ErrorHandler.HTTP(w, fmt.Sprintf("limit must be between 1 and %d", maxRecommendations), http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	products, err := recommender.Recommend(ctx, productID, limit)
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, "Recommendations unavailable", http.StatusBadGateway)
+		return
+	}
+
+	obs.Log.Info("Recommendations served", "productID", productID, "count", len(products))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		ProductID       string    `json:"productId"`
+		Recommendations []Product `json:"recommendations"`
+	}{productID, products})
+}
@@ -0,0 +1,127 @@
+// Package redisobs instruments go-redis clients with the observability
+// library: every command gets a client span, keys are reduced to their prefix
+// so values and identifiers never reach the telemetry backend, and read
+// commands are counted as cache hits or misses.
+package redisobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// readCommands are the commands whose redis.Nil result means a cache miss.
+var readCommands = map[string]bool{
+	"get":    true,
+	"getex":  true,
+	"getdel": true,
+	"hget":   true,
+}
+
+// Hook is a redis.Hook recording spans and hit/miss metrics.
+type Hook struct {
+	hits   metric.Float64Counter
+	misses metric.Float64Counter
+}
+
+var _ redis.Hook = (*Hook)(nil)
+
+// NewHook creates a hook. obs is used to create the hit/miss counters; spans
+// are started from the Observability instance in each command's context.
+func NewHook(obs *observability.Observability) (*Hook, error) {
+	hits, err := obs.Metrics.Counter("redis.cache.hits", metric.WithDescription("Redis read commands that found their key"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create hits counter: %w", err)
+	}
+	misses, err := obs.Metrics.Counter("redis.cache.misses", metric.WithDescription("Redis read commands that did not find their key"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create misses counter: %w", err)
+	}
+	return &Hook{hits: hits, misses: misses}, nil
+}
+
+// DialHook passes dials through unchanged.
+func (h *Hook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+// ProcessHook wraps a single command in a client span.
+func (h *Hook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		name := cmd.Name()
+		prefix := KeyPrefix(cmd)
+		ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "redis."+name,
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", strings.ToUpper(name)),
+			attribute.String("db.redis.key_prefix", prefix),
+		)
+		defer span.End()
+
+		err := next(ctx, cmd)
+
+		// A read that failed neither hit nor missed.
+		if readCommands[name] && (err == nil || errors.Is(err, redis.Nil)) {
+			hit := err == nil
+			span.SetAttributes(attribute.Bool("cache.hit", hit))
+			attrs := metric.WithAttributes(attribute.String("db.redis.key_prefix", prefix))
+			if hit {
+				h.hits.Add(ctx, 1, attrs)
+			} else {
+				h.misses.Add(ctx, 1, attrs)
+			}
+		}
+		if err != nil && !errors.Is(err, redis.Nil) {
+			obs.ErrorHandler.Record(err, "Redis command failed")
+		}
+		return err
+	}
+}
+
+// ProcessPipelineHook wraps a pipeline in a single client span.
+func (h *Hook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		names := make([]string, len(cmds))
+		for i, cmd := range cmds {
+			names[i] = strings.ToUpper(cmd.Name())
+		}
+		ctx, obs, span := observability.StartSpanFromCtxWith(ctx, "redis.pipeline",
+			attribute.String("db.system", "redis"),
+			attribute.String("db.operation", strings.Join(names, " ")),
+			attribute.Int("db.redis.pipeline_length", len(cmds)),
+		)
+		defer span.End()
+
+		err := next(ctx, cmds)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			obs.ErrorHandler.Record(err, "Redis pipeline failed")
+		}
+		return err
+	}
+}
+
+// KeyPrefix returns the part of the command's key before the first ':'.
+// Values and the identifying part of keys are never recorded.
+func KeyPrefix(cmd redis.Cmder) string {
+	args := cmd.Args()
+	if len(args) < 2 {
+		return ""
+	}
+	key, ok := args[1].(string)
+	if !ok {
+		return ""
+	}
+	prefix, _, found := strings.Cut(key, ":")
+	if !found {
+		return "(none)"
+	}
+	return prefix
+}
@@ -0,0 +1,95 @@
+package redisobs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/app-obs/go/observability"
+	"github.com/redis/go-redis/v9"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+var testFactory = observability.NewFactory(
+	observability.WithServiceName("redisobs-test"),
+	observability.WithApmType("none"),
+	observability.WithMetricsType("none"),
+)
+
+func TestMain(m *testing.M) {
+	if _, err := testFactory.Setup(context.Background()); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestKeyPrefix(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		cmd  redis.Cmder
+		want string
+	}{
+		{"prefixed", redis.NewStringCmd(ctx, "get", "product:42"), "product"},
+		{"nested prefix", redis.NewStringCmd(ctx, "get", "session:user:42"), "session"},
+		{"no prefix", redis.NewStringCmd(ctx, "get", "config"), "(none)"},
+		{"no key", redis.NewStatusCmd(ctx, "ping"), ""},
+		{"key not a string", redis.NewStringCmd(ctx, "get", 42), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KeyPrefix(tt.cmd); got != tt.want {
+				t.Errorf("KeyPrefix(%v) = %q, want %q", tt.cmd.Args(), got, tt.want)
+			}
+		})
+	}
+}
+
+func TestProcessHook(t *testing.T) {
+	errDown := errors.New("connection refused")
+	tests := []struct {
+		name                 string
+		cmd                  string
+		err                  error
+		wantHits, wantMisses float64
+	}{
+		{"hit", "get", nil, 1, 0},
+		{"miss", "get", redis.Nil, 0, 1},
+		{"hash miss", "hget", redis.Nil, 0, 1},
+		{"write not counted", "set", nil, 0, 0},
+		{"failed read not counted", "get", errDown, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := sdkmetric.NewManualReader()
+			meter := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)).Meter("test")
+			hits, _ := meter.Float64Counter("redis.cache.hits")
+			misses, _ := meter.Float64Counter("redis.cache.misses")
+			h := &Hook{hits: hits, misses: misses}
+			ctx := context.Background()
+			ctx = testFactory.NewBackgroundObservability(ctx).Context()
+
+			process := h.ProcessHook(func(context.Context, redis.Cmder) error { return tt.err })
+			if err := process(ctx, redis.NewStringCmd(ctx, tt.cmd, "product:42")); !errors.Is(err, tt.err) {
+				t.Fatalf("ProcessHook = %v, want %v", err, tt.err)
+			}
+			var rm metricdata.ResourceMetrics
+			if err := reader.Collect(ctx, &rm); err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]float64{}
+			for _, sm := range rm.ScopeMetrics {
+				for _, m := range sm.Metrics {
+					for _, dp := range m.Data.(metricdata.Sum[float64]).DataPoints {
+						got[m.Name] += dp.Value
+					}
+				}
+			}
+			if got["redis.cache.hits"] != tt.wantHits || got["redis.cache.misses"] != tt.wantMisses {
+				t.Errorf("hits, misses = %v, %v, want %v, %v", got["redis.cache.hits"], got["redis.cache.misses"], tt.wantHits, tt.wantMisses)
+			}
+		})
+	}
+}
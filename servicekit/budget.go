@@ -0,0 +1,167 @@
+package servicekit
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var EnvInstrumentationReport = "INSTRUMENTATION_REPORT_INTERVAL"
+
+// defaultDevReportInterval is used in development when no interval is set.
+const defaultDevReportInterval = time.Minute
+
+// telemetryCost is the telemetry produced while handling requests.
+type telemetryCost struct {
+	requests   int64
+	spans      int64
+	events     int64
+	attributes int64
+	eventBytes int64
+}
+
+// traceCost accumulates the cost of one request until its root span ends.
+type traceCost struct {
+	route  string
+	rootID trace.SpanID
+	telemetryCost
+}
+
+// budgetProcessor is a span processor that estimates the telemetry overhead
+// of each route: spans created, events attached (including those produced by
+// logs), attributes converted and bytes carried by events. Child spans are
+// attributed to the route of their local root span.
+type budgetProcessor struct {
+	mu     sync.Mutex
+	traces map[trace.TraceID]*traceCost
+	routes map[string]*telemetryCost
+}
+
+var _ sdktrace.SpanProcessor = (*budgetProcessor)(nil)
+
+func newBudgetProcessor() *budgetProcessor {
+	return &budgetProcessor{
+		traces: make(map[trace.TraceID]*traceCost),
+		routes: make(map[string]*telemetryCost),
+	}
+}
+
+func (p *budgetProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	if parent := s.Parent(); parent.IsValid() && !parent.IsRemote() {
+		return
+	}
+	sc := s.SpanContext()
+	p.mu.Lock()
+	p.traces[sc.TraceID()] = &traceCost{route: s.Name(), rootID: sc.SpanID()}
+	p.mu.Unlock()
+}
+
+func (p *budgetProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	sc := s.SpanContext()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tc, ok := p.traces[sc.TraceID()]
+	if !ok {
+		return
+	}
+	tc.spans++
+	tc.attributes += int64(len(s.Attributes()))
+	for _, e := range s.Events() {
+		tc.events++
+		tc.attributes += int64(len(e.Attributes))
+		tc.eventBytes += int64(len(e.Name))
+		for _, kv := range e.Attributes {
+			tc.eventBytes += int64(len(kv.Key) + len(kv.Value.Emit()))
+		}
+	}
+
+	if sc.SpanID() != tc.rootID {
+		return
+	}
+	delete(p.traces, sc.TraceID())
+	rc, ok := p.routes[tc.route]
+	if !ok {
+		rc = &telemetryCost{}
+		p.routes[tc.route] = rc
+	}
+	rc.requests++
+	rc.spans += tc.spans
+	rc.events += tc.events
+	rc.attributes += tc.attributes
+	rc.eventBytes += tc.eventBytes
+}
+
+func (p *budgetProcessor) Shutdown(context.Context) error   { return nil }
+func (p *budgetProcessor) ForceFlush(context.Context) error { return nil }
+
+// report logs the average cost per request of every route seen since the
+// previous report, then starts over.
+func (p *budgetProcessor) report(obs *observability.Observability) {
+	p.mu.Lock()
+	routes := p.routes
+	p.routes = make(map[string]*telemetryCost)
+	p.mu.Unlock()
+
+	names := make([]string, 0, len(routes))
+	for name := range routes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		c := routes[name]
+		n := float64(c.requests)
+		obs.Log.Info("Instrumentation budget",
+			"route", name,
+			"requests", c.requests,
+			"spansPerRequest", float64(c.spans)/n,
+			"eventsPerRequest", float64(c.events)/n,
+			"attributesPerRequest", float64(c.attributes)/n,
+			"eventBytesPerRequest", float64(c.eventBytes)/n,
+		)
+	}
+}
+
+// startBudgetReport periodically logs the instrumentation budget of each
+// route. It is on by default in development and can be enabled elsewhere by
+// setting INSTRUMENTATION_REPORT_INTERVAL. It needs the OpenTelemetry SDK, so
+// it only works with the OTLP APM type.
+func startBudgetReport(obs *observability.Observability) {
+	interval := time.Duration(0)
+	if val := Getenv(EnvInstrumentationReport, ""); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil {
+			obs.Log.Warn("Invalid instrumentation report interval, report disabled", "value", val, "error", err)
+			return
+		}
+		interval = d
+	} else if Getenv("OBS_ENVIRONMENT", "development") == "development" {
+		interval = defaultDevReportInterval
+	}
+	if interval <= 0 {
+		return
+	}
+
+	tp, ok := sdkTracerProvider()
+	if !ok {
+		obs.Log.Debug("Instrumentation budget report needs the OTLP tracer, report disabled")
+		return
+	}
+	p := newBudgetProcessor()
+	tp.RegisterSpanProcessor(p)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			p.report(obs)
+		}
+	}()
+	obs.Log.Info("Instrumentation budget report enabled", "interval", interval.String())
+}
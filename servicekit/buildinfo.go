@@ -0,0 +1,87 @@
+package servicekit
+
+import (
+	"runtime"
+	"runtime/debug"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// version is the service version, set at build time with
+// -ldflags "-X servicekit.version=...". When unset, the VCS revision recorded
+// by the Go toolchain is used instead.
+var version string
+
+// buildInfo describes the running binary.
+type buildInfo struct {
+	version     string
+	revision    string
+	revisionAt  string
+	modified    bool
+	goVersion   string
+	hasRevision bool
+}
+
+// readBuildInfo collects the build information embedded in the binary.
+func readBuildInfo() buildInfo {
+	info := buildInfo{version: version, goVersion: runtime.Version()}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		if info.version == "" {
+			info.version = "unknown"
+		}
+		return info
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.revision = s.Value
+			info.hasRevision = true
+		case "vcs.time":
+			info.revisionAt = s.Value
+		case "vcs.modified":
+			info.modified = s.Value == "true"
+		}
+	}
+	if info.version == "" {
+		switch {
+		case bi.Main.Version != "" && bi.Main.Version != "(devel)":
+			info.version = bi.Main.Version
+		case info.hasRevision:
+			info.version = info.revision[:min(12, len(info.revision))]
+			if info.modified {
+				info.version += "-dirty"
+			}
+		default:
+			info.version = "unknown"
+		}
+	}
+	return info
+}
+
+// attributes returns the build information as resource attributes.
+func (b buildInfo) attributes() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		attribute.String("service.version", b.version),
+		attribute.String("process.runtime.version", b.goVersion),
+	}
+	if b.hasRevision {
+		attrs = append(attrs,
+			attribute.String("vcs.revision", b.revision),
+			attribute.Bool("vcs.modified", b.modified),
+		)
+	}
+	return attrs
+}
+
+// logBuildInfo logs what build of the service is starting.
+func logBuildInfo(obs *observability.Observability, b buildInfo) {
+	obs.Log.Info("Build info",
+		"version", b.version,
+		"revision", b.revision,
+		"revisionTime", b.revisionAt,
+		"modified", b.modified,
+		"goVersion", b.goVersion,
+	)
+}
@@ -0,0 +1,139 @@
+package servicekit
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+)
+
+var EnvChaos = "OBS_CHAOS"
+
+// chaosFault is a fault injected into a share of requests: a delay before
+// the request is handled, or an error response instead of handling it.
+type chaosFault struct {
+	kind    string // "latency" or "error"
+	delay   time.Duration
+	status  int
+	percent float64
+}
+
+// chaos injects the faults configured in OBS_CHAOS, so incident debugging
+// can be rehearsed on the example stack. Every request it affects is marked
+// with chaos.injected=true on its span, which tells injected faults apart
+// from real ones.
+type chaos struct {
+	faults []chaosFault
+}
+
+// newChaos creates the faults described by spec, as read from OBS_CHAOS:
+// comma-separated faults, each "latency:<duration>@<percent>%" or
+// "error:<status>@<percent>%", e.g. "latency:200ms@10%,error:500@2%". An
+// empty spec injects nothing.
+func newChaos(spec string) (*chaos, error) {
+	faults, err := parseChaos(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &chaos{faults: faults}, nil
+}
+
+// Enabled reports whether any fault is configured.
+func (c *chaos) Enabled() bool {
+	return len(c.faults) > 0
+}
+
+// Middleware rolls every fault for each request passing through next. The
+// delays of latency faults add up; an error fault responds with its status
+// without calling next. It must run inside obsmiddleware.WithObservability,
+// whose span it marks.
+func (c *chaos) Middleware(next http.Handler) http.Handler {
+	if !c.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var delay time.Duration
+		status := 0
+		for _, f := range c.faults {
+			if rand.Float64()*100 >= f.percent {
+				continue
+			}
+			switch f.kind {
+			case "latency":
+				delay += f.delay
+			case "error":
+				if status == 0 {
+					status = f.status
+				}
+			}
+		}
+		if delay == 0 && status == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		span, ok := obsmiddleware.SpanFromContext(r.Context())
+		if ok {
+			span.SetAttributes(observability.Bool("chaos.injected", true))
+		}
+		if delay > 0 {
+			if ok {
+				span.SetAttributes(observability.Int("chaos.latency_ms", int(delay.Milliseconds())))
+			}
+			select {
+			case <-time.After(delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		if status != 0 {
+			if ok {
+				span.SetAttributes(observability.Int("chaos.error_status", status))
+			}
+			http.Error(w, http.StatusText(status), status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func parseChaos(spec string) ([]chaosFault, error) {
+	var faults []chaosFault
+	for _, def := range strings.Split(spec, ",") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		kind, rest, ok := strings.Cut(def, ":")
+		value, percentText, ok2 := strings.Cut(rest, "@")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("chaos fault %q: expected kind:value@percent%%", def)
+		}
+		percent, err := strconv.ParseFloat(strings.TrimSuffix(percentText, "%"), 64)
+		if err != nil || percent < 0 || percent > 100 {
+			return nil, fmt.Errorf("chaos fault %q: percent must be between 0 and 100", def)
+		}
+
+		f := chaosFault{kind: kind, percent: percent}
+		switch kind {
+		case "latency":
+			if f.delay, err = time.ParseDuration(value); err != nil || f.delay <= 0 {
+				return nil, fmt.Errorf("chaos fault %q: latency must be a positive duration", def)
+			}
+		case "error":
+			if f.status, err = strconv.Atoi(value); err != nil || f.status < 400 || f.status > 599 {
+				return nil, fmt.Errorf("chaos fault %q: error must be a 4xx or 5xx status", def)
+			}
+		default:
+			return nil, fmt.Errorf("chaos fault %q: unknown kind %q, want latency or error", def, kind)
+		}
+		faults = append(faults, f)
+	}
+	return faults, nil
+}
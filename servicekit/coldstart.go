@@ -0,0 +1,89 @@
+package servicekit
+
+import (
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"obsmiddleware"
+)
+
+var (
+	EnvColdStartRequests     = "COLD_START_REQUESTS"
+	DefaultColdStartRequests = "10"
+)
+
+// processStart approximates the moment the process started. Package-level
+// variables are initialized before main runs, so this is as early as we can get.
+var processStart = time.Now()
+
+// coldStartTracker tags the first N requests served after process start with
+// app.cold_start=true, so cold-start latency can be told apart from
+// steady-state latency in both traces and metrics.
+type coldStartTracker struct {
+	remaining atomic.Int64
+	duration  metric.Float64Histogram
+}
+
+// newColdStartTracker creates a tracker for the first n requests.
+func newColdStartTracker(meter metric.Meter, n int64) (*coldStartTracker, error) {
+	duration, err := meter.Float64Histogram("app.request.duration",
+		metric.WithDescription("Duration of handled requests, split by cold start"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	t := &coldStartTracker{duration: duration}
+	t.remaining.Store(n)
+	return t, nil
+}
+
+// Middleware tags the request span as cold or warm and records the request
+// duration. It must run inside obsmiddleware.WithObservability.
+func (t *coldStartTracker) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		cold := t.remaining.Add(-1) >= 0
+		if span, ok := obsmiddleware.SpanFromContext(r.Context()); ok {
+			span.SetAttributes(observability.Bool("app.cold_start", cold))
+		}
+
+		next.ServeHTTP(w, r)
+
+		t.duration.Record(r.Context(), time.Since(start).Seconds(),
+			metric.WithAttributes(attribute.Bool("app.cold_start", cold)))
+	})
+}
+
+// recordStartupDuration emits the time from process start until the service is
+// ready to accept requests, both as a metric and as a log line.
+func recordStartupDuration(obs *observability.Observability, meter metric.Meter) {
+	startup := time.Since(processStart)
+
+	gauge, err := meter.Float64Gauge("app.startup.duration",
+		metric.WithDescription("Time from process start until the service is ready"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Failed to create startup duration gauge")
+	} else {
+		gauge.Record(obs.Context(), startup.Seconds())
+	}
+
+	obs.Log.Info("Startup complete", "startupDuration", startup.String())
+}
+
+// coldStartRequests returns the number of requests to tag as cold starts.
+func coldStartRequests() int64 {
+	n, err := strconv.ParseInt(Getenv(EnvColdStartRequests, DefaultColdStartRequests), 10, 64)
+	if err != nil || n < 0 {
+		n, _ = strconv.ParseInt(DefaultColdStartRequests, 10, 64)
+	}
+	return n
+}
@@ -0,0 +1,159 @@
+package servicekit
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+
+	"health"
+)
+
+var EnvCollectorReadiness = "OBS_COLLECTOR_READINESS"
+
+// collectorCheckTimeout bounds the startup connectivity check.
+const collectorCheckTimeout = 5 * time.Second
+
+// collectorClient sends the connectivity check requests.
+var collectorClient = &http.Client{Timeout: collectorCheckTimeout}
+
+// collectorDiagnostics describes how far a request to the collector got.
+// Step names the first step that failed, empty when all succeeded.
+type collectorDiagnostics struct {
+	URL        string
+	Addresses  []string
+	TLS        bool
+	StatusCode int
+	Duration   time.Duration
+	Step       string
+	Err        error
+}
+
+// logArgs returns the diagnostics as log arguments.
+func (d collectorDiagnostics) logArgs() []any {
+	args := []any{
+		"url", d.URL,
+		"addresses", d.Addresses,
+		"tls", d.TLS,
+		"statusCode", d.StatusCode,
+		"duration", d.Duration.String(),
+	}
+	if d.Err != nil {
+		args = append(args, "failedStep", d.Step, "error", d.Err)
+	}
+	return args
+}
+
+// checkCollector checks that the OTLP collector at apmURL accepts traces.
+// It resolves the host, connects, completes the TLS handshake for https
+// URLs, and posts an empty export request to the traces path, which a
+// collector accepts without recording anything. Each step is reported, so a
+// wrong host, port, scheme or path can be told apart.
+func checkCollector(ctx context.Context, apmURL string) collectorDiagnostics {
+	start := time.Now()
+	d := collectorDiagnostics{URL: apmURL}
+	fail := func(step string, err error) collectorDiagnostics {
+		d.Step, d.Err, d.Duration = step, err, time.Since(start)
+		return d
+	}
+
+	u, err := url.Parse(apmURL)
+	if err != nil || u.Host == "" {
+		return fail("url", fmt.Errorf("invalid collector URL %q", apmURL))
+	}
+	d.TLS = u.Scheme == "https"
+	// The exporter posts to the URL's path, or to the OTLP default.
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+
+	d.Addresses, err = net.DefaultResolver.LookupHost(ctx, u.Hostname())
+	if err != nil {
+		return fail("dns", err)
+	}
+
+	// The transport may dial several addresses concurrently.
+	var (
+		mu                 sync.Mutex
+		connectErr, tlsErr error
+	)
+	trace := &httptrace.ClientTrace{
+		ConnectDone: func(_, _ string, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				connectErr = err
+			}
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				tlsErr = err
+			}
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), http.MethodPost, u.String(), bytes.NewReader(nil))
+	if err != nil {
+		return fail("url", err)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := collectorClient.Do(req)
+	if err != nil {
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case tlsErr != nil:
+			return fail("tls", tlsErr)
+		case connectErr != nil:
+			return fail("connect", connectErr)
+		}
+		return fail("http", err)
+	}
+	resp.Body.Close()
+
+	d.StatusCode = resp.StatusCode
+	if resp.StatusCode/100 != 2 {
+		return fail("http", fmt.Errorf("collector answered %s to POST %s", resp.Status, u.Path))
+	}
+	d.Duration = time.Since(start)
+	return d
+}
+
+// preflightCollector checks the collector once at startup and logs the
+// outcome, so a misconfigured OBS_APM_URL shows up in the first lines of
+// the log instead of as traces that never arrive. With
+// OBS_COLLECTOR_READINESS=true, the check is also registered as a critical
+// readiness check. Only the OTLP APM type is checked.
+func preflightCollector(obs *observability.Observability, checks *health.Registry) {
+	apmURL := Getenv("OBS_APM_URL", "")
+	if apmType != "otlp" || apmURL == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(obs.Context(), collectorCheckTimeout)
+	defer cancel()
+	if d := checkCollector(ctx, apmURL); d.Err != nil {
+		obs.Log.Warn("Collector check failed, traces may not reach the APM backend", d.logArgs()...)
+	} else {
+		obs.Log.Info("Collector reachable", d.logArgs()...)
+	}
+
+	if ready, _ := strconv.ParseBool(Getenv(EnvCollectorReadiness, "false")); ready {
+		checks.Register("collector", health.CheckerFunc(func(ctx context.Context) error {
+			if d := checkCollector(ctx, apmURL); d.Err != nil {
+				return fmt.Errorf("%s: %w", d.Step, d.Err)
+			}
+			return nil
+		}))
+	}
+}
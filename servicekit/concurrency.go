@@ -0,0 +1,132 @@
+package servicekit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+
+	"obsmiddleware"
+)
+
+// connTimerKey is a private type to prevent collisions with other packages.
+type connTimerKey struct{}
+
+// connTimer remembers when the current request on a connection started
+// waiting: the accept time for the first request, and the moment the
+// connection left the idle state for keep-alive requests.
+type connTimer struct {
+	waitingSince atomic.Int64 // UnixNano
+	idle         atomic.Bool
+}
+
+// connTimes tracks per-connection timestamps through the http.Server
+// ConnContext and ConnState hooks, so handlers can estimate how long a request
+// queued inside the server before it reached them.
+type connTimes struct {
+	conns sync.Map // net.Conn -> *connTimer
+}
+
+// ConnContext is meant to be set as http.Server.ConnContext.
+func (c *connTimes) ConnContext(ctx context.Context, conn net.Conn) context.Context {
+	t := &connTimer{}
+	t.waitingSince.Store(time.Now().UnixNano())
+	c.conns.Store(conn, t)
+	return context.WithValue(ctx, connTimerKey{}, t)
+}
+
+// ConnState is meant to be set as http.Server.ConnState.
+func (c *connTimes) ConnState(conn net.Conn, state http.ConnState) {
+	v, ok := c.conns.Load(conn)
+	if !ok {
+		return
+	}
+	t := v.(*connTimer)
+
+	switch state {
+	case http.StateIdle:
+		t.idle.Store(true)
+	case http.StateActive:
+		if t.idle.Swap(false) {
+			t.waitingSince.Store(time.Now().UnixNano())
+		}
+	case http.StateHijacked, http.StateClosed:
+		c.conns.Delete(conn)
+	}
+}
+
+// queueTime returns the time elapsed since the request on the connection
+// behind ctx started waiting, or false if it is unknown.
+func queueTime(ctx context.Context) (time.Duration, bool) {
+	t, ok := ctx.Value(connTimerKey{}).(*connTimer)
+	if !ok {
+		return 0, false
+	}
+	return time.Since(time.Unix(0, t.waitingSince.Load())), true
+}
+
+var EnvRouteMetricLabels = "OBS_ROUTE_METRIC_LABELS"
+
+// routeMetricLabels are the labels that the per-route metrics may carry,
+// from the comma-separated OBS_ROUTE_METRIC_LABELS. Dropping one merges the
+// series that differ only in it.
+var routeMetricLabels = obsmiddleware.KeySet(strings.Split(Getenv(EnvRouteMetricLabels, "http.route,http.request.method"), ","))
+
+// routeMetricAttributes returns attrs without the labels missing from
+// routeMetricLabels.
+func routeMetricAttributes(attrs ...attribute.KeyValue) metric.MeasurementOption {
+	allowed := attrs[:0]
+	for _, kv := range attrs {
+		if routeMetricLabels[kv.Key] {
+			allowed = append(allowed, kv)
+		}
+	}
+	return metric.WithAttributes(allowed...)
+}
+
+// inFlightTracker exposes the number of requests currently being handled per
+// route and records the server-side queue time on each request span.
+type inFlightTracker struct {
+	inFlight metric.Int64UpDownCounter
+}
+
+// newInFlightTracker creates the per-route in-flight instruments.
+func newInFlightTracker(meter metric.Meter) (*inFlightTracker, error) {
+	inFlight, err := meter.Int64UpDownCounter("http.server.active_requests",
+		metric.WithDescription("Number of requests currently being handled, per route"),
+		metric.WithUnit("{request}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &inFlightTracker{inFlight: inFlight}, nil
+}
+
+// Middleware counts requests to route as in flight while next handles them,
+// labeled with http.route and http.request.method as far as
+// OBS_ROUTE_METRIC_LABELS allows, and records the queue time on the request
+// span. It must run inside obsmiddleware.WithObservability.
+func (t *inFlightTracker) Middleware(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		routeAttr := routeMetricAttributes(
+			attribute.String("http.route", route),
+			attribute.String("http.request.method", obsmiddleware.SpanMethod(r.Method)),
+		)
+		ctx := r.Context()
+		if wait, ok := queueTime(ctx); ok {
+			if span, ok := obsmiddleware.SpanFromContext(ctx); ok {
+				span.SetAttributes(attribute.Float64("http.server.queue_time_ms", float64(wait.Microseconds())/1000))
+			}
+		}
+
+		t.inFlight.Add(ctx, 1, routeAttr)
+		defer t.inFlight.Add(ctx, -1, routeAttr)
+		next.ServeHTTP(w, r)
+	})
+}
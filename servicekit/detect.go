@@ -0,0 +1,285 @@
+package servicekit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var (
+	EnvResourceDetectors     = "OBS_RESOURCE_DETECTORS"
+	DefaultResourceDetectors = "host,container,k8s"
+)
+
+// detectTimeout bounds resource detection, which may query cloud metadata
+// endpoints that do not exist outside that cloud.
+const detectTimeout = 3 * time.Second
+
+// metadataClient queries cloud metadata endpoints. They are link-local, so
+// anything slower than this means we are not running on that cloud.
+var metadataClient = &http.Client{Timeout: time.Second}
+
+// resourceDetectors maps the names accepted in OBS_RESOURCE_DETECTORS to
+// their detectors.
+var resourceDetectors = map[string]resource.Option{
+	"host":      resource.WithHost(),
+	"container": resource.WithContainer(),
+	"process":   resource.WithProcess(),
+	"os":        resource.WithOS(),
+	"k8s":       resource.WithDetectors(k8sDetector{}),
+	"ec2":       resource.WithDetectors(ec2Detector{}),
+	"ecs":       resource.WithDetectors(ecsDetector{}),
+	"gce":       resource.WithDetectors(gceDetector{}),
+}
+
+// resourceProcessor adds detected resource attributes to every span. The
+// library builds the tracer's resource from the service name, application
+// and environment only, and a resource cannot be changed once the tracer
+// provider exists, so the attributes are put on the spans instead.
+type resourceProcessor struct {
+	attrs []attribute.KeyValue
+}
+
+var _ sdktrace.SpanProcessor = (*resourceProcessor)(nil)
+
+func (p *resourceProcessor) OnStart(_ context.Context, s sdktrace.ReadWriteSpan) {
+	s.SetAttributes(p.attrs...)
+}
+
+func (p *resourceProcessor) OnEnd(sdktrace.ReadOnlySpan)      {}
+func (p *resourceProcessor) Shutdown(context.Context) error   { return nil }
+func (p *resourceProcessor) ForceFlush(context.Context) error { return nil }
+
+// detectResource runs the detectors listed in OBS_RESOURCE_DETECTORS
+// (comma-separated, "none" to disable) and adds what they find, along with
+// attrs, to every span. It needs the OpenTelemetry SDK, so it only works with
+// the OTLP APM type.
+func detectResource(obs *observability.Observability, attrs ...attribute.KeyValue) {
+	tp, ok := sdkTracerProvider()
+	if !ok {
+		return
+	}
+
+	var opts []resource.Option
+	for _, name := range strings.Split(Getenv(EnvResourceDetectors, DefaultResourceDetectors), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || name == "none" {
+			continue
+		}
+		opt, ok := resourceDetectors[name]
+		if !ok {
+			obs.Log.Warn("Unknown resource detector, skipped", "detector", name)
+			continue
+		}
+		opts = append(opts, opt)
+	}
+
+	if len(opts) > 0 {
+		ctx, cancel := context.WithTimeout(obs.Context(), detectTimeout)
+		defer cancel()
+		res, err := resource.New(ctx, opts...)
+		if err != nil {
+			// Detectors that fail still let the others contribute.
+			obs.Log.Warn("Resource detection incomplete", "error", err)
+		}
+		if res != nil && res.Len() > 0 {
+			attrs = append(attrs, res.Attributes()...)
+			obs.Log.Info("Resource detected", "resource", res.String())
+		}
+	}
+	if len(attrs) == 0 {
+		return
+	}
+	tp.RegisterSpanProcessor(&resourceProcessor{attrs: attrs})
+}
+
+// k8sDetector reads the pod identity that Kubernetes exposes through the
+// environment (via the downward API) and the service account mount.
+type k8sDetector struct{}
+
+func (k8sDetector) Detect(context.Context) (*resource.Resource, error) {
+	if os.Getenv("KUBERNETES_SERVICE_HOST") == "" {
+		return resource.Empty(), nil
+	}
+
+	var attrs []attribute.KeyValue
+	// The pod's hostname is its name unless the spec overrides it.
+	if pod := Getenv("POD_NAME", os.Getenv("HOSTNAME")); pod != "" {
+		attrs = append(attrs, attribute.String("k8s.pod.name", pod))
+	}
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		if b, err := os.ReadFile("/var/run/secrets/kubernetes.io/serviceaccount/namespace"); err == nil {
+			namespace = strings.TrimSpace(string(b))
+		}
+	}
+	if namespace != "" {
+		attrs = append(attrs, attribute.String("k8s.namespace.name", namespace))
+	}
+	if node := os.Getenv("NODE_NAME"); node != "" {
+		attrs = append(attrs, attribute.String("k8s.node.name", node))
+	}
+	return resource.NewSchemaless(attrs...), nil
+}
+
+// ec2Detector reads the instance identity document from the EC2 instance
+// metadata service, using an IMDSv2 session token.
+type ec2Detector struct{}
+
+func (ec2Detector) Detect(ctx context.Context) (*resource.Resource, error) {
+	const imds = "http://169.254.169.254/latest"
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, imds+"/api/token", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "60")
+	token, err := fetchMetadata(req)
+	if err != nil {
+		// Not on EC2.
+		return resource.Empty(), nil
+	}
+
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, imds+"/dynamic/instance-identity/document", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token", token)
+	body, err := fetchMetadata(req)
+	if err != nil {
+		return nil, fmt.Errorf("ec2: %w", err)
+	}
+
+	var doc struct {
+		AccountID        string `json:"accountId"`
+		AvailabilityZone string `json:"availabilityZone"`
+		Region           string `json:"region"`
+		InstanceID       string `json:"instanceId"`
+		InstanceType     string `json:"instanceType"`
+		ImageID          string `json:"imageId"`
+	}
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return nil, fmt.Errorf("ec2: invalid identity document: %w", err)
+	}
+	return resource.NewSchemaless(
+		attribute.String("cloud.provider", "aws"),
+		attribute.String("cloud.platform", "aws_ec2"),
+		attribute.String("cloud.account.id", doc.AccountID),
+		attribute.String("cloud.region", doc.Region),
+		attribute.String("cloud.availability_zone", doc.AvailabilityZone),
+		attribute.String("host.id", doc.InstanceID),
+		attribute.String("host.type", doc.InstanceType),
+		attribute.String("host.image.id", doc.ImageID),
+	), nil
+}
+
+// ecsDetector reads the task metadata that the ECS agent exposes to every
+// container through ECS_CONTAINER_METADATA_URI_V4.
+type ecsDetector struct{}
+
+func (ecsDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	uri := os.Getenv("ECS_CONTAINER_METADATA_URI_V4")
+	if uri == "" {
+		return resource.Empty(), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri+"/task", nil)
+	if err != nil {
+		return nil, err
+	}
+	body, err := fetchMetadata(req)
+	if err != nil {
+		return nil, fmt.Errorf("ecs: %w", err)
+	}
+
+	var task struct {
+		Cluster          string `json:"Cluster"`
+		TaskARN          string `json:"TaskARN"`
+		Family           string `json:"Family"`
+		Revision         string `json:"Revision"`
+		AvailabilityZone string `json:"AvailabilityZone"`
+		LaunchType       string `json:"LaunchType"`
+	}
+	if err := json.Unmarshal([]byte(body), &task); err != nil {
+		return nil, fmt.Errorf("ecs: invalid task metadata: %w", err)
+	}
+	return resource.NewSchemaless(
+		attribute.String("cloud.provider", "aws"),
+		attribute.String("cloud.platform", "aws_ecs"),
+		attribute.String("cloud.availability_zone", task.AvailabilityZone),
+		attribute.String("aws.ecs.cluster.arn", task.Cluster),
+		attribute.String("aws.ecs.task.arn", task.TaskARN),
+		attribute.String("aws.ecs.task.family", task.Family),
+		attribute.String("aws.ecs.task.revision", task.Revision),
+		attribute.String("aws.ecs.launchtype", strings.ToLower(task.LaunchType)),
+	), nil
+}
+
+// gceDetector reads the instance identity from the GCE metadata server.
+type gceDetector struct{}
+
+func (gceDetector) Detect(ctx context.Context) (*resource.Resource, error) {
+	const metadata = "http://metadata.google.internal/computeMetadata/v1"
+
+	get := func(path string) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadata+path, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("Metadata-Flavor", "Google")
+		return fetchMetadata(req)
+	}
+
+	instanceID, err := get("/instance/id")
+	if err != nil {
+		// Not on GCE.
+		return resource.Empty(), nil
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("cloud.provider", "gcp"),
+		attribute.String("cloud.platform", "gcp_compute_engine"),
+		attribute.String("host.id", instanceID),
+	}
+	if project, err := get("/project/project-id"); err == nil {
+		attrs = append(attrs, attribute.String("cloud.account.id", project))
+	}
+	// The zone comes back as projects/<number>/zones/<zone>.
+	if zone, err := get("/instance/zone"); err == nil {
+		zone = zone[strings.LastIndex(zone, "/")+1:]
+		attrs = append(attrs, attribute.String("cloud.availability_zone", zone))
+		if i := strings.LastIndex(zone, "-"); i > 0 {
+			attrs = append(attrs, attribute.String("cloud.region", zone[:i]))
+		}
+	}
+	if machineType, err := get("/instance/machine-type"); err == nil {
+		attrs = append(attrs, attribute.String("host.type", machineType[strings.LastIndex(machineType, "/")+1:]))
+	}
+	return resource.NewSchemaless(attrs...), nil
+}
+
+// fetchMetadata sends req to a metadata endpoint and returns the body.
+func fetchMetadata(req *http.Request) (string, error) {
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata request %s returned %s", req.URL.Path, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
@@ -0,0 +1,65 @@
+package servicekit
+
+import (
+	"context"
+	"net/http"
+	httppprof "net/http/pprof"
+	"runtime/pprof"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+var EnvDebugPort = "DEBUG_PORT"
+
+// debugHandler serves the net/http/pprof endpoints under /debug/pprof/.
+func debugHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", httppprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", httppprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", httppprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", httppprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", httppprof.Trace)
+	return mux
+}
+
+// startDebugServer serves debugHandler on DEBUG_PORT, if set. The profiling
+// endpoints get their own port so they are never exposed with the API. The
+// server stops along with the API.
+func startDebugServer(s *Service) {
+	port := Getenv(EnvDebugPort, "")
+	if port == "" {
+		return
+	}
+	// No write timeout: CPU profiles and execution traces take as long as
+	// the caller asks them to.
+	server := &http.Server{
+		Addr:        ":" + port,
+		Handler:     debugHandler(),
+		ReadTimeout: 10 * time.Second,
+		IdleTimeout: 15 * time.Second,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.Obs.ErrorHandler.Record(err, "Debug server stopped")
+		}
+	}()
+	s.OnDrain(server.Shutdown)
+	s.Obs.Log.Info("Debug server running", "address", server.Addr)
+}
+
+// profileLabels runs next under pprof labels naming the route and, with the
+// OTLP APM type, the trace and span being served, so CPU profiles can be
+// sliced by endpoint and individual slow requests found in them. It must run
+// inside obsmiddleware.WithObservability.
+func profileLabels(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		labels := []string{"http.route", route}
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			labels = append(labels, "trace.id", sc.TraceID().String(), "span.id", sc.SpanID().String())
+		}
+		pprof.Do(r.Context(), pprof.Labels(labels...), func(ctx context.Context) {
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+}
@@ -0,0 +1,160 @@
+package servicekit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/trace"
+
+	"obsmiddleware"
+)
+
+var (
+	EnvConfigFile             = "OBS_CONFIG_FILE"
+	EnvConfigPollInterval     = "OBS_CONFIG_POLL_INTERVAL"
+	DefaultConfigPollInterval = "10s"
+)
+
+// runtimeConfig is the observability configuration that can change while the
+// service runs.
+type runtimeConfig struct {
+	LogLevel   string   `json:"log_level"`
+	SampleRate *float64 `json:"sample_rate"`
+}
+
+// configWatcher applies the settings of the file named by OBS_CONFIG_FILE.
+type configWatcher struct {
+	obs  *observability.Observability
+	path string
+	// sampler is nil with APM types other than OTLP.
+	sampler *samplingTracerProvider
+	modTime time.Time
+}
+
+// watchConfig polls the JSON file named by OBS_CONFIG_FILE, if set, and
+// applies its settings whenever it changes, so Debug logs can be switched on
+// and off and the trace sampling rate lowered without a restart. Every change
+// is logged and recorded as a config.changed event on a span of its own.
+func (s *Service) watchConfig() {
+	path := Getenv(EnvConfigFile, "")
+	if path == "" {
+		return
+	}
+	val := Getenv(EnvConfigPollInterval, DefaultConfigPollInterval)
+	interval, err := time.ParseDuration(val)
+	if err != nil || interval <= 0 {
+		s.Obs.Log.Warn("Invalid config poll interval, config reload disabled", "value", val, "error", err)
+		return
+	}
+
+	w := &configWatcher{obs: s.Obs, path: path, sampler: installSampler()}
+	w.reload()
+	s.Go(func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				w.reload()
+			}
+		}
+	})
+	s.Obs.Log.Info("Watching config file", "path", path, "interval", interval.String())
+}
+
+// reload applies the config file if it changed since the last reload.
+func (w *configWatcher) reload() {
+	info, err := os.Stat(w.path)
+	if err != nil {
+		w.obs.Log.Warn("Failed to read config file", "path", w.path, "error", err)
+		return
+	}
+	if info.ModTime().Equal(w.modTime) {
+		return
+	}
+	w.modTime = info.ModTime()
+
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		w.obs.Log.Warn("Failed to read config file", "path", w.path, "error", err)
+		return
+	}
+	var cfg runtimeConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		w.obs.Log.Warn("Invalid config file, ignored", "path", w.path, "error", err)
+		return
+	}
+	w.applyLogLevel(cfg.LogLevel)
+	w.applySampleRate(cfg.SampleRate)
+}
+
+// applyLogLevel switches the level of the Debug logs written with
+// obsmiddleware.LogDebug. The library fixes the level of its logger at
+// startup, so Info and Warn logs keep OBS_LOG_LEVEL whatever the level.
+func (w *configWatcher) applyLogLevel(value string) {
+	if value == "" {
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(value)); err != nil {
+		w.obs.Log.Warn("Invalid log level in config file, ignored", "path", w.path, "logLevel", value)
+		return
+	}
+	old := obsmiddleware.LogLevel()
+	if level == old {
+		return
+	}
+	obsmiddleware.SetLogLevel(level)
+	w.changed("log_level", old.String(), level.String())
+	if level > slog.LevelInfo && obsmiddleware.ConfiguredLogLevel < level {
+		w.obs.Log.Warn("Only Debug logs follow the log level of the config file, Info and Warn logs keep OBS_LOG_LEVEL",
+			"logLevel", level.String(), "configuredLogLevel", obsmiddleware.ConfiguredLogLevel.String())
+	}
+}
+
+// applySampleRate changes the rate new traces are sampled at.
+func (w *configWatcher) applySampleRate(rate *float64) {
+	if rate == nil {
+		return
+	}
+	if *rate < 0 || *rate > 1 {
+		w.obs.Log.Warn("Invalid sample rate in config file, ignored", "path", w.path, "sampleRate", *rate)
+		return
+	}
+	if w.sampler == nil {
+		w.obs.Log.Warn("The sample rate can only be changed at run time with the OTLP APM type, ignored", "path", w.path)
+		return
+	}
+	if *rate > w.sampler.startRate {
+		w.obs.Log.Warn("Sample rate in config file is above OBS_SAMPLE_RATE, capped",
+			"sampleRate", *rate, "startSampleRate", w.sampler.startRate)
+	}
+	old := w.sampler.Rate()
+	w.sampler.SetRate(*rate)
+	if now := w.sampler.Rate(); now != old {
+		w.changed("sample_rate", formatRate(old), formatRate(now))
+	}
+}
+
+// changed logs a setting change and records it as a config.changed event.
+func (w *configWatcher) changed(key, old, value string) {
+	_, obs, span := w.obs.StartSpanWith("ConfigReload")
+	defer span.End()
+	span.AddEvent("config.changed", trace.WithAttributes(
+		observability.String("config.key", key),
+		observability.String("config.old_value", old),
+		observability.String("config.new_value", value),
+	))
+	obs.Log.Info("Config changed", "path", w.path, "key", key, "from", old, "to", value)
+}
+
+func formatRate(rate float64) string {
+	return strconv.FormatFloat(rate, 'g', -1, 64)
+}
@@ -0,0 +1,131 @@
+package servicekit
+
+import (
+	"context"
+	"encoding/binary"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"sync/atomic"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/embedded"
+)
+
+// samplingTracerProvider wraps the tracer provider of the OpenTelemetry SDK
+// with a head sampler whose rate can change while the service runs. The
+// library creates the SDK with a sampler fixed at OBS_SAMPLE_RATE, which
+// samples the root spans passed to it again, so the rate set with SetRate is
+// the effective rate only up to OBS_SAMPLE_RATE: it can be lowered and raised
+// back, but not above the rate the service started with.
+//
+// Like the SDK's parent-based sampler, a span with a parent follows the
+// parent's decision, so a trace dropped here, locally or by an upstream
+// service, stays dropped. Dropped spans are non-recording but carry a valid
+// span context, so their trace ID is still propagated and logged.
+type samplingTracerProvider struct {
+	embedded.TracerProvider
+	sdk *sdktrace.TracerProvider
+	// startRate is the OBS_SAMPLE_RATE the SDK samples root spans at.
+	startRate float64
+	// rate holds the current sampling rate as float64 bits.
+	rate atomic.Uint64
+}
+
+var _ trace.TracerProvider = (*samplingTracerProvider)(nil)
+
+// installSampler replaces the global tracer provider with a
+// samplingTracerProvider. It needs the OpenTelemetry SDK, so it returns nil
+// with APM types other than OTLP. The library fetches its tracer from the
+// global provider for every trace, so the sampler applies from then on.
+func installSampler() *samplingTracerProvider {
+	tp, ok := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+	if !ok {
+		return nil
+	}
+	// The library falls back to sampling everything on an invalid rate.
+	startRate, err := strconv.ParseFloat(Getenv("OBS_SAMPLE_RATE", "1"), 64)
+	if err != nil {
+		startRate = 1
+	}
+	p := &samplingTracerProvider{sdk: tp, startRate: startRate}
+	p.rate.Store(math.Float64bits(startRate))
+	otel.SetTracerProvider(p)
+	return p
+}
+
+// sdkTracerProvider returns the tracer provider of the OpenTelemetry SDK,
+// unwrapping the samplingTracerProvider if one was installed, or false with
+// APM types other than OTLP.
+func sdkTracerProvider() (*sdktrace.TracerProvider, bool) {
+	switch tp := otel.GetTracerProvider().(type) {
+	case *sdktrace.TracerProvider:
+		return tp, true
+	case *samplingTracerProvider:
+		return tp.sdk, true
+	}
+	return nil, false
+}
+
+// Rate returns the current sampling rate.
+func (p *samplingTracerProvider) Rate() float64 {
+	return math.Float64frombits(p.rate.Load())
+}
+
+// SetRate changes the sampling rate of new traces. rate must be between 0
+// and 1; it is capped at the rate the service started with.
+func (p *samplingTracerProvider) SetRate(rate float64) {
+	p.rate.Store(math.Float64bits(min(rate, p.startRate)))
+}
+
+// sampleRoot reports whether a new trace is passed on to the SDK, which keeps
+// startRate of them, so that rate of them are kept overall.
+func (p *samplingTracerProvider) sampleRoot() bool {
+	rate := p.Rate()
+	return rate >= p.startRate || rand.Float64()*p.startRate < rate
+}
+
+func (p *samplingTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return &samplingTracer{tracer: p.sdk.Tracer(name, opts...), p: p}
+}
+
+type samplingTracer struct {
+	embedded.Tracer
+	tracer trace.Tracer
+	p      *samplingTracerProvider
+}
+
+func (t *samplingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	parent := trace.SpanContextFromContext(ctx)
+	cfg := trace.NewSpanStartConfig(opts...)
+	if cfg.NewRoot() || !parent.IsValid() {
+		if !t.p.sampleRoot() {
+			return dropSpan(ctx, newTraceID(), trace.TraceState{})
+		}
+	} else if !parent.IsSampled() {
+		return dropSpan(ctx, parent.TraceID(), parent.TraceState())
+	}
+	return t.tracer.Start(ctx, name, opts...)
+}
+
+// dropSpan returns a non-recording span in trace traceID, unsampled so its
+// children are dropped too.
+func dropSpan(ctx context.Context, traceID trace.TraceID, state trace.TraceState) (context.Context, trace.Span) {
+	var spanID trace.SpanID
+	binary.BigEndian.PutUint64(spanID[:], rand.Uint64())
+	ctx = trace.ContextWithSpanContext(ctx, trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceState: state,
+	}))
+	return ctx, trace.SpanFromContext(ctx)
+}
+
+func newTraceID() trace.TraceID {
+	var id trace.TraceID
+	binary.BigEndian.PutUint64(id[:8], rand.Uint64())
+	binary.BigEndian.PutUint64(id[8:], rand.Uint64())
+	return id
+}
@@ -0,0 +1,74 @@
+package servicekit
+
+import (
+	"context"
+	"testing"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestSamplingTracer(t *testing.T) {
+	parent := func(flags trace.TraceFlags) context.Context {
+		return trace.ContextWithRemoteSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+			TraceID:    trace.TraceID{1},
+			SpanID:     trace.SpanID{1},
+			TraceFlags: flags,
+		}))
+	}
+	tests := []struct {
+		name          string
+		rate          float64
+		ctx           context.Context
+		wantRecording bool
+	}{
+		{"root at full rate", 1, context.Background(), true},
+		{"root at zero rate", 0, context.Background(), false},
+		{"sampled parent at zero rate", 0, parent(trace.FlagsSampled), true},
+		{"unsampled parent at full rate", 1, parent(0), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &samplingTracerProvider{sdk: sdktrace.NewTracerProvider(), startRate: 1}
+			p.SetRate(tt.rate)
+			_, span := p.Tracer("test").Start(tt.ctx, "op")
+			defer span.End()
+
+			if got := span.IsRecording(); got != tt.wantRecording {
+				t.Errorf("IsRecording = %v, want %v", got, tt.wantRecording)
+			}
+			sc := span.SpanContext()
+			if !sc.IsValid() {
+				t.Fatal("span context is invalid")
+			}
+			if sc.IsSampled() != tt.wantRecording {
+				t.Errorf("IsSampled = %v, want %v", sc.IsSampled(), tt.wantRecording)
+			}
+			if parentSC := trace.SpanContextFromContext(tt.ctx); parentSC.IsValid() && sc.TraceID() != parentSC.TraceID() {
+				t.Errorf("trace ID = %s, want the parent's %s", sc.TraceID(), parentSC.TraceID())
+			}
+		})
+	}
+}
+
+func TestSamplingTracerProviderSetRate(t *testing.T) {
+	tests := []struct {
+		name      string
+		startRate float64
+		rate      float64
+		want      float64
+	}{
+		{"lowered", 0.5, 0.1, 0.1},
+		{"capped at the start rate", 0.5, 1, 0.5},
+		{"zero", 1, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := &samplingTracerProvider{startRate: tt.startRate}
+			p.SetRate(tt.rate)
+			if got := p.Rate(); got != tt.want {
+				t.Errorf("Rate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
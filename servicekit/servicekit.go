@@ -0,0 +1,430 @@
+// Package servicekit runs the example services. It does what every one of
+// their main functions used to do by hand: set observability up from the
+// OBS_* environment variables, serve the health probes apart from the API,
+// run an HTTP server with explicit timeouts on PORT, stop it on SIGINT or
+// SIGTERM, and shut the service's components and then telemetry down. It
+// also answers the API's errors in the schema of apierror.
+//
+// Along with observability, every service gets the same telemetry setup: the
+// build, host and platform it runs on added to its spans, the span attribute
+// filter, a check of the collector, export statistics reported as metrics
+// and in a final shutdown report, and, with DEBUG_PORT, a pprof server. Run
+// also traces every API request, tags the first ones as cold starts, and
+// counts the requests in flight on each route registered with Handle.
+//
+// A service passes Run a function that sets its components up on the
+// Service it is given and returns its API handler, which Run traces:
+//
+//	func main() {
+//		servicekit.Run("cart", registerRoutes, servicekit.WithDefaultPort(DefaultPort))
+//	}
+//
+// Services without an HTTP API, such as queue consumers, use RunWorker.
+package servicekit
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+
+	"apierror"
+	"health"
+	"obsmiddleware"
+)
+
+var (
+	// EnvShutdownTimeout bounds how long the servers, and then the
+	// background work, get to finish once asked to stop.
+	EnvShutdownTimeout     = "SHUTDOWN_TIMEOUT"
+	DefaultShutdownTimeout = "10s"
+	// EnvDrainDelay is how long a service keeps serving after it reports
+	// itself as not ready, before its servers are asked to stop: the time
+	// load balancers and Kubernetes take to stop sending it requests.
+	EnvDrainDelay     = "SHUTDOWN_DRAIN_DELAY"
+	DefaultDrainDelay = "0s"
+	// EnvDependencyCheckTTL and EnvDependencyCheckTimeout configure the
+	// checks of the services a service depends on: how long a result is
+	// reused, and how long a probe may take.
+	EnvDependencyCheckTTL         = "DEPENDENCY_CHECK_TTL"
+	DefaultDependencyCheckTTL     = "5s"
+	EnvDependencyCheckTimeout     = "DEPENDENCY_CHECK_TIMEOUT"
+	DefaultDependencyCheckTimeout = "1s"
+)
+
+// apmType is the APM backend the factory sends spans to, from OBS_APM_TYPE.
+var apmType = Getenv("OBS_APM_TYPE", "none")
+
+// Getenv returns the value of the environment variable or a default value if not set
+func Getenv(envKey, defaultValue string) string {
+	if value := os.Getenv(envKey); value != "" {
+		return value
+	}
+	return defaultValue
+}
+
+// Service is a service being run by Run or RunWorker.
+type Service struct {
+	// Name is the service name in telemetry: OBS_SERVICE_NAME if set, or
+	// else the name the service was run with.
+	Name string
+
+	Factory *observability.Factory
+
+	// Obs is the background observability instance, for logs and spans
+	// outside of requests.
+	Obs *observability.Observability
+
+	// Checks holds the readiness checks served at /readyz. Components
+	// register theirs as they are set up.
+	Checks *health.Registry
+
+	// DependencyCheckTTL and DependencyCheckTimeout are the ttl and timeout
+	// of the health.HTTPChecker of each service this one depends on, from
+	// DEPENDENCY_CHECK_TTL and DEPENDENCY_CHECK_TIMEOUT.
+	DependencyCheckTTL     time.Duration
+	DependencyCheckTimeout time.Duration
+
+	// Meter is the service's meter, named after it.
+	Meter metric.Meter
+
+	// Spans starts the root span of every API request. It is Factory unless
+	// the setup function replaces it, such as to route some requests to
+	// another APM backend. RunWorker leaves it unused.
+	Spans obsmiddleware.SpanStarter
+
+	// Server is the HTTP server Run serves the API with, its address,
+	// timeouts and connection hooks already set. Run sets its Handler.
+	// RunWorker leaves it unused.
+	Server *http.Server
+
+	shutdown *shutdownReporter
+	closers  []func()
+
+	inFlight   *inFlightTracker
+	beforeSpan []func(http.Handler) http.Handler
+
+	shutdownTimeout time.Duration
+	drainDelay      time.Duration
+	drainers        []func(ctx context.Context) error
+
+	// workCtx is canceled to stop the background work started with Go.
+	workCtx  context.Context
+	stopWork context.CancelFunc
+	workers  sync.WaitGroup
+}
+
+// AddTelemetry registers shutdowner to be flushed and shut down along with
+// the service's telemetry, such as a second tracing backend. name identifies
+// it in the shutdown report.
+func (s *Service) AddTelemetry(name string, shutdowner observability.Shutdowner) {
+	s.shutdown.Add(name, shutdowner)
+}
+
+// Handle registers handler on mux for pattern, counting its requests in
+// flight and running it under pprof labels, both under the route of pattern.
+func (s *Service) Handle(mux *http.ServeMux, pattern string, handler http.Handler) {
+	route := obsmiddleware.RouteOf(pattern)
+	mux.Handle(pattern, s.inFlight.Middleware(route, profileLabels(route, handler)))
+}
+
+// BeforeSpan registers middleware that Run mounts in front of the API's
+// tracing, for work that has to be done before the request span starts, such
+// as taking a secret out of the URL the span records. The first registered
+// runs first.
+func (s *Service) BeforeSpan(middleware func(http.Handler) http.Handler) {
+	s.beforeSpan = append(s.beforeSpan, middleware)
+}
+
+// OnShutdown registers close to run when the service stops, once the servers
+// and the background work have stopped and before telemetry is shut down, so
+// whatever close logs or traces is still exported. Functions run in the
+// reverse order of their registration, like deferred calls.
+func (s *Service) OnShutdown(close func()) {
+	s.closers = append(s.closers, close)
+}
+
+// OnDrain registers stop to stop a server of the service other than its HTTP
+// API, such as a gRPC or debug server, along with the API. stop must stop
+// accepting new requests and return once the ones in flight are done, or
+// once ctx is done, when it should drop them.
+func (s *Service) OnDrain(stop func(ctx context.Context) error) {
+	s.drainers = append(s.drainers, stop)
+}
+
+// Go runs work in the background, such as a queue consumer, until ctx is
+// done. ctx is done once the servers have stopped, so the messages behind
+// requests already accepted are still handled; the service then waits for
+// work to return, within the shutdown timeout, before the OnShutdown
+// functions close the connections work uses.
+func (s *Service) Go(work func(ctx context.Context)) {
+	s.workers.Add(1)
+	go func() {
+		defer s.workers.Done()
+		work(s.workCtx)
+	}()
+}
+
+// Fatal logs msg and args at Error level, shuts the service and then
+// telemetry down, so that record is exported, and exits with status 1. Use it
+// instead of obs.ErrorHandler.Fatal, which exits without flushing.
+func (s *Service) Fatal(msg string, args ...any) {
+	// Call Logc directly so the log source still points at our caller.
+	s.Obs.Log.Logc(slog.LevelError, 3, msg, args...)
+	s.stop("fatal: " + msg)
+	os.Exit(1)
+}
+
+// stop runs the OnShutdown functions and then shuts telemetry down.
+// Background work still running is canceled, but not waited for.
+func (s *Service) stop(exitReason string) {
+	s.stopWork()
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		s.closers[i]()
+	}
+	s.shutdown.ShutdownOrLog(telemetryShutdownTimeout, "Error during observability shutdown", exitReason)
+}
+
+type options struct {
+	defaultPort string
+}
+
+// Option configures Run and RunWorker.
+type Option func(*options)
+
+// WithDefaultPort sets the port Run listens on when PORT is not set.
+func WithDefaultPort(port string) Option {
+	return func(o *options) {
+		o.defaultPort = port
+	}
+}
+
+// newService sets observability and the telemetry setup shared by every
+// service up, exiting on failure.
+func newService(name string, opts []Option) (*Service, options) {
+	o := options{defaultPort: "8080"}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	// The factory reads the OBS_* environment variables, such as
+	// OBS_SERVICE_NAME, OBS_APPLICATION, OBS_ENVIRONMENT, OBS_APM_TYPE and
+	// OBS_APM_URL. Options passed to it win over them, so name is only passed
+	// when OBS_SERVICE_NAME is not set.
+	var factoryOpts []observability.Option
+	if envName := os.Getenv("OBS_SERVICE_NAME"); envName != "" {
+		name = envName
+	} else {
+		factoryOpts = append(factoryOpts, observability.WithServiceName(name))
+	}
+	// Name the spans and logs started outside the factory like the rest.
+	obsmiddleware.SetServiceName(name)
+	factory := observability.NewFactory(factoryOpts...)
+	shutdowner := factory.SetupOrExit("Failed to setup observability")
+
+	s := &Service{
+		Name:    name,
+		Factory: factory,
+		Obs:     factory.NewBackgroundObservability(context.Background()),
+		Checks:  health.New(2 * time.Second),
+		Meter:   otel.Meter(name),
+		Spans:   factory,
+		// Shut down through the reporter, which logs a final shutdown report.
+		shutdown: newShutdownReporter(name, shutdowner),
+	}
+	s.workCtx, s.stopWork = context.WithCancel(context.Background())
+
+	// Add the build, host, container and platform the service runs on to its
+	// spans, and rewrite the attributes configured as sensitive.
+	build := readBuildInfo()
+	logBuildInfo(s.Obs, build)
+	detectResource(s.Obs, build.attributes()...)
+	obsmiddleware.FilterAttributes(s.Obs)
+
+	s.Checks.RegisterNonCritical("telemetry", s.shutdown.stats)
+	preflightCollector(s.Obs, s.Checks)
+	if err := s.shutdown.stats.RegisterMetrics(s.Meter); err != nil {
+		s.Fatal("Failed to register telemetry export metrics", "error", err)
+	}
+	startSpanMetrics(s.Obs, s.Meter)
+
+	var err error
+	if s.shutdownTimeout, err = time.ParseDuration(Getenv(EnvShutdownTimeout, DefaultShutdownTimeout)); err != nil || s.shutdownTimeout <= 0 {
+		s.Fatal("Invalid shutdown timeout", "value", Getenv(EnvShutdownTimeout, DefaultShutdownTimeout))
+	}
+	if s.drainDelay, err = time.ParseDuration(Getenv(EnvDrainDelay, DefaultDrainDelay)); err != nil || s.drainDelay < 0 {
+		s.Fatal("Invalid shutdown drain delay", "value", Getenv(EnvDrainDelay, DefaultDrainDelay))
+	}
+	if s.DependencyCheckTTL, err = time.ParseDuration(Getenv(EnvDependencyCheckTTL, DefaultDependencyCheckTTL)); err != nil || s.DependencyCheckTTL < 0 {
+		s.Fatal("Invalid dependency check TTL", "value", Getenv(EnvDependencyCheckTTL, DefaultDependencyCheckTTL))
+	}
+	if s.DependencyCheckTimeout, err = time.ParseDuration(Getenv(EnvDependencyCheckTimeout, DefaultDependencyCheckTimeout)); err != nil || s.DependencyCheckTimeout <= 0 {
+		s.Fatal("Invalid dependency check timeout", "value", Getenv(EnvDependencyCheckTimeout, DefaultDependencyCheckTimeout))
+	}
+	// Last, as the sampler it installs hides the SDK's tracer provider.
+	s.watchConfig()
+	return s, o
+}
+
+// Run runs an HTTP service named name. registerRoutes sets the service up
+// and returns its API handler, which is served at every path but the
+// liveness and readiness probes, /healthz and /readyz. Those are served
+// untraced, from s.Checks. Each API request is traced with
+// obsmiddleware.WithObservability, starting its span with s.Spans, gets the
+// faults configured in OBS_CHAOS, and has its panics recovered; the handler
+// names the span with obsmiddleware.WithRoute. The API's errors are answered
+// in the schema of apierror. The server listens on PORT, or the port set with
+// WithDefaultPort, until it fails or gets SIGINT or SIGTERM, such as from
+// docker compose down or a Kubernetes rollout. The service then drains:
+//
+//  1. /readyz answers 503, and the API is still served for
+//     SHUTDOWN_DRAIN_DELAY, while the service is taken out of rotation.
+//  2. The servers, the API's and those registered with OnDrain, stop
+//     accepting requests and get SHUTDOWN_TIMEOUT to finish the ones in
+//     flight.
+//  3. The background work started with Go is canceled and gets what is left
+//     of that time to return.
+//  4. The OnShutdown functions run, and telemetry is flushed and shut down
+//     last, so the spans of the requests drained are exported.
+//
+// Setup failures are reported with s.Fatal.
+func Run(name string, registerRoutes func(s *Service) http.Handler, opts ...Option) {
+	s, o := newService(name, opts)
+	s.Server = &http.Server{
+		Addr:         ":" + Getenv("PORT", o.defaultPort),
+		ReadTimeout:  10 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		IdleTimeout:  15 * time.Second,
+	}
+
+	// Timestamp connections so handlers can see how long requests queued.
+	conns := &connTimes{}
+	s.Server.ConnContext = conns.ConnContext
+	s.Server.ConnState = conns.ConnState
+
+	coldStart, err := newColdStartTracker(s.Meter, coldStartRequests())
+	if err != nil {
+		s.Fatal("Failed to create cold start tracker", "error", err)
+	}
+	if s.inFlight, err = newInFlightTracker(s.Meter); err != nil {
+		s.Fatal("Failed to create in-flight tracker", "error", err)
+	}
+	// Faults listed in OBS_CHAOS are injected into requests, to rehearse incidents.
+	chaos, err := newChaos(Getenv(EnvChaos, ""))
+	if err != nil {
+		s.Fatal("Invalid chaos faults", "error", err)
+	}
+	if chaos.Enabled() {
+		s.Obs.Log.Warn("Chaos faults enabled", "faults", Getenv(EnvChaos, ""))
+	}
+
+	// registerRoutes may replace s.Spans, so it runs first.
+	api := registerRoutes(s)
+	api = obsmiddleware.WithObservability(s.Spans, coldStart.Middleware(obsmiddleware.Recoverer(chaos.Middleware(api))))
+	for i := len(s.beforeSpan) - 1; i >= 0; i-- {
+		api = s.beforeSpan[i](api)
+	}
+
+	root := http.NewServeMux()
+	root.HandleFunc("/healthz", s.Checks.HandleLiveness)
+	root.HandleFunc("/readyz", s.Checks.HandleReadiness)
+	root.Handle("/", apierror.Middleware(api))
+	s.Server.Handler = root
+
+	startDebugServer(s)
+	startBudgetReport(s.Obs)
+	recordStartupDuration(s.Obs, s.Meter)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Server.ListenAndServe()
+	}()
+	s.Obs.Log.Info("Server running", "address", s.Server.Addr)
+
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			s.Fatal("Server stopped with an error", "error", err)
+		}
+		s.stop("server closed")
+	case <-ctx.Done():
+		stop()
+		s.drain()
+		s.stop("signal received")
+	}
+}
+
+// drain takes the service out of rotation, stops its servers and then its
+// background work, as described for Run.
+func (s *Service) drain() {
+	s.Checks.Drain()
+	s.Obs.Log.Info("Server stopping", "reason", "signal received", "drainDelay", s.drainDelay.String(), "shutdownTimeout", s.shutdownTimeout.String())
+	time.Sleep(s.drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, stop := range append([]func(context.Context) error{s.Server.Shutdown}, s.drainers...) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := stop(ctx); err != nil {
+				s.Obs.ErrorHandler.Record(err, "Server did not stop cleanly")
+			}
+		}()
+	}
+	wg.Wait()
+	s.stopBackground(ctx)
+}
+
+// stopBackground cancels the background work and waits for it until ctx is
+// done.
+func (s *Service) stopBackground(ctx context.Context) {
+	s.stopWork()
+	done := make(chan struct{})
+	go func() {
+		s.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		s.Obs.Log.Warn("Background work did not stop in time", "shutdownTimeout", s.shutdownTimeout.String())
+	}
+}
+
+// RunWorker runs a service named name that serves no HTTP API. work runs
+// until it returns or ctx is done, on SIGINT or SIGTERM; the background work
+// started with Go is then canceled and gets SHUTDOWN_TIMEOUT to return,
+// before the OnShutdown functions run and telemetry is shut down. An error
+// from work is reported with s.Fatal.
+func RunWorker(name string, work func(ctx context.Context, s *Service) error, opts ...Option) {
+	s, _ := newService(name, opts)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := work(ctx, s); err != nil {
+		s.Fatal("Worker stopped with an error", "error", err)
+	}
+	exitReason := "work done"
+	if ctx.Err() != nil {
+		exitReason = "signal received"
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), s.shutdownTimeout)
+	defer cancel()
+	s.stopBackground(shutdownCtx)
+	s.stop(exitReason)
+}
@@ -0,0 +1,248 @@
+package servicekit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"github.com/go-logr/logr"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// telemetryShutdownTimeout bounds the whole telemetry shutdown, flushes
+// included.
+const telemetryShutdownTimeout = 10 * time.Second
+
+// exportErrorWindow is how long telemetry is reported as failing after an
+// export error.
+const exportErrorWindow = time.Minute
+
+// exportWarnInterval is the least time between two warnings about failing
+// exports. An unreachable collector fails every export, and one warning a
+// minute says as much as one per batch.
+const exportWarnInterval = time.Minute
+
+// fallbackLogger writes straight to stdout. The observability logger may be
+// shut down (and, with asynchronous logging, unusable) by the time the last
+// records are written, so those go through this logger instead.
+var fallbackLogger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// exportStats collects export statistics from the OpenTelemetry SDK. It is
+// installed as the SDK's internal logger, since the batch span processor logs
+// the size of every batch it exports along with the number of spans it has
+// dropped so far, and as its error handler, which receives failed exports.
+type exportStats struct {
+	spansExported atomic.Int64
+	spansDropped  atomic.Int64
+	exportErrors  atomic.Int64
+	lastErrorAt   atomic.Int64 // Unix nanoseconds
+
+	lastWarnAt atomic.Int64 // Unix nanoseconds
+	suppressed atomic.Int64 // errors not logged since the last warning
+}
+
+var _ logr.LogSink = (*exportStats)(nil)
+
+func (s *exportStats) Init(logr.RuntimeInfo)          {}
+func (s *exportStats) Enabled(int) bool               { return true }
+func (s *exportStats) WithValues(...any) logr.LogSink { return s }
+func (s *exportStats) WithName(string) logr.LogSink   { return s }
+
+func (s *exportStats) Info(_ int, msg string, keysAndValues ...any) {
+	if msg != "exporting spans" {
+		return
+	}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		switch keysAndValues[i] {
+		case "count":
+			if n, ok := keysAndValues[i+1].(int); ok {
+				s.spansExported.Add(int64(n))
+			}
+		case "total_dropped":
+			if n, ok := keysAndValues[i+1].(uint32); ok {
+				s.spansDropped.Store(int64(n))
+			}
+		}
+	}
+}
+
+func (s *exportStats) Error(err error, msg string, _ ...any) {
+	now := time.Now().UnixNano()
+	s.exportErrors.Add(1)
+	s.lastErrorAt.Store(now)
+
+	last := s.lastWarnAt.Load()
+	if (last != 0 && time.Duration(now-last) < exportWarnInterval) || !s.lastWarnAt.CompareAndSwap(last, now) {
+		s.suppressed.Add(1)
+		return
+	}
+	fallbackLogger.Warn("Telemetry export failing, check that the collector is reachable",
+		"error", err,
+		"detail", msg,
+		"apmURL", Getenv("OBS_APM_URL", ""),
+		"exportErrors", s.exportErrors.Load(),
+		"suppressed", s.suppressed.Swap(0),
+	)
+}
+
+// Check fails if the SDK reported an error within exportErrorWindow, which
+// makes it a health check for the telemetry pipeline.
+func (s *exportStats) Check(context.Context) error {
+	at := s.lastErrorAt.Load()
+	if at == 0 {
+		return nil
+	}
+	if since := time.Since(time.Unix(0, at)); since < exportErrorWindow {
+		return fmt.Errorf("telemetry error %s ago, %d in total", since.Round(time.Second), s.exportErrors.Load())
+	}
+	return nil
+}
+
+// RegisterMetrics reports the export statistics as metrics through meter, so
+// lost telemetry shows up on dashboards and not only in the shutdown report.
+func (s *exportStats) RegisterMetrics(meter metric.Meter) error {
+	exported, err := meter.Int64ObservableCounter("telemetry.spans.exported",
+		metric.WithDescription("Spans handed to the exporter by the batch span processor"),
+		metric.WithUnit("{span}"),
+	)
+	if err != nil {
+		return err
+	}
+	dropped, err := meter.Int64ObservableCounter("telemetry.spans.dropped",
+		metric.WithDescription("Spans dropped because the batch span processor queue was full"),
+		metric.WithUnit("{span}"),
+	)
+	if err != nil {
+		return err
+	}
+	errs, err := meter.Int64ObservableCounter("telemetry.export.errors",
+		metric.WithDescription("Errors reported by the OpenTelemetry SDK, mostly failed exports"),
+		metric.WithUnit("{error}"),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveInt64(exported, s.spansExported.Load())
+		o.ObserveInt64(dropped, s.spansDropped.Load())
+		o.ObserveInt64(errs, s.exportErrors.Load())
+		return nil
+	}, exported, dropped, errs)
+	return err
+}
+
+// shutdownReporter shuts telemetry down and then writes a final
+// "Shutdown report" record, so post-mortems can tell whether telemetry was
+// lost at exit: how many spans were exported or dropped over the life of the
+// process, how many exports failed, and how long each shutdown step took.
+// Span statistics are only available with the OTLP APM type.
+type shutdownReporter struct {
+	service    string
+	components []shutdownStep
+	stats      *exportStats
+}
+
+// shutdownStep is one part of the telemetry shutdown, timed and reported on
+// its own.
+type shutdownStep struct {
+	name string
+	run  func(context.Context) error
+}
+
+// newShutdownReporter starts collecting export statistics for the service
+// named service. Call it right after observability setup. shutdowner is
+// reported as the "telemetry" component.
+func newShutdownReporter(service string, shutdowner observability.Shutdowner) *shutdownReporter {
+	stats := &exportStats{}
+	otel.SetLogger(logr.New(stats))
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		stats.Error(err, "")
+	}))
+	return &shutdownReporter{
+		service:    service,
+		components: []shutdownStep{{name: "telemetry", run: shutdowner.Shutdown}},
+		stats:      stats,
+	}
+}
+
+// Add registers another component to shut down, such as a second tracing
+// backend. name identifies it in the shutdown report.
+func (r *shutdownReporter) Add(name string, shutdowner observability.Shutdowner) {
+	r.components = append(r.components, shutdownStep{name: name, run: shutdowner.Shutdown})
+}
+
+// ShutdownOrLog flushes and shuts down telemetry within timeout, and then
+// writes the shutdown report with exitReason. Flushes, and then component
+// shutdowns, run concurrently, so one hanging exporter does not use up the
+// time of the others. Every step that fails is logged with msg and named in
+// the report.
+func (r *shutdownReporter) ShutdownOrLog(timeout time.Duration, msg, exitReason string) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Flush explicitly first, so the time spent exporting is reported apart
+	// from the time spent shutting down.
+	var flushes []shutdownStep
+	if tp, ok := sdkTracerProvider(); ok {
+		flushes = append(flushes, shutdownStep{name: "traceFlush", run: tp.ForceFlush})
+	}
+	if mp, ok := otel.GetMeterProvider().(*sdkmetric.MeterProvider); ok {
+		flushes = append(flushes, shutdownStep{name: "metricFlush", run: mp.ForceFlush})
+	}
+	results := runSteps(ctx, flushes)
+	results = append(results, runSteps(ctx, r.components)...)
+
+	durations := make([]any, 0, len(results))
+	failed := []string{}
+	for _, res := range results {
+		durations = append(durations, slog.String(res.name, res.duration.String()))
+		if res.err != nil {
+			failed = append(failed, res.name)
+			fallbackLogger.Error(msg, "component", res.name, "error", res.err)
+		}
+	}
+
+	fallbackLogger.Info("Shutdown report",
+		"service", r.service,
+		"exitReason", exitReason,
+		"uptime", time.Since(processStart).String(),
+		"spansExported", r.stats.spansExported.Load(),
+		"spansDropped", r.stats.spansDropped.Load(),
+		"exportErrors", r.stats.exportErrors.Load(),
+		"clean", len(failed) == 0,
+		"failed", failed,
+		slog.Group("durations", durations...),
+	)
+}
+
+// stepResult is the outcome of a shutdownStep.
+type stepResult struct {
+	name     string
+	duration time.Duration
+	err      error
+}
+
+// runSteps runs steps concurrently and returns their results in the same
+// order.
+func runSteps(ctx context.Context, steps []shutdownStep) []stepResult {
+	results := make([]stepResult, len(steps))
+	var wg sync.WaitGroup
+	for i, step := range steps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			err := step.run(ctx)
+			results[i] = stepResult{name: step.name, duration: time.Since(start), err: err}
+		}()
+	}
+	wg.Wait()
+	return results
+}
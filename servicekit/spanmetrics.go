@@ -0,0 +1,89 @@
+package servicekit
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+var EnvSpanMetrics = "OBS_SPAN_METRICS"
+
+// spanMetricsProcessor derives RED metrics (rate, errors, duration) from
+// ended spans, named and labeled like those of the OpenTelemetry Collector's
+// spanmetrics connector, so the same dashboards work whether the metrics are
+// generated here or in a collector or Tempo.
+type spanMetricsProcessor struct {
+	calls    metric.Int64Counter
+	duration metric.Float64Histogram
+}
+
+var _ sdktrace.SpanProcessor = (*spanMetricsProcessor)(nil)
+
+func newSpanMetricsProcessor(meter metric.Meter) (*spanMetricsProcessor, error) {
+	calls, err := meter.Int64Counter("traces.span.metrics.calls",
+		metric.WithDescription("Spans ended, by span name, kind and status code"),
+		metric.WithUnit("{call}"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	duration, err := meter.Float64Histogram("traces.span.metrics.duration",
+		metric.WithDescription("Duration of spans, by span name, kind and status code"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &spanMetricsProcessor{calls: calls, duration: duration}, nil
+}
+
+func (p *spanMetricsProcessor) OnStart(context.Context, sdktrace.ReadWriteSpan) {}
+
+func (p *spanMetricsProcessor) OnEnd(s sdktrace.ReadOnlySpan) {
+	attrs := metric.WithAttributes(
+		attribute.String("span.name", s.Name()),
+		attribute.String("span.kind", "SPAN_KIND_"+strings.ToUpper(s.SpanKind().String())),
+		attribute.String("status.code", "STATUS_CODE_"+strings.ToUpper(s.Status().Code.String())),
+	)
+	// The span's context is gone by now; the measurements need none.
+	ctx := context.Background()
+	p.calls.Add(ctx, 1, attrs)
+	p.duration.Record(ctx, s.EndTime().Sub(s.StartTime()).Seconds(), attrs)
+}
+
+func (p *spanMetricsProcessor) Shutdown(context.Context) error   { return nil }
+func (p *spanMetricsProcessor) ForceFlush(context.Context) error { return nil }
+
+// startSpanMetrics generates span metrics when OBS_SPAN_METRICS is true, for
+// setups without a collector or Tempo metrics-generator to derive them. Span
+// names must stay low-cardinality for this to be affordable. It needs the
+// OpenTelemetry SDK, so it only works with the OTLP APM type.
+func startSpanMetrics(obs *observability.Observability, meter metric.Meter) {
+	val := Getenv(EnvSpanMetrics, "false")
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		obs.Log.Warn("Invalid span metrics setting, span metrics disabled", "value", val, "error", err)
+		return
+	}
+	if !enabled {
+		return
+	}
+
+	tp, ok := sdkTracerProvider()
+	if !ok {
+		obs.Log.Debug("Span metrics need the OTLP tracer, span metrics disabled")
+		return
+	}
+	p, err := newSpanMetricsProcessor(meter)
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Failed to create span metrics")
+		return
+	}
+	tp.RegisterSpanProcessor(p)
+	obs.Log.Info("Span metrics enabled")
+}
@@ -0,0 +1,86 @@
+package servicekit
+
+import (
+	"net/url"
+	"strconv"
+)
+
+// TelemetryEnv renders the service's observability configuration as
+// environment variables for a child process, such as a load generator or a
+// replay tool, so it reports to the same backend with the same settings.
+// serviceName is the name the child reports under; empty means s.Name.
+//
+// The result holds both the OBS_* variables read by observability.NewFactory,
+// for tools built on this repo's library, and their standard OTEL_*
+// equivalents, for any other OpenTelemetry SDK. Values mirror the library
+// defaults for unset variables. Pass it as exec.Cmd.Env, after os.Environ()
+// if the child needs the rest of the environment too.
+func (s *Service) TelemetryEnv(serviceName string) []string {
+	if serviceName == "" {
+		serviceName = s.Name
+	}
+	app := Getenv("OBS_APPLICATION", "unknown-app")
+	environment := Getenv("OBS_ENVIRONMENT", "development")
+	apmURL := Getenv("OBS_APM_URL", "")
+	metricsType := Getenv("OBS_METRICS_TYPE", "none")
+	sampleRate := Getenv("OBS_SAMPLE_RATE", "1")
+	if _, err := strconv.ParseFloat(sampleRate, 64); err != nil {
+		sampleRate = "1" // the library ignores invalid rates too
+	}
+
+	env := []string{
+		"OBS_SERVICE_NAME=" + serviceName,
+		"OBS_APPLICATION=" + app,
+		"OBS_ENVIRONMENT=" + environment,
+		"OBS_APM_TYPE=" + apmType,
+		"OBS_METRICS_TYPE=" + metricsType,
+		"OBS_APM_URL=" + apmURL,
+		"OBS_SAMPLE_RATE=" + sampleRate,
+		"OBS_LOG_LEVEL=" + Getenv("OBS_LOG_LEVEL", "debug"),
+		"OBS_TRACE_LOG_LEVEL=" + Getenv("OBS_TRACE_LOG_LEVEL", "info"),
+
+		"OTEL_SERVICE_NAME=" + serviceName,
+		// Resource attribute values must be percent-encoded.
+		"OTEL_RESOURCE_ATTRIBUTES=application=" + url.PathEscape(app) + ",environment=" + url.PathEscape(environment),
+		"OTEL_PROPAGATORS=tracecontext,baggage",
+		"OTEL_TRACES_SAMPLER=traceidratio",
+		"OTEL_TRACES_SAMPLER_ARG=" + sampleRate,
+		// Logs are written to stdout and shipped by the Docker logging driver.
+		"OTEL_LOGS_EXPORTER=none",
+	}
+
+	// Only the OTLP backend has an OTEL_* equivalent; Datadog is configured
+	// through its agent.
+	if apmType == "otlp" && apmURL != "" {
+		env = append(env,
+			"OTEL_EXPORTER_OTLP_ENDPOINT="+apmURL,
+			"OTEL_EXPORTER_OTLP_PROTOCOL=http/protobuf",
+			"OTEL_TRACES_EXPORTER=otlp",
+		)
+		// The span batching and limit settings, read by the SDK's tracer
+		// provider in the library and in any child.
+		for _, key := range []string{
+			"OTEL_BSP_MAX_QUEUE_SIZE", "OTEL_BSP_MAX_EXPORT_BATCH_SIZE", "OTEL_BSP_SCHEDULE_DELAY", "OTEL_BSP_EXPORT_TIMEOUT",
+			"OTEL_SPAN_ATTRIBUTE_COUNT_LIMIT", "OTEL_SPAN_EVENT_COUNT_LIMIT",
+		} {
+			if val := Getenv(key, ""); val != "" {
+				env = append(env, key+"="+val)
+			}
+		}
+	} else {
+		env = append(env, "OTEL_TRACES_EXPORTER=none")
+	}
+	if apmType == "otlp" && metricsType == "otlp" && apmURL != "" {
+		env = append(env, "OTEL_METRICS_EXPORTER=otlp")
+		// The service's own metric export settings, which the library's
+		// exporter reads from the environment as well.
+		for _, key := range []string{"OTEL_EXPORTER_OTLP_METRICS_TEMPORALITY_PREFERENCE", "OTEL_METRIC_EXPORT_INTERVAL"} {
+			if val := Getenv(key, ""); val != "" {
+				env = append(env, key+"="+val)
+			}
+		}
+	} else {
+		env = append(env, "OTEL_METRICS_EXPORTER=none")
+	}
+	return env
+}
@@ -0,0 +1,9 @@
+package main
+
+import "servicekit"
+
+var DefaultPort = "8094"
+
+func main() {
+	servicekit.Run("session", registerRoutes, servicekit.WithDefaultPort(DefaultPort))
+}
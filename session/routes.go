@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/baggage"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvSessionSecret  = "SESSION_SECRET"
+	EnvSessionTTL     = "SESSION_TTL"
+	DefaultSessionTTL = "30m"
+)
+
+// SessionCookie is the cookie that carries the session ID. Browsers send a
+// cookie to every port of the host that set it, so the frontend gets it too.
+const SessionCookie = "session_id"
+
+// registerRoutes sets the session store up and returns the session API.
+func registerRoutes(s *servicekit.Service) http.Handler {
+	ttl, err := time.ParseDuration(servicekit.Getenv(EnvSessionTTL, DefaultSessionTTL))
+	if err != nil || ttl <= 0 {
+		s.Fatal("Invalid session TTL", "value", servicekit.Getenv(EnvSessionTTL, DefaultSessionTTL))
+	}
+	secret := []byte(servicekit.Getenv(EnvSessionSecret, ""))
+	if len(secret) == 0 {
+		// Cookies signed with a random secret do not survive a restart.
+		secret = make([]byte, 32)
+		rand.Read(secret)
+		s.Obs.Log.Warn("SESSION_SECRET not set, sessions end when the service restarts")
+	}
+
+	// Sessions are kept in process, so they have no readiness check.
+	store := NewSessionStore(secret, ttl)
+	s.Obs.Log.Info("Session store ready", "sessionTTL", ttl.String())
+
+	mux := http.NewServeMux()
+	s.Handle(mux, "POST /sessions", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleCreateSession(r.Context(), w, r, observability.ObsFromCtx(r.Context()), store, ttl)
+	}))
+	s.Handle(mux, "GET /sessions/current", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleGetSession(r.Context(), w, r, observability.ObsFromCtx(r.Context()), store)
+	}))
+	s.Handle(mux, "DELETE /sessions/current", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handleDeleteSession(r.Context(), w, r, observability.ObsFromCtx(r.Context()), store)
+	}))
+
+	return obsmiddleware.WithRoute(mux)
+}
+
+// createSessionRequest is the optional body of POST /sessions.
+type createSessionRequest struct {
+	UserID string `json:"userId"`
+}
+
+// handleCreateSession starts a session, for the user in the request body if
+// any, and sets its cookie.
+func handleCreateSession(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	store *SessionStore, ttl time.Duration) {
+	var req createSessionRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		obs.ErrorHandler.HTTP(w, "Invalid session request", http.StatusBadRequest)
+		return
+	}
+
+	session, cookie, err := store.Create(ctx, req.UserID)
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Failed to create session")
+		http.Error(w, "Failed to create session", http.StatusInternalServerError)
+		return
+	}
+	withSessionID(ctx, obs, session.ID)
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookie,
+		Value:    cookie,
+		Path:     "/",
+		MaxAge:   int(ttl.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	obs.Log.Info("Session created", "authenticated", req.UserID != "")
+	writeSession(w, http.StatusCreated, session)
+}
+
+// handleGetSession answers with the session of the request's cookie, which
+// is how other services validate a cookie they were sent.
+func handleGetSession(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	store *SessionStore) {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, "Missing session cookie", http.StatusUnauthorized)
+		return
+	}
+	session, err := store.Get(ctx, cookie.Value)
+	if err != nil {
+		writeSessionError(w, obs, err)
+		return
+	}
+	withSessionID(ctx, obs, session.ID)
+
+	obsmiddleware.LogDebug(obs, "Session validated")
+	writeSession(w, http.StatusOK, session)
+}
+
+// handleDeleteSession ends the session of the request's cookie and clears
+// the cookie.
+func handleDeleteSession(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	store *SessionStore) {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		obs.ErrorHandler.HTTP(w, "Missing session cookie", http.StatusUnauthorized)
+		return
+	}
+	id, err := store.Delete(ctx, cookie.Value)
+	if err != nil {
+		writeSessionError(w, obs, err)
+		return
+	}
+	withSessionID(ctx, obs, id)
+
+	http.SetCookie(w, &http.Cookie{Name: SessionCookie, Path: "/", MaxAge: -1, HttpOnly: true})
+	obs.Log.Info("Session ended")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// withSessionID records the session a request belongs to, once its cookie
+// has been checked, as session.id on the request span and on the request's
+// log records. Requests whose session.id baggage member already matches were
+// recorded by obsmiddleware.WithObservability.
+func withSessionID(ctx context.Context, obs *observability.Observability, id string) {
+	if baggage.FromContext(ctx).Member("session.id").Value() == id {
+		return
+	}
+	if span, ok := obsmiddleware.SpanFromContext(ctx); ok {
+		span.SetAttributes(observability.String("session.id", id))
+	}
+	obs.Log = obs.Log.With("session.id", id)
+}
+
+// writeSession answers with session as JSON.
+func writeSession(w http.ResponseWriter, status int, session *Session) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(session)
+}
+
+// writeSessionError answers with the status matching a store error.
+func writeSessionError(w http.ResponseWriter, obs *observability.Observability, err error) {
+	switch {
+	case errors.Is(err, ErrSessionInvalid):
+		obs.ErrorHandler.HTTP(w, "Invalid session cookie", http.StatusUnauthorized)
+	case errors.Is(err, ErrSessionNotFound):
+		obs.ErrorHandler.HTTP(w, "Session expired", http.StatusUnauthorized)
+	default:
+		obs.ErrorHandler.HTTP(w, "Failed to validate session", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+)
+
+var (
+	// ErrSessionInvalid is returned for a cookie that was not issued by the
+	// service, or was tampered with.
+	ErrSessionInvalid = errors.New("invalid session cookie")
+	// ErrSessionNotFound is returned for a session that expired or ended.
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// Session is a user's session.
+type Session struct {
+	ID        string    `json:"sessionId"`
+	UserID    string    `json:"userId,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// SessionStore keeps sessions in memory and signs the cookies that carry
+// their IDs, so a client cannot make up a session ID and have it looked up.
+type SessionStore struct {
+	secret []byte
+	ttl    time.Duration
+
+	mu        sync.Mutex
+	sessions  map[string]*Session // by session ID
+	lastSweep time.Time
+}
+
+// NewSessionStore creates a store whose cookies are signed with secret and
+// whose sessions last ttl.
+func NewSessionStore(secret []byte, ttl time.Duration) *SessionStore {
+	return &SessionStore{secret: secret, ttl: ttl, sessions: map[string]*Session{}}
+}
+
+// Create starts a session for userID, which may be empty for an anonymous
+// visitor, and returns it with the value of its cookie.
+func (s *SessionStore) Create(ctx context.Context, userID string) (*Session, string, error) {
+	_, _, span := observability.StartSpanFromCtxWith(ctx, "SessionStore.Create")
+	defer span.End()
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		span.RecordError(err)
+		return nil, "", err
+	}
+	session := &Session{ID: hex.EncodeToString(b), UserID: userID, ExpiresAt: time.Now().Add(s.ttl).UTC()}
+
+	s.mu.Lock()
+	s.sweep(time.Now())
+	s.sessions[session.ID] = session
+	s.mu.Unlock()
+	span.SetAttributes(observability.String("session.id", session.ID))
+	return session, s.sign(session.ID), nil
+}
+
+// Get returns the session whose ID the cookie value carries.
+func (s *SessionStore) Get(ctx context.Context, cookie string) (*Session, error) {
+	_, _, span := observability.StartSpanFromCtxWith(ctx, "SessionStore.Get")
+	defer span.End()
+
+	id, err := s.verify(cookie)
+	if err != nil {
+		span.SetAttributes(observability.Bool("session.valid", false))
+		return nil, err
+	}
+	span.SetAttributes(observability.String("session.id", id))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || time.Now().After(session.ExpiresAt) {
+		span.SetAttributes(observability.Bool("session.valid", false))
+		return nil, ErrSessionNotFound
+	}
+	span.SetAttributes(observability.Bool("session.valid", true))
+	return session, nil
+}
+
+// Delete ends the session whose ID the cookie value carries. Ending a
+// session that already ended is not an error.
+func (s *SessionStore) Delete(ctx context.Context, cookie string) (string, error) {
+	_, _, span := observability.StartSpanFromCtxWith(ctx, "SessionStore.Delete")
+	defer span.End()
+
+	id, err := s.verify(cookie)
+	if err != nil {
+		return "", err
+	}
+	span.SetAttributes(observability.String("session.id", id))
+
+	s.mu.Lock()
+	delete(s.sessions, id)
+	s.mu.Unlock()
+	return id, nil
+}
+
+// sign returns the cookie value for the session id: the ID and its HMAC.
+func (s *SessionStore) sign(id string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(id))
+	return id + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// verify returns the session ID of a cookie value if its HMAC is right.
+func (s *SessionStore) verify(cookie string) (string, error) {
+	id, _, ok := strings.Cut(cookie, ".")
+	if !ok || !hmac.Equal([]byte(cookie), []byte(s.sign(id))) {
+		return "", ErrSessionInvalid
+	}
+	return id, nil
+}
+
+// sweep drops, at most once a minute, the sessions that expired before now.
+// s.mu must be held.
+func (s *SessionStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+	for id, session := range s.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
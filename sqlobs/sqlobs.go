@@ -0,0 +1,156 @@
+// Package sqlobs instruments database/sql with the observability library:
+// every statement run through a DB gets a client span recording the
+// statement text and the database system. Statements should use
+// placeholders, since only the text is recorded and never the arguments.
+package sqlobs
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// DB wraps a *sql.DB so that ExecContext, QueryContext and QueryRowContext
+// record a span per statement, as do those of the transactions started with
+// BeginTx. Other methods of sql.DB are passed through uninstrumented.
+type DB struct {
+	*sql.DB
+	system string
+}
+
+// Open opens a database with sql.Open and wraps it. system is the
+// db.system attribute value, such as "postgresql".
+func Open(driverName, dataSourceName, system string) (*DB, error) {
+	db, err := sql.Open(driverName, dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	return Wrap(db, system), nil
+}
+
+// Wrap wraps db. system is the db.system attribute value.
+func Wrap(db *sql.DB, system string) *DB {
+	return &DB{DB: db, system: system}
+}
+
+// BeginTx starts a transaction whose statements are instrumented too.
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, system: db.system}, nil
+}
+
+// ExecContext runs query under a span that also records the affected rows.
+func (db *DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return execContext(ctx, db.DB, db.system, query, args)
+}
+
+// QueryContext runs query under a span. The span ends when QueryContext
+// returns, so it covers the query but not reading the rows.
+func (db *DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return queryContext(ctx, db.DB, db.system, query, args)
+}
+
+// QueryRowContext runs query under a span. A missing row does not fail the
+// span: sql.ErrNoRows is only reported by Scan, and the caller decides
+// whether a missing row is an error.
+func (db *DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return queryRowContext(ctx, db.DB, db.system, query, args)
+}
+
+// Tx wraps a *sql.Tx like DB wraps a *sql.DB. Commit and Rollback are not
+// instrumented.
+type Tx struct {
+	*sql.Tx
+	system string
+}
+
+// ExecContext is like DB.ExecContext, within the transaction.
+func (tx *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	return execContext(ctx, tx.Tx, tx.system, query, args)
+}
+
+// QueryContext is like DB.QueryContext, within the transaction.
+func (tx *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	return queryContext(ctx, tx.Tx, tx.system, query, args)
+}
+
+// QueryRowContext is like DB.QueryRowContext, within the transaction.
+func (tx *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	return queryRowContext(ctx, tx.Tx, tx.system, query, args)
+}
+
+// conn is the part of *sql.DB and *sql.Tx that runs statements.
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func execContext(ctx context.Context, c conn, system, query string, args []any) (sql.Result, error) {
+	ctx, span := startSpan(ctx, system, query)
+	defer span.End()
+
+	res, err := c.ExecContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "statement failed")
+		return nil, err
+	}
+	if n, err := res.RowsAffected(); err == nil {
+		span.SetAttributes(attribute.Int64("db.rows_affected", n))
+	}
+	return res, nil
+}
+
+func queryContext(ctx context.Context, c conn, system, query string, args []any) (*sql.Rows, error) {
+	ctx, span := startSpan(ctx, system, query)
+	defer span.End()
+
+	rows, err := c.QueryContext(ctx, query, args...)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "query failed")
+		return nil, err
+	}
+	return rows, nil
+}
+
+func queryRowContext(ctx context.Context, c conn, system, query string, args []any) *sql.Row {
+	ctx, span := startSpan(ctx, system, query)
+	defer span.End()
+
+	row := c.QueryRowContext(ctx, query, args...)
+	if err := row.Err(); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "query failed")
+	}
+	return row
+}
+
+// startSpan starts the span of query, named after its operation.
+func startSpan(ctx context.Context, system, query string) (context.Context, observability.Span) {
+	operation := Operation(query)
+	ctx, _, span := observability.StartSpanFromCtxWith(ctx, "db."+strings.ToLower(operation),
+		attribute.String("db.system", system),
+		attribute.String("db.operation", operation),
+		attribute.String("db.statement", query),
+	)
+	return ctx, span
+}
+
+// Operation returns the first keyword of query in upper case, such as
+// "SELECT" or "UPDATE".
+func Operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.ToUpper(fields[0])
+}
@@ -0,0 +1,231 @@
+package sqlobs
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	// testFactory is not set up: its spans are started with the tracer
+	// provider of TestMain, which records them.
+	testFactory = observability.NewFactory(
+		observability.WithServiceName("sqlobs-test"),
+		observability.WithApmType("otlp"),
+		observability.WithMetricsType("none"),
+	)
+	recorder = tracetest.NewSpanRecorder()
+)
+
+func TestMain(m *testing.M) {
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	sql.Register("sqlobs-fake", fakeDriver{})
+	os.Exit(m.Run())
+}
+
+var errBroken = errors.New("relation \"broken\" does not exist")
+
+// fakeDriver answers statements by their text: those mentioning "broken"
+// fail, queries mentioning "missing" return no rows, and the others
+// affect or return a single row.
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (fakeConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	if strings.Contains(query, "broken") {
+		return nil, errBroken
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (fakeConn) QueryContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Rows, error) {
+	switch {
+	case strings.Contains(query, "broken"):
+		return nil, errBroken
+	case strings.Contains(query, "missing"):
+		return &fakeRows{}, nil
+	}
+	return &fakeRows{values: [][]driver.Value{{"Laptop"}}}, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct{ values [][]driver.Value }
+
+func (r *fakeRows) Columns() []string { return []string{"name"} }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+// attr returns the value of the attribute key of span.
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+// requestContext returns the context of a request span of testFactory, as
+// handlers pass to sqlobs. The request span ends with the test.
+func requestContext(t *testing.T) context.Context {
+	_, ctx, span, _ := testFactory.StartSpanFromRequest(httptest.NewRequest(http.MethodGet, "/inventory/123", nil))
+	t.Cleanup(span.End)
+	return ctx
+}
+
+func TestOperation(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"SELECT name FROM products WHERE id = $1", "SELECT"},
+		{"update stock set quantity = $1", "UPDATE"},
+		{"\n\tINSERT INTO schema_migrations (version) VALUES ($1)", "INSERT"},
+		{"", ""},
+		{"   ", ""},
+	}
+	for _, tt := range tests {
+		if got := Operation(tt.query); got != tt.want {
+			t.Errorf("Operation(%q) = %q, want %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestStatementSpan(t *testing.T) {
+	db, err := Open("sqlobs-fake", "", "postgresql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	tests := []struct {
+		name       string
+		query      string
+		run        func(ctx context.Context, query string) error
+		wantErr    error
+		wantSpan   string
+		wantStatus codes.Code
+	}{
+		{"exec", "UPDATE stock SET quantity = $1", func(ctx context.Context, query string) error {
+			_, err := db.ExecContext(ctx, query, 3)
+			return err
+		}, nil, "db.update", codes.Unset},
+		{"exec failure", "UPDATE broken SET quantity = $1", func(ctx context.Context, query string) error {
+			_, err := db.ExecContext(ctx, query, 3)
+			return err
+		}, errBroken, "db.update", codes.Error},
+		{"query", "SELECT name FROM products", func(ctx context.Context, query string) error {
+			rows, err := db.QueryContext(ctx, query)
+			if err != nil {
+				return err
+			}
+			return rows.Close()
+		}, nil, "db.select", codes.Unset},
+		{"query failure", "SELECT name FROM broken", func(ctx context.Context, query string) error {
+			_, err := db.QueryContext(ctx, query)
+			return err
+		}, errBroken, "db.select", codes.Error},
+		{"query row", "SELECT name FROM products WHERE id = $1", func(ctx context.Context, query string) error {
+			var name string
+			return db.QueryRowContext(ctx, query, "1").Scan(&name)
+		}, nil, "db.select", codes.Unset},
+		{"query row without rows", "SELECT name FROM products WHERE name = 'missing'", func(ctx context.Context, query string) error {
+			var name string
+			return db.QueryRowContext(ctx, query).Scan(&name)
+		}, sql.ErrNoRows, "db.select", codes.Unset},
+		{"query row failure", "SELECT name FROM broken", func(ctx context.Context, query string) error {
+			var name string
+			return db.QueryRowContext(ctx, query).Scan(&name)
+		}, errBroken, "db.select", codes.Error},
+		{"transaction", "INSERT INTO schema_migrations (version) VALUES ($1)", func(ctx context.Context, query string) error {
+			tx, err := db.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			defer tx.Rollback()
+			if _, err := tx.ExecContext(ctx, query, "001"); err != nil {
+				return err
+			}
+			return tx.Commit()
+		}, nil, "db.insert", codes.Unset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder.Reset()
+			if err := tt.run(requestContext(t), tt.query); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+
+			spans := recorder.Ended()
+			if len(spans) != 1 {
+				t.Fatalf("ended spans = %d, want 1", len(spans))
+			}
+			span := spans[0]
+			if span.Name() != tt.wantSpan {
+				t.Errorf("span name = %q, want %q", span.Name(), tt.wantSpan)
+			}
+			if got := span.Status().Code; got != tt.wantStatus {
+				t.Errorf("span status = %v, want %v", got, tt.wantStatus)
+			}
+			if got := attr(span, "db.system").AsString(); got != "postgresql" {
+				t.Errorf("db.system = %q, want %q", got, "postgresql")
+			}
+			if got := attr(span, "db.statement").AsString(); got != tt.query {
+				t.Errorf("db.statement = %q, want %q", got, tt.query)
+			}
+		})
+	}
+}
+
+func TestExecRowsAffected(t *testing.T) {
+	db, err := Open("sqlobs-fake", "", "postgresql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	recorder.Reset()
+
+	if _, err := db.ExecContext(requestContext(t), "UPDATE stock SET quantity = quantity - $1", 1); err != nil {
+		t.Fatal(err)
+	}
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("ended spans = %d, want 1", len(spans))
+	}
+	if got := attr(spans[0], "db.rows_affected").AsInt64(); got != 1 {
+		t.Errorf("db.rows_affected = %d, want 1", got)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+var EnvAuditLogFile = "AUDIT_LOG_FILE"
+
+// Audit outcomes.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// auditRecord is one line of the audit log. Records are chained: each
+// carries the hash of the one before it, so a record that is removed,
+// reordered or edited breaks the chain from that point on.
+type auditRecord struct {
+	Seq      uint64         `json:"audit.seq"`
+	Time     string         `json:"time"`
+	Service  string         `json:"service"`
+	Action   string         `json:"action"`
+	Subject  string         `json:"subject"`
+	Outcome  string         `json:"outcome"`
+	TraceID  string         `json:"trace.id,omitempty"`
+	SpanID   string         `json:"span.id,omitempty"`
+	Fields   map[string]any `json:"fields,omitempty"`
+	PrevHash string         `json:"audit.prev_hash"`
+	Hash     string         `json:"audit.hash,omitempty"`
+}
+
+// auditLog writes audit records to their own sink, apart from the
+// application logs, which may be sampled, filtered by level or dropped.
+// It is safe for concurrent use.
+type auditLog struct {
+	mu       sync.Mutex
+	w        io.Writer
+	file     *os.File // nil when writing to stderr
+	seq      uint64
+	prevHash string
+}
+
+// newAuditLog creates the audit log. Records are appended to
+// AUDIT_LOG_FILE if set, and written to stderr otherwise, so they never mix
+// with the application logs on stdout. A file that already holds records is
+// continued: the chain goes on from the sequence number and hash of its last
+// record.
+func newAuditLog() (*auditLog, error) {
+	a := &auditLog{
+		w:        os.Stderr,
+		prevHash: hex.EncodeToString(make([]byte, sha256.Size)),
+	}
+	path := servicekit.Getenv(EnvAuditLogFile, "")
+	if path == "" {
+		return a, nil
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("audit log: %w", err)
+	}
+	last, found, err := lastAuditRecord(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("audit log %s: %w", path, err)
+	}
+	if found {
+		a.seq = last.Seq
+		a.prevHash = last.Hash
+	}
+	a.w = f
+	a.file = f
+	return a, nil
+}
+
+// lastAuditRecord returns the last record written to r, if any. A last line
+// that is not a complete record, as left by a crash in the middle of a write,
+// is an error: appending to it would hide the break in the chain.
+func lastAuditRecord(r io.Reader) (auditRecord, bool, error) {
+	var last []byte
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if line := scanner.Bytes(); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return auditRecord{}, false, err
+	}
+	if last == nil {
+		return auditRecord{}, false, nil
+	}
+	var rec auditRecord
+	if err := json.Unmarshal(last, &rec); err != nil || rec.Seq == 0 || rec.Hash == "" {
+		return auditRecord{}, false, fmt.Errorf("last record is incomplete: %q", last)
+	}
+	return rec, true, nil
+}
+
+// Close closes the audit log file, if any. Records audited after Close fail.
+func (a *auditLog) Close() error {
+	if a.file == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.file.Close()
+}
+
+// Audit records that action was performed on subject with the given outcome.
+// fields are key-value pairs, as for the logger. The record is linked to the
+// current trace, and an audit event with its sequence number is added to the
+// span so the trace points back at the audit entry.
+func (a *auditLog) Audit(obs *observability.Observability, action, subject, outcome string, fields ...any) error {
+	if action == "" || subject == "" || outcome == "" {
+		return errors.New("audit: action, subject and outcome are required")
+	}
+	rec := auditRecord{
+		Time:    time.Now().UTC().Format(time.RFC3339Nano),
+		Service: obsmiddleware.ServiceName(),
+		Action:  action,
+		Subject: subject,
+		Outcome: outcome,
+		Fields:  auditFields(fields),
+	}
+	span := trace.SpanFromContext(obs.Context())
+	if sc := span.SpanContext(); sc.IsValid() {
+		rec.TraceID = sc.TraceID().String()
+		rec.SpanID = sc.SpanID().String()
+	}
+
+	a.mu.Lock()
+	rec.Seq = a.seq + 1
+	rec.PrevHash = a.prevHash
+	// The hash covers the whole record, including the previous hash, as
+	// serialized without its own hash.
+	unsigned, err := json.Marshal(rec)
+	if err != nil {
+		a.mu.Unlock()
+		return fmt.Errorf("audit: %w", err)
+	}
+	sum := sha256.Sum256(unsigned)
+	rec.Hash = hex.EncodeToString(sum[:])
+	line, err := json.Marshal(rec)
+	if err == nil {
+		_, err = a.w.Write(append(line, '\n'))
+	}
+	if err == nil {
+		a.seq = rec.Seq
+		a.prevHash = rec.Hash
+	}
+	a.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("audit: %w", err)
+	}
+
+	span.AddEvent("audit", trace.WithAttributes(
+		attribute.String("audit.action", action),
+		attribute.String("audit.outcome", outcome),
+		attribute.Int64("audit.seq", int64(rec.Seq)),
+	))
+	return nil
+}
+
+// auditFields turns key-value pairs into a map. A key without a value is
+// kept under !BADKEY, as slog does.
+func auditFields(kvs []any) map[string]any {
+	if len(kvs) == 0 {
+		return nil
+	}
+	fields := make(map[string]any, (len(kvs)+1)/2)
+	for i := 0; i < len(kvs); i += 2 {
+		key, ok := kvs[i].(string)
+		if !ok || i+1 == len(kvs) {
+			fields["!BADKEY"] = kvs[i]
+			continue
+		}
+		fields[key] = kvs[i+1]
+	}
+	return fields
+}
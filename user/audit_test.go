@@ -0,0 +1,48 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLastAuditRecord(t *testing.T) {
+	tests := []struct {
+		name      string
+		log       string
+		wantFound bool
+		wantSeq   uint64
+		wantHash  string
+		wantErr   bool
+	}{
+		{name: "empty"},
+		{name: "blank lines", log: "\n\n"},
+		{
+			name:      "last of several",
+			log:       `{"audit.seq":1,"audit.hash":"aa"}` + "\n" + `{"audit.seq":2,"audit.hash":"bb"}` + "\n",
+			wantFound: true, wantSeq: 2, wantHash: "bb",
+		},
+		{
+			name:      "no trailing newline",
+			log:       `{"audit.seq":7,"audit.hash":"cc"}`,
+			wantFound: true, wantSeq: 7, wantHash: "cc",
+		},
+		{
+			name:    "torn last line",
+			log:     `{"audit.seq":1,"audit.hash":"aa"}` + "\n" + `{"audit.seq":2,"audit.ha`,
+			wantErr: true,
+		},
+		{name: "missing hash", log: `{"audit.seq":3}` + "\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec, found, err := lastAuditRecord(strings.NewReader(tt.log))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lastAuditRecord error = %v, want error %v", err, tt.wantErr)
+			}
+			if found != tt.wantFound || rec.Seq != tt.wantSeq || rec.Hash != tt.wantHash {
+				t.Errorf("lastAuditRecord = seq %d hash %q found %v, want seq %d hash %q found %v",
+					rec.Seq, rec.Hash, found, tt.wantSeq, tt.wantHash, tt.wantFound)
+			}
+		})
+	}
+}
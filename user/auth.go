@@ -0,0 +1,210 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"grpcobs"
+	"obsmiddleware"
+	"servicekit"
+)
+
+var (
+	EnvJWTSecret = "JWT_SECRET"
+	EnvJWTIssuer = "JWT_ISSUER"
+)
+
+// jwtLeeway is the clock skew allowed when checking exp and nbf.
+const jwtLeeway = 30 * time.Second
+
+// errToken is the reason a request's bearer token was refused. Its message
+// is safe to show the client and to log: it never includes the token.
+type errToken string
+
+func (e errToken) Error() string { return string(e) }
+
+const (
+	errTokenMissing   errToken = "missing bearer token"
+	errTokenMalformed errToken = "malformed token"
+	errTokenAlgorithm errToken = "unsupported signing algorithm"
+	errTokenSignature errToken = "invalid signature"
+	errTokenExpired   errToken = "token expired"
+	errTokenNotYet    errToken = "token not yet valid"
+	errTokenIssuer    errToken = "unexpected issuer"
+	errTokenSubject   errToken = "missing subject"
+)
+
+// jwtClaims are the registered claims the verifier checks.
+type jwtClaims struct {
+	Subject   string `json:"sub"`
+	Issuer    string `json:"iss"`
+	ExpiresAt *int64 `json:"exp"`
+	NotBefore *int64 `json:"nbf"`
+}
+
+// jwtAuth verifies the HS256-signed JWTs that callers send as bearer tokens.
+// A nil *jwtAuth lets every request through.
+type jwtAuth struct {
+	secret []byte
+	issuer string
+}
+
+// newJWTAuth returns the verifier configured by JWT_SECRET and, optionally,
+// JWT_ISSUER, or nil if JWT_SECRET is not set.
+func newJWTAuth() *jwtAuth {
+	secret := servicekit.Getenv(EnvJWTSecret, "")
+	if secret == "" {
+		return nil
+	}
+	return &jwtAuth{secret: []byte(secret), issuer: servicekit.Getenv(EnvJWTIssuer, "")}
+}
+
+// Middleware answers requests without a valid token with 401. For the others,
+// the token's subject becomes the user.id baggage member, replacing any the
+// caller sent, and is set as user.id on the request span and on every record
+// logged for the request. The token itself is never recorded.
+func (a *jwtAuth) Middleware(next http.Handler) http.Handler {
+	if a == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		obs := observability.ObsFromCtx(ctx)
+		span, _ := obsmiddleware.SpanFromContext(ctx)
+
+		claims, err := a.verify(r.Header.Get("Authorization"), time.Now())
+		if err != nil {
+			if span != nil {
+				span.AddEvent("auth.failure", trace.WithAttributes(observability.String("auth.failure_reason", err.Error())))
+			}
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			obs.ErrorHandler.HTTP(w, "Unauthorized: "+err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		ctx = withUserID(ctx, claims.Subject)
+		if span != nil {
+			span.SetAttributes(observability.String("user.id", claims.Subject))
+		}
+		obs.Log = obs.Log.With("user.id", claims.Subject)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// UnaryServerInterceptor does for the gRPC calls what Middleware does for
+// HTTP requests, with the bearer token in the authorization metadata. Calls
+// without a valid token fail with UNAUTHENTICATED. Chain it after
+// grpcobs.UnaryServerInterceptor, so the call has a span and an
+// Observability instance.
+func (a *jwtAuth) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if a == nil {
+			return handler(ctx, req)
+		}
+		span := trace.SpanFromContext(ctx)
+
+		var authorization string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if values := md.Get("authorization"); len(values) > 0 {
+				authorization = values[0]
+			}
+		}
+		claims, err := a.verify(authorization, time.Now())
+		if err != nil {
+			span.AddEvent("auth.failure", trace.WithAttributes(observability.String("auth.failure_reason", err.Error())))
+			return nil, status.Error(codes.Unauthenticated, "unauthorized: "+err.Error())
+		}
+
+		ctx = withUserID(ctx, claims.Subject)
+		span.SetAttributes(observability.String("user.id", claims.Subject))
+		obs := grpcobs.ObsFromCtx(ctx)
+		obs.Log = obs.Log.With("user.id", claims.Subject)
+		return handler(ctx, req)
+	}
+}
+
+// verify checks the bearer token in the Authorization header value and
+// returns its claims.
+func (a *jwtAuth) verify(authorization string, now time.Time) (*jwtClaims, error) {
+	scheme, token, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, errTokenMissing
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errTokenMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, errTokenMalformed
+	}
+	// Only the configured algorithm is accepted, whatever the token claims.
+	if header.Alg != "HS256" {
+		return nil, errTokenAlgorithm
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errTokenMalformed
+	}
+	mac := hmac.New(sha256.New, a.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, errTokenSignature
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, errTokenMalformed
+	}
+	switch {
+	case claims.ExpiresAt != nil && now.After(time.Unix(*claims.ExpiresAt, 0).Add(jwtLeeway)):
+		return nil, errTokenExpired
+	case claims.NotBefore != nil && now.Add(jwtLeeway).Before(time.Unix(*claims.NotBefore, 0)):
+		return nil, errTokenNotYet
+	case a.issuer != "" && claims.Issuer != a.issuer:
+		return nil, errTokenIssuer
+	case claims.Subject == "":
+		return nil, errTokenSubject
+	}
+	return &claims, nil
+}
+
+// decodeSegment decodes a base64url-encoded JSON segment of a JWT into v.
+func decodeSegment(segment string, v any) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// withUserID returns ctx with id as its user.id baggage member. A subject
+// that cannot be a baggage value is left out of the baggage only.
+func withUserID(ctx context.Context, id string) context.Context {
+	member, err := baggage.NewMemberRaw("user.id", id)
+	if err != nil {
+		return ctx
+	}
+	bag, err := baggage.FromContext(ctx).SetMember(member)
+	if err != nil {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
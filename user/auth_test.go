@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"go.opentelemetry.io/otel/baggage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+var testFactory = observability.NewFactory(
+	observability.WithServiceName("user-test"),
+	observability.WithApmType("none"),
+	observability.WithMetricsType("none"),
+)
+
+func TestMain(m *testing.M) {
+	if _, err := testFactory.Setup(context.Background()); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+// signToken returns a JWT with header and claims, signed with HS256 and
+// secret whatever alg the header names.
+func signToken(t *testing.T, secret string, header, claims map[string]any) string {
+	t.Helper()
+	enc := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	msg := enc(header) + "." + enc(claims)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(msg))
+	return msg + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestJWTAuthVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	unix := func(d time.Duration) int64 { return now.Add(d).Unix() }
+	hs256 := map[string]any{"alg": "HS256", "typ": "JWT"}
+	valid := func(t *testing.T, claims map[string]any) string {
+		return "Bearer " + signToken(t, "s3cret", hs256, claims)
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"alice"}`)) + "."
+
+	tests := []struct {
+		name          string
+		issuer        string
+		authorization func(t *testing.T) string
+		wantSubject   string
+		wantErr       error
+	}{
+		{
+			name:          "valid",
+			authorization: func(t *testing.T) string { return valid(t, map[string]any{"sub": "alice", "exp": unix(time.Hour)}) },
+			wantSubject:   "alice",
+		},
+		{
+			name: "lowercase scheme",
+			authorization: func(t *testing.T) string {
+				return "bearer " + signToken(t, "s3cret", hs256, map[string]any{"sub": "alice"})
+			},
+			wantSubject: "alice",
+		},
+		{
+			name:          "missing",
+			authorization: func(*testing.T) string { return "" },
+			wantErr:       errTokenMissing,
+		},
+		{
+			name:          "basic scheme",
+			authorization: func(*testing.T) string { return "Basic YWxpY2U6cHc=" },
+			wantErr:       errTokenMissing,
+		},
+		{
+			name:          "two segments",
+			authorization: func(*testing.T) string { return "Bearer a.b" },
+			wantErr:       errTokenMalformed,
+		},
+		{
+			name:          "alg none",
+			authorization: func(*testing.T) string { return "Bearer " + unsigned },
+			wantErr:       errTokenAlgorithm,
+		},
+		{
+			name: "alg confusion with RS256",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + signToken(t, "s3cret", map[string]any{"alg": "RS256"}, map[string]any{"sub": "alice"})
+			},
+			wantErr: errTokenAlgorithm,
+		},
+		{
+			name: "alg confusion with HS512",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + signToken(t, "s3cret", map[string]any{"alg": "HS512"}, map[string]any{"sub": "alice"})
+			},
+			wantErr: errTokenAlgorithm,
+		},
+		{
+			name: "wrong secret",
+			authorization: func(t *testing.T) string {
+				return "Bearer " + signToken(t, "other", hs256, map[string]any{"sub": "alice"})
+			},
+			wantErr: errTokenSignature,
+		},
+		{
+			name:          "expired",
+			authorization: func(t *testing.T) string { return valid(t, map[string]any{"sub": "alice", "exp": unix(-time.Minute)}) },
+			wantErr:       errTokenExpired,
+		},
+		{
+			name: "expired within leeway",
+			authorization: func(t *testing.T) string {
+				return valid(t, map[string]any{"sub": "alice", "exp": unix(-10 * time.Second)})
+			},
+			wantSubject: "alice",
+		},
+		{
+			name:          "not yet valid",
+			authorization: func(t *testing.T) string { return valid(t, map[string]any{"sub": "alice", "nbf": unix(time.Minute)}) },
+			wantErr:       errTokenNotYet,
+		},
+		{
+			name: "nbf within leeway",
+			authorization: func(t *testing.T) string {
+				return valid(t, map[string]any{"sub": "alice", "nbf": unix(10 * time.Second)})
+			},
+			wantSubject: "alice",
+		},
+		{
+			name:          "wrong issuer",
+			issuer:        "auth.example",
+			authorization: func(t *testing.T) string { return valid(t, map[string]any{"sub": "alice", "iss": "evil"}) },
+			wantErr:       errTokenIssuer,
+		},
+		{
+			name:          "expected issuer",
+			issuer:        "auth.example",
+			authorization: func(t *testing.T) string { return valid(t, map[string]any{"sub": "alice", "iss": "auth.example"}) },
+			wantSubject:   "alice",
+		},
+		{
+			name:          "missing subject",
+			authorization: func(t *testing.T) string { return valid(t, map[string]any{"exp": unix(time.Hour)}) },
+			wantErr:       errTokenSubject,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			auth := &jwtAuth{secret: []byte("s3cret"), issuer: tt.issuer}
+			claims, err := auth.verify(tt.authorization(t), now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("verify error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && claims.Subject != tt.wantSubject {
+				t.Errorf("subject = %q, want %q", claims.Subject, tt.wantSubject)
+			}
+		})
+	}
+}
+
+func TestJWTAuthUnaryServerInterceptor(t *testing.T) {
+	token := "Bearer " + signToken(t, "s3cret", map[string]any{"alg": "HS256"}, map[string]any{"sub": "alice"})
+	tests := []struct {
+		name     string
+		auth     *jwtAuth
+		md       metadata.MD
+		wantCode codes.Code
+		wantUser string
+	}{
+		{"disabled", nil, nil, codes.OK, ""},
+		{"no metadata", &jwtAuth{secret: []byte("s3cret")}, nil, codes.Unauthenticated, ""},
+		{"no token", &jwtAuth{secret: []byte("s3cret")}, metadata.Pairs("x-other", "1"), codes.Unauthenticated, ""},
+		{"invalid token", &jwtAuth{secret: []byte("other")}, metadata.Pairs("authorization", token), codes.Unauthenticated, ""},
+		{"valid token", &jwtAuth{secret: []byte("s3cret")}, metadata.Pairs("authorization", token), codes.OK, "alice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			var gotUser string
+			handler := func(ctx context.Context, req any) (any, error) {
+				gotUser = baggage.FromContext(ctx).Member("user.id").Value()
+				return "ok", nil
+			}
+
+			_, err := tt.auth.UnaryServerInterceptor()(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/GetUser"}, handler)
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("code = %s, want %s", got, tt.wantCode)
+			}
+			if gotUser != tt.wantUser {
+				t.Errorf("user.id baggage = %q, want %q", gotUser, tt.wantUser)
+			}
+		})
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+)
+
+// User is a user as the API returns it.
+type User struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// String returns the plain text form of u, served to clients that do not ask
+// for JSON.
+func (u User) String() string {
+	return fmt.Sprintf("%s with ID %s", u.Name, u.ID)
+}
+
+// LogValue leaves the email out of logs: it is personal data, returned to
+// API clients but never written to the telemetry backends.
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(slog.String("id", u.ID), slog.String("name", u.Name))
+}
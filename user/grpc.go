@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"grpcobs"
+	"proto/userpb"
+	"servicekit"
+
+	"obsmiddleware"
+)
+
+var (
+	EnvGRPCPort     = "GRPC_PORT"
+	DefaultGRPCPort = "9087"
+)
+
+// userServer serves the user lookups of GET /user over gRPC, with
+// the same UserService.
+type userServer struct {
+	userpb.UnimplementedUserServiceServer
+	service UserService
+	audit   *auditLog
+}
+
+func (s *userServer) GetUser(ctx context.Context, req *userpb.GetUserRequest) (*userpb.GetUserResponse, error) {
+	obs := grpcobs.ObsFromCtx(ctx)
+	if req.GetId() == "" {
+		return nil, status.Error(codes.InvalidArgument, "missing user ID")
+	}
+
+	// Every log below, down to the repository, carries the user ID.
+	ctx = obsmiddleware.ContextWith(ctx, obs, "userID", req.GetId())
+
+	userInfo, err := s.service.GetUserInfo(ctx, obs, req.GetId())
+	clientAddress := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		clientAddress = p.Addr.String()
+	}
+	auditProfileRead(obs, s.audit, clientAddress, req.GetId(), err)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		return nil, status.Error(codes.Internal, "failed to fetch user info")
+	}
+
+	obs.Log.Info("User info fetched successfully", "userInfo", userInfo)
+	return &userpb.GetUserResponse{Info: userInfo.String()}, nil
+}
+
+// startGRPCServer serves UserService on GRPC_PORT in the background, next
+// to the HTTP API. Every call gets a server span from grpcobs that continues
+// the caller's trace, and needs a bearer token when auth is not nil, as the
+// HTTP API does. The server drains along with the API when the service stops.
+func startGRPCServer(s *servicekit.Service, service UserService, audit *auditLog, auth *jwtAuth) error {
+	lis, err := net.Listen("tcp", ":"+servicekit.Getenv(EnvGRPCPort, DefaultGRPCPort))
+	if err != nil {
+		return err
+	}
+	server := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		grpcobs.UnaryServerInterceptor(s.Factory),
+		auth.UnaryServerInterceptor(),
+	))
+	userpb.RegisterUserServiceServer(server, &userServer{service: service, audit: audit})
+	go func() {
+		if err := server.Serve(lis); err != nil {
+			s.Obs.ErrorHandler.Record(err, "gRPC server stopped")
+		}
+	}()
+	s.OnDrain(func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			// Cancel the calls still running.
+			server.Stop()
+			return ctx.Err()
+		}
+	})
+	s.Obs.Log.Info("gRPC server running", "address", lis.Addr().String())
+	return nil
+}
@@ -7,36 +7,52 @@ import (
 	"strings"
 
 	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
 )
 
 // ErrUserNotFound is returned when a user is not found.
 var ErrUserNotFound = errors.New("user not found")
 
 type UserRepository interface {
-	GetUserByID(ctx context.Context, obs *observability.Observability, id string) (string, error)
+	GetUserByID(ctx context.Context, obs *observability.Observability, id string) (User, error)
 }
 
 type userRepositoryImpl struct{}
 
-func (r *userRepositoryImpl) GetUserByID(ctx context.Context, obs *observability.Observability, id string) (string, error) {
-	ctx, obs, span := observability.StartSpanFromCtx(ctx, "UserRepository.GetUserByID", observability.SpanAttributes{"user.id": id})
-	defer span.End()
-
-	obs.Log.With(
-		"userID", id,
-	).Debug("Fetching user data")
+func (r *userRepositoryImpl) GetUserByID(ctx context.Context, obs *observability.Observability, id string) (User, error) {
+	obsmiddleware.LogDebug(obs, "Fetching user data")
 
 	// Simulate DB fetch: if the ID starts with "missing-", return not found.
 	if strings.HasPrefix(id, "missing-") {
-		obs.Log.With("userID", id).Warn("User not found in repository")
-		return "", ErrUserNotFound
+		obs.Log.Warn("User not found in repository")
+		obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{"db.response.returned_rows": 0})
+		return User{}, ErrUserNotFound
+	}
+
+	// Simulate a bug: if the ID starts with "panic-", blow up so the
+	// obsmiddleware.Recoverer can be seen in action.
+	if strings.HasPrefix(id, "panic-") {
+		panic(fmt.Sprintf("corrupted user record %s", id))
 	}
 
 	// Otherwise, return a dummy user with its ID.
-	obs.Log.With("userID", id).Debug("User found in repository")
-	return fmt.Sprintf("User ABC with ID %s", id), nil
+	obsmiddleware.LogDebug(obs, "User found in repository")
+	obsmiddleware.AddAttrs(ctx, observability.SpanAttributes{"db.response.returned_rows": 1})
+	return User{ID: id, Name: "User ABC", Email: id + "@example.com"}, nil
+}
+
+// tracedUserRepository traces every call to the wrapped repository.
+type tracedUserRepository struct {
+	next UserRepository
+}
+
+func (r *tracedUserRepository) GetUserByID(ctx context.Context, obs *observability.Observability, id string) (User, error) {
+	return obsmiddleware.Traced(ctx, "UserRepository.GetUserByID", func(ctx context.Context, obs *observability.Observability) (User, error) {
+		return r.next.GetUserByID(ctx, obs, id)
+	}, observability.String("user.id", id))
 }
 
 func NewUserRepository() UserRepository {
-	return &userRepositoryImpl{}
-}
\ No newline at end of file
+	return &tracedUserRepository{next: &userRepositoryImpl{}}
+}
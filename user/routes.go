@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
+	"servicekit"
+)
+
+// registerRoutes sets the user store up, starts the gRPC server, and returns the user API.
+func registerRoutes(s *servicekit.Service) http.Handler {
+	audit, err := newAuditLog()
+	if err != nil {
+		s.Fatal("Failed to open audit log", "error", err)
+	}
+	s.OnShutdown(func() { audit.Close() })
+
+	events, err := obsmiddleware.NewDomainEvents(s.Meter)
+	if err != nil {
+		s.Fatal("Failed to create domain events", "error", err)
+	}
+
+	repo := NewUserRepository()
+	service := NewUserService(repo, events)
+
+	// With JWT_SECRET set, the user endpoints, over HTTP and gRPC, require a
+	// bearer token.
+	auth := newJWTAuth()
+	if auth != nil {
+		s.Obs.Log.Info("JWT authentication enabled")
+	}
+
+	mux := http.NewServeMux()
+	for _, pattern := range []string{"GET /user", "GET /user/{id}"} {
+		s.Handle(mux, pattern, auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handleUser(r.Context(), w, r, observability.ObsFromCtx(r.Context()), service, audit)
+		})))
+	}
+
+	if err := startGRPCServer(s, service, audit, auth); err != nil {
+		s.Fatal("Failed to start gRPC server", "error", err)
+	}
+
+	return obsmiddleware.WithRoute(mux)
+}
+
+// handleUser now centralizes all error handling logic.
+func handleUser(ctx context.Context,
+	w http.ResponseWriter, r *http.Request,
+	obs *observability.Observability,
+	service UserService,
+	audit *auditLog) {
+	userID := r.PathValue("id")
+	if userID == "" {
+		userID = r.URL.Query().Get("id")
+	}
+
+	if userID == "" {
+		obs.ErrorHandler.HTTP(w, "Missing user ID", http.StatusBadRequest)
+		return
+	}
+
+	// Every log below, down to the repository, carries the user ID.
+	ctx = obsmiddleware.ContextWith(ctx, obs, "userID", userID)
+	obsmiddleware.LogDebug(obs, "Searching for user info")
+
+	userInfo, err := service.GetUserInfo(ctx, obs, userID)
+	auditProfileRead(obs, audit, r.RemoteAddr, userID, err)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			obs.ErrorHandler.HTTP(w, "User not found", http.StatusNotFound)
+		} else {
+			obs.ErrorHandler.HTTP(w, "Failed to fetch user info", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	obs.Log.Info("User info fetched successfully", "userInfo", userInfo)
+	obsmiddleware.Respond(w, r, obs, http.StatusOK, userInfo)
+}
+
+// auditProfileRead records an access to the profile of userID in the audit
+// log. Profiles hold personal data, so every read is audited, including the
+// ones that fail.
+func auditProfileRead(obs *observability.Observability, audit *auditLog, clientAddress string, userID string, err error) {
+	outcome := AuditSuccess
+	fields := []any{"client.address", clientAddress}
+	if err != nil {
+		outcome = AuditFailure
+		fields = append(fields, "error", err.Error())
+	}
+	if auditErr := audit.Audit(obs, "user.profile.read", userID, outcome, fields...); auditErr != nil {
+		obs.ErrorHandler.Record(auditErr, "Failed to write audit record")
+	}
+}
@@ -2,39 +2,45 @@ package main
 
 import (
 	"context"
+	"errors"
 
 	"github.com/app-obs/go/observability"
+
+	"obsmiddleware"
 )
 
 type UserService interface {
-	GetUserInfo(ctx context.Context, obs *observability.Observability, userID string) (string, error)
+	GetUserInfo(ctx context.Context, obs *observability.Observability, userID string) (User, error)
 }
 
+// eventUserNotFound is emitted for lookups of users that do not exist, which
+// in numbers point at stale links or enumeration attempts.
+var eventUserNotFound = obsmiddleware.DomainEvent{Name: "user.not_found", Counted: true}
+
 type userServiceImpl struct {
-	repo UserRepository
+	repo   UserRepository
+	events *obsmiddleware.DomainEvents
 }
 
-func (s *userServiceImpl) GetUserInfo(ctx context.Context, obs *observability.Observability, userID string) (string, error) {
-	ctx, obs, span := observability.StartSpanFromCtx(ctx, "UserService.GetUserInfo", observability.SpanAttributes{"user.id": userID})
+func (s *userServiceImpl) GetUserInfo(ctx context.Context, obs *observability.Observability, userID string) (User, error) {
+	ctx, obs, span := obsmiddleware.StartSpan(ctx, "UserService.GetUserInfo", observability.String("user.id", userID))
 	defer span.End()
 
-	obs.Log.With(
-		"userID", userID,
-	).Debug("Processing request")
+	obsmiddleware.LogDebug(obs, "Processing request")
 
 	userInfo, err := s.repo.GetUserByID(ctx, obs, userID)
 	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			s.events.Emit(obs, eventUserNotFound, map[string]any{"user.id": userID})
+		}
 		obs.ErrorHandler.Record(err, "Error fetching user")
-		return "", err
+		return User{}, err
 	}
 
-	obs.Log.With(
-		"userID", userID,
-		"userInfo", userInfo,
-	).Info("Successfully retrieved user info")
+	obs.Log.With("userInfo", userInfo).Info("Successfully retrieved user info")
 	return userInfo, nil
 }
 
-func NewUserService(repo UserRepository) UserService {
-	return &userServiceImpl{repo: repo}
-}
\ No newline at end of file
+func NewUserService(repo UserRepository, events *obsmiddleware.DomainEvents) UserService {
+	return &userServiceImpl{repo: repo, events: events}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	amqp "github.com/rabbitmq/amqp091-go"
+
+	"amqpobs"
+)
+
+// ErrJobFailed is returned for jobs of type "fail", to demo error traces.
+var ErrJobFailed = errors.New("job failed")
+
+// JobPublisher publishes jobs to the jobs queue.
+type JobPublisher struct {
+	mu     sync.Mutex // serializes publishes on the shared channel
+	ch     *amqp.Channel
+	nextID uint64
+}
+
+func NewJobPublisher(ch *amqp.Channel) *JobPublisher {
+	return &JobPublisher{ch: ch}
+}
+
+// Publish enqueues a job of jobType and returns its ID.
+func (p *JobPublisher) Publish(ctx context.Context, obs *observability.Observability, jobType string) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.nextID++
+	jobID := "job-" + strconv.FormatUint(p.nextID, 10)
+
+	err := amqpobs.Publish(ctx, p.ch, "", jobsQueue, amqp.Publishing{
+		MessageId:   jobID,
+		Type:        jobType,
+		ContentType: "text/plain",
+		Timestamp:   time.Now(),
+	})
+	if err != nil {
+		obs.ErrorHandler.Record(err, "Error publishing job")
+		return "", err
+	}
+	return jobID, nil
+}
+
+// consumeJobs hands every delivery to handle, acking successful ones and
+// dropping failed ones, until deliveries is closed. Cancel ctx once the
+// consumer has been canceled, so the end is not reported as a failure.
+func consumeJobs(ctx context.Context, obs *observability.Observability, deliveries <-chan amqp.Delivery, handle func(amqp.Delivery) error) {
+	for d := range deliveries {
+		if err := handle(d); err != nil {
+			if nackErr := d.Nack(false, false); nackErr != nil {
+				obs.ErrorHandler.Record(nackErr, "Failed to nack delivery")
+			}
+			continue
+		}
+		if ackErr := d.Ack(false); ackErr != nil {
+			obs.ErrorHandler.Record(ackErr, "Failed to ack delivery")
+		}
+	}
+	if ctx.Err() != nil {
+		obs.Log.Info("Job consumer stopping")
+		return
+	}
+	obs.Log.Warn("Delivery channel closed, no longer consuming jobs")
+}
+
+// processJob simulates doing the work of a job.
+func processJob(ctx context.Context, obs *observability.Observability, d amqp.Delivery) error {
+	_, obs, span := obs.StartSpanWith("Job.process",
+		observability.String("job.id", d.MessageId),
+		observability.String("job.type", d.Type),
+	)
+	defer span.End()
+
+	obs.Log.Info("Processing job", "jobID", d.MessageId, "jobType", d.Type)
+
+	// Simulate some work.
+	time.Sleep(time.Duration(50+rand.IntN(150)) * time.Millisecond)
+
+	if d.Type == "fail" {
+		err := fmt.Errorf("%w: %s", ErrJobFailed, d.MessageId)
+		obs.ErrorHandler.Record(err, "Error processing job")
+		return err
+	}
+
+	obs.Log.Info("Job processed", "jobID", d.MessageId)
+	return nil
+}
@@ -0,0 +1,9 @@
+package main
+
+import "servicekit"
+
+var DefaultPort = "8088"
+
+func main() {
+	servicekit.Run("worker", registerRoutes, servicekit.WithDefaultPort(DefaultPort))
+}
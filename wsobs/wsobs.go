@@ -0,0 +1,183 @@
+// Package wsobs traces WebSocket connections for services built on the
+// observability library. A connection gets a connection span, a child of the
+// span of the upgrade request, which lasts until the connection closes and
+// records how many messages went each way and how the connection ended.
+// Every message gets a span of its own, which starts a new trace linked to
+// the connection span: a connection can stay open for hours, and one trace
+// holding all of its messages would be too big to show or to sample.
+//
+// Like amqpobs, the helpers use OpenTelemetry span kinds and links, so the
+// spans are only exported when the service runs with the OTLP APM type.
+package wsobs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/app-obs/go/observability"
+	"github.com/coder/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "wsobs"
+
+// Conn is a WebSocket connection traced by a connection span. Send and
+// Receive may be called concurrently, but each of them from one goroutine at
+// a time.
+type Conn struct {
+	conn    *websocket.Conn
+	factory *observability.Factory
+	route   string
+
+	ctx  context.Context
+	span trace.Span
+	obs  *observability.Observability
+
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+// Accept upgrades the request r to a WebSocket connection and starts its
+// connection span, named "WebSocket <route>". route is the pattern of the
+// endpoint, such as "/products/{id}/price-updates", and names the message
+// spans too. If the upgrade fails, Accept has already answered the request.
+func Accept(factory *observability.Factory, w http.ResponseWriter, r *http.Request, route string, opts *websocket.AcceptOptions) (*Conn, error) {
+	conn, err := websocket.Accept(w, r, opts)
+	if err != nil {
+		return nil, err
+	}
+	ctx, span := otel.Tracer(tracerName).Start(r.Context(), "WebSocket "+route,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(attribute.String("websocket.route", route)),
+	)
+	return &Conn{
+		conn:    conn,
+		factory: factory,
+		route:   route,
+		ctx:     ctx,
+		span:    span,
+		obs:     factory.NewBackgroundObservability(ctx),
+	}, nil
+}
+
+// Obs returns an Observability instance bound to the connection span, to log
+// the events of the connection as a whole.
+func (c *Conn) Obs() *observability.Observability {
+	return c.obs
+}
+
+// MessageFunc does the work for one message. obs is bound to the message
+// span: use it (rather than ObsFromCtx) to log and to start child spans.
+type MessageFunc func(ctx context.Context, obs *observability.Observability) error
+
+// Send runs fn under a "<route> send" message span. fn prepares the message
+// and writes it with Write, so the work that went into it, such as the
+// lookups of its data, shows up in the message's trace. An error returned by
+// fn is recorded on the span and returned.
+func (c *Conn) Send(fn MessageFunc) error {
+	ctx, obs, span := c.startMessage("send", trace.SpanKindProducer, c.sent.Add(1))
+	defer span.End()
+	if err := fn(ctx, obs); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "send failed")
+		return err
+	}
+	return nil
+}
+
+// Write writes data as one message, on behalf of the message span in ctx,
+// on which it records the message type and size.
+func (c *Conn) Write(ctx context.Context, typ websocket.MessageType, data []byte) error {
+	trace.SpanFromContext(ctx).SetAttributes(
+		attribute.String("websocket.message.type", messageType(typ)),
+		attribute.Int("websocket.message.size", len(data)),
+	)
+	return c.conn.Write(ctx, typ, data)
+}
+
+// ReceiveFunc handles one message received from the client. obs is bound to
+// the message span.
+type ReceiveFunc func(ctx context.Context, obs *observability.Observability, typ websocket.MessageType, data []byte) error
+
+// Receive waits for the next message from the client and handles it with h
+// under a "<route> receive" message span, which starts once the message has
+// arrived. It returns the error that ended the read, such as the client
+// closing the connection, or the error h returned, which is also recorded on
+// the span.
+func (c *Conn) Receive(ctx context.Context, h ReceiveFunc) error {
+	typ, data, err := c.conn.Read(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, obs, span := c.startMessage("receive", trace.SpanKindConsumer, c.received.Add(1))
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("websocket.message.type", messageType(typ)),
+		attribute.Int("websocket.message.size", len(data)),
+	)
+	if err := h(ctx, obs, typ, data); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "receive failed")
+		return err
+	}
+	return nil
+}
+
+// startMessage starts the span of the index-th message in direction op, in
+// a new trace linked to the connection span.
+func (c *Conn) startMessage(op string, kind trace.SpanKind, index int64) (context.Context, *observability.Observability, trace.Span) {
+	ctx, span := otel.Tracer(tracerName).Start(context.Background(), c.route+" "+op,
+		trace.WithNewRoot(),
+		trace.WithLinks(trace.LinkFromContext(c.ctx)),
+		trace.WithSpanKind(kind),
+		trace.WithAttributes(
+			attribute.String("websocket.route", c.route),
+			attribute.Int64("websocket.message.index", index),
+		),
+	)
+	return ctx, c.factory.NewBackgroundObservability(ctx), span
+}
+
+// Close closes the connection and ends the connection span. cause is the
+// error that ended the connection, or nil if the server ends it. A close by
+// the client, or its disappearance, is recorded in websocket.close.code, and
+// any other cause as an error on the span, with the connection closed as an
+// internal error.
+func (c *Conn) Close(cause error) error {
+	defer c.span.End()
+	c.span.SetAttributes(
+		attribute.Int64("websocket.messages.sent", c.sent.Load()),
+		attribute.Int64("websocket.messages.received", c.received.Load()),
+	)
+
+	if code := websocket.CloseStatus(cause); code != -1 {
+		c.span.SetAttributes(attribute.Int("websocket.close.code", int(code)))
+		return c.conn.CloseNow()
+	}
+	if errors.Is(cause, io.EOF) || errors.Is(cause, io.ErrUnexpectedEOF) {
+		// The client went away without a close message.
+		c.span.SetAttributes(attribute.Int("websocket.close.code", int(websocket.StatusAbnormalClosure)))
+		return c.conn.CloseNow()
+	}
+	if cause != nil && !errors.Is(cause, context.Canceled) {
+		c.span.RecordError(cause)
+		c.span.SetStatus(codes.Error, "connection failed")
+		c.span.SetAttributes(attribute.Int("websocket.close.code", int(websocket.StatusInternalError)))
+		return c.conn.Close(websocket.StatusInternalError, "internal error")
+	}
+	c.span.SetAttributes(attribute.Int("websocket.close.code", int(websocket.StatusNormalClosure)))
+	return c.conn.Close(websocket.StatusNormalClosure, "")
+}
+
+func messageType(typ websocket.MessageType) string {
+	if typ == websocket.MessageBinary {
+		return "binary"
+	}
+	return "text"
+}
@@ -0,0 +1,134 @@
+package wsobs
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/app-obs/go/observability"
+	"github.com/coder/websocket"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	testFactory = observability.NewFactory(
+		observability.WithServiceName("wsobs-test"),
+		observability.WithApmType("none"),
+		observability.WithMetricsType("none"),
+	)
+	recorder = tracetest.NewSpanRecorder()
+)
+
+func TestMain(m *testing.M) {
+	if _, err := testFactory.Setup(context.Background()); err != nil {
+		panic(err)
+	}
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	os.Exit(m.Run())
+}
+
+// attr returns the value of the attribute key of span.
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestConn(t *testing.T) {
+	errHandler := errors.New("handler failed")
+	tests := []struct {
+		name string
+		// clientClose closes the connection from the client after the first
+		// message; otherwise the server's receive handler fails.
+		clientClose   bool
+		wantCloseCode int64
+		wantStatus    codes.Code
+	}{
+		{"client closes", true, int64(websocket.StatusNormalClosure), codes.Unset},
+		{"handler fails", false, int64(websocket.StatusInternalError), codes.Error},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder.Reset()
+			done := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				defer close(done)
+				c, err := Accept(testFactory, w, r, "/updates", nil)
+				if err != nil {
+					t.Errorf("Accept = %v", err)
+					return
+				}
+				err = c.Send(func(ctx context.Context, obs *observability.Observability) error {
+					return c.Write(ctx, websocket.MessageText, []byte("hello"))
+				})
+				if err == nil {
+					err = c.Receive(r.Context(), func(context.Context, *observability.Observability, websocket.MessageType, []byte) error {
+						return errHandler
+					})
+				}
+				c.Close(err)
+			}))
+			defer srv.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			client, _, err := websocket.Dial(ctx, "ws"+srv.URL[len("http"):], nil)
+			if err != nil {
+				t.Fatalf("Dial = %v", err)
+			}
+			if _, data, err := client.Read(ctx); err != nil || string(data) != "hello" {
+				t.Fatalf("Read = %q, %v, want hello", data, err)
+			}
+			if tt.clientClose {
+				client.Close(websocket.StatusNormalClosure, "")
+			} else {
+				client.Write(ctx, websocket.MessageText, []byte("hi"))
+				client.Read(ctx) // until the server closes
+			}
+			<-done
+
+			var conn, send sdktrace.ReadOnlySpan
+			for _, s := range recorder.Ended() {
+				switch s.Name() {
+				case "WebSocket /updates":
+					conn = s
+				case "/updates send":
+					send = s
+				}
+			}
+			if conn == nil || send == nil {
+				t.Fatalf("ended spans = %d, want the connection and send spans", len(recorder.Ended()))
+			}
+			if got := attr(conn, "websocket.close.code").AsInt64(); got != tt.wantCloseCode {
+				t.Errorf("websocket.close.code = %d, want %d", got, tt.wantCloseCode)
+			}
+			if conn.Status().Code != tt.wantStatus {
+				t.Errorf("connection span status = %v, want %v", conn.Status().Code, tt.wantStatus)
+			}
+			if got := attr(conn, "websocket.messages.sent").AsInt64(); got != 1 {
+				t.Errorf("websocket.messages.sent = %d, want 1", got)
+			}
+			// Messages start their own trace, linked to the connection.
+			if send.SpanContext().TraceID() == conn.SpanContext().TraceID() {
+				t.Error("send span in the connection's trace, want a new trace")
+			}
+			if links := send.Links(); len(links) != 1 || links[0].SpanContext.SpanID() != conn.SpanContext().SpanID() {
+				t.Errorf("send span links = %v, want the connection span", links)
+			}
+			if got := attr(send, "websocket.message.size").AsInt64(); got != 5 {
+				t.Errorf("websocket.message.size = %d, want 5", got)
+			}
+		})
+	}
+}